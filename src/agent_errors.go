@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FailureKind says what to do about an agent session that failed.
+type FailureKind int
+
+const (
+	// FailureRetryable failures are transient, such as a network error or
+	// an overloaded API. The candidate is retried after a backoff.
+	FailureRetryable FailureKind = iota
+	// FailureFatal failures will not go away on retry, such as an expired
+	// login or an unknown model. The run stops.
+	FailureFatal
+	// FailureCandidate failures are down to the candidate, such as a prompt
+	// that overflows the context window. The candidate is ignored.
+	FailureCandidate
+)
+
+func (k FailureKind) String() string {
+	switch k {
+	case FailureFatal:
+		return "fatal"
+	case FailureCandidate:
+		return "candidate"
+	default:
+		return "retryable"
+	}
+}
+
+// Failure is a backend's classification of a failed agent session.
+type Failure struct {
+	Kind   FailureKind
+	Reason string // The error line that decided the kind; empty for retryable failures
+}
+
+// FailurePhrases are the substrings that mark a failure as fatal or
+// candidate-specific, matched like rate limit phrases (/.../ for regexes).
+type FailurePhrases struct {
+	Fatal     []string
+	Candidate []string
+}
+
+// Classify maps the errors a failed session reported and its exit code to a
+// failure kind. Exit codes 126 and 127 mean the shell could not run the agent
+// at all, which no retry will fix.
+func (p FailurePhrases) Classify(errors string, exitCode int) Failure {
+	if exitCode == 126 || exitCode == 127 {
+		return Failure{Kind: FailureFatal, Reason: fmt.Sprintf("agent command could not be run (exit code %d)", exitCode)}
+	}
+	if reason, ok := matchFailure(errors, p.Fatal); ok {
+		return Failure{Kind: FailureFatal, Reason: reason}
+	}
+	if reason, ok := matchFailure(errors, p.Candidate); ok {
+		return Failure{Kind: FailureCandidate, Reason: reason}
+	}
+	return Failure{Kind: FailureRetryable}
+}
+
+// matchFailure returns the line of errors containing the first of phrases
+// found in it.
+func matchFailure(errors string, phrases []string) (string, bool) {
+	match, ok := findRateLimitMatch(errors, phrases)
+	if !ok {
+		return "", false
+	}
+	idx := strings.Index(errors, match.phrase)
+	start := strings.LastIndex(errors[:idx], "\n") + 1
+	end := len(errors)
+	if n := strings.Index(errors[idx:], "\n"); n >= 0 {
+		end = idx + n
+	}
+	return truncateSummary(errors[start:end]), true
+}
+
+// agentError is an agent failure that was not a timeout or a rate limit,
+// with the backend's classification of it.
+type agentError struct {
+	failure Failure
+	err     error
+}
+
+func (e *agentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *agentError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		backend  Backend
+		errors   string
+		exitCode int
+		want     Failure
+	}{
+		{"claude expired login", &ClaudeBackend{}, "Invalid API key · Please run /login\n", 1,
+			Failure{FailureFatal, "Invalid API key · Please run /login"}},
+		{"claude prompt too long", &ClaudeBackend{}, "Prompt is too long\n", 1,
+			Failure{FailureCandidate, "Prompt is too long"}},
+		{"claude max turns", &ClaudeBackend{}, "error_max_turns\n", 1,
+			Failure{FailureCandidate, "error_max_turns"}},
+		{"claude overloaded", &ClaudeBackend{}, "API Error: 529 overloaded_error\n", 1,
+			Failure{Kind: FailureRetryable}},
+		{"codex missing model", &CodexBackend{}, "turn failed\nunexpected status 404: model_not_found: gpt-9\n", 1,
+			Failure{FailureFatal, "unexpected status 404: model_not_found: gpt-9"}},
+		{"codex context overflow", &CodexBackend{}, "context_length_exceeded: input exceeds the context window\n", 1,
+			Failure{FailureCandidate, "context_length_exceeded: input exceeds the context window"}},
+		{"codex network blip", &CodexBackend{}, "stream disconnected before completion\n", 1,
+			Failure{Kind: FailureRetryable}},
+		{"gemini bad key", &GeminiBackend{}, "[400] API key not valid. Please pass a valid API key.\n", 1,
+			Failure{FailureFatal, "[400] API key not valid. Please pass a valid API key."}},
+		{"gemini token limit", &GeminiBackend{}, "The input token count (1200000) exceeds the maximum number of tokens allowed (1048576).\n", 1,
+			Failure{FailureCandidate, "The input token count (1200000) exceeds the maximum number of tokens allowed (1048576)."}},
+		{"openai unauthorized", &OpenAIBackend{}, "HTTP 401 Unauthorized\n{\"error\":\"bad key\"}\n", 0,
+			Failure{FailureFatal, "HTTP 401 Unauthorized"}},
+		{"openai server error", &OpenAIBackend{}, "HTTP 502 Bad Gateway\n", 0,
+			Failure{Kind: FailureRetryable}},
+		{"command not found", &ClaudeBackend{}, "bash: claude: command not found\n", 127,
+			Failure{FailureFatal, "agent command could not be run (exit code 127)"}},
+		{"custom backend phrases", &CustomBackend{spec: BackendSpec{
+			FatalErrors:     []string{"/token expired at \\d+/"},
+			CandidateErrors: []string{"input too large"},
+		}}, "error: token expired at 1700000000\n", 1,
+			Failure{FailureFatal, "error: token expired at 1700000000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.backend.ClassifyFailure(tt.errors, tt.exitCode); got != tt.want {
+				t.Errorf("ClassifyFailure(%q, %d) = %+v, want %+v", tt.errors, tt.exitCode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunIterationAgentFailure(t *testing.T) {
+	claudeError := func(text string) string {
+		return `echo '{"type":"result","subtype":"success","is_error":true,"result":"` + text + `"}'; exit 1`
+	}
+
+	tests := []struct {
+		name        string
+		agentScript string
+		wantFatal   bool
+		wantErr     bool
+		wantIgnored bool
+	}{
+		{"transient error is retried", "echo 'connection reset by peer' >&2; exit 1", false, true, false},
+		{"expired login stops the run", claudeError("Invalid API key · Please run /login"), true, true, false},
+		{"context overflow ignores the candidate", claudeError("Prompt is too long"), false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, dir := newScriptedRunner(t, tt.agentScript,
+				Task{CandidateSource: `echo '["c1"]'`},
+				Config{ResetCommand: "touch reset", SuccessCommand: "touch committed"},
+			)
+
+			var err error
+			captureStdout(t, func() {
+				_, err = runner.runIteration()
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runIteration() error = %v, want error %v", err, tt.wantErr)
+			}
+			if _, isFatal := err.(*fatalError); isFatal != tt.wantFatal {
+				t.Errorf("runIteration() error = %T %v, want fatal %v", err, err, tt.wantFatal)
+			}
+			if got := runner.ignoredList.Contains("c1"); got != tt.wantIgnored {
+				t.Errorf("candidate ignored = %v, want %v", got, tt.wantIgnored)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "reset")); err != nil {
+				t.Error("expected the agent's changes to be reset")
+			}
+			if tt.wantIgnored {
+				if runner.outcome != OutcomeAgentFailed {
+					t.Errorf("outcome = %s, want %s", runner.outcome, OutcomeAgentFailed)
+				}
+				log, _ := os.ReadFile(runner.agentLogger.Path())
+				if !strings.Contains(string(log), "Outcome: AGENT_FAILED") || !strings.Contains(string(log), "Prompt is too long") {
+					t.Errorf("agent log missing the failure:\n%s", log)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AgentSpec is one entry of an `agents:` fallback chain.
+type AgentSpec struct {
+	Agent      string `yaml:"agent"`
+	AgentFlags string `yaml:"agent_flags"`
+	Backend    string `yaml:"backend"`
+}
+
+// agentChoice is an agent the runner can hand candidates to. When the active
+// agent is rate limited it cools down and the next one in the chain takes over.
+type agentChoice struct {
+	Agent         string
+	Flags         string
+	BackendName   string // Built-in or declared backend; detected from Agent when empty
+	source        string // Where the agent was configured, for verbose output
+	backend       Backend
+	cooldownUntil time.Time
+}
+
+// label names the agent for the console and the log.
+func (a *agentChoice) label() string {
+	return fmt.Sprintf("%s (%s)", a.backend.DisplayName(), a.Agent)
+}
+
+// resolveAgents builds the agent chain. Precedence: CLI override > task-level
+// > global, where each level is either an `agents` list or a single `agent`.
+// The CLI --agent-flags override applies to the preferred agent.
+func (r *Runner) resolveAgents() []*agentChoice {
+	var chain []*agentChoice
+	switch {
+	case r.opts.Agent != "":
+		chain = []*agentChoice{{Agent: r.opts.Agent, source: "CLI override"}}
+	case len(r.task.Agents) > 0:
+		chain = agentChain(r.task.Agents, "task-level")
+	case r.task.Agent != "":
+		chain = []*agentChoice{{Agent: r.task.Agent, source: "task-level"}}
+	case len(r.env.Config.Agents) > 0:
+		chain = agentChain(r.env.Config.Agents, "global")
+	default:
+		chain = []*agentChoice{{Agent: r.env.Config.Agent, source: "global"}}
+	}
+
+	// Single agents take flags from the usual places: CLI override > task-level > global
+	if len(chain) == 1 && chain[0].Flags == "" {
+		chain[0].Flags = r.task.AgentFlags
+		if chain[0].Flags == "" {
+			chain[0].Flags = r.env.Config.AgentFlags
+		}
+	}
+	if r.opts.AgentFlags != "" {
+		chain[0].Flags = r.opts.AgentFlags
+	}
+
+	// Backends: agent entry > task-level > global > detected from the command
+	for _, agent := range chain {
+		if agent.BackendName == "" {
+			agent.BackendName = r.task.Backend
+		}
+		if agent.BackendName == "" {
+			agent.BackendName = r.env.Config.Backend
+		}
+		agent.backend = newBackend(agent.BackendName, agent.Agent, r.env.Config.Backends)
+	}
+	return chain
+}
+
+func agentChain(specs []AgentSpec, source string) []*agentChoice {
+	chain := make([]*agentChoice, len(specs))
+	for i, spec := range specs {
+		chain[i] = &agentChoice{Agent: spec.Agent, Flags: spec.AgentFlags, BackendName: spec.Backend, source: source}
+	}
+	return chain
+}
+
+// agentChainString lists the chain for the startup banner, e.g. "Claude → Codex".
+func agentChainString(chain []*agentChoice) string {
+	names := make([]string, len(chain))
+	for i, agent := range chain {
+		names[i] = agent.backend.DisplayName()
+	}
+	return strings.Join(names, " → ")
+}
+
+// activeAgent returns the agent currently handling candidates.
+func (r *Runner) activeAgent() *agentChoice {
+	return r.agents[r.active]
+}
+
+// selectAgent switches to the most preferred agent that isn't cooling down.
+// If every agent is cooling down, it picks the one available soonest and
+// returns how long until it is.
+func (r *Runner) selectAgent(now time.Time) time.Duration {
+	best := 0
+	for i, agent := range r.agents {
+		if !now.Before(agent.cooldownUntil) {
+			best = i
+			break
+		}
+		if agent.cooldownUntil.Before(r.agents[best].cooldownUntil) {
+			best = i
+		}
+	}
+
+	wait := r.agents[best].cooldownUntil.Sub(now)
+	if best != r.active && wait <= 0 {
+		previous, previousIndex := r.activeAgent(), r.active
+		if best < previousIndex {
+			fmt.Fprintln(r.console(), ColorInfo(fmt.Sprintf("Cooldown over, switching back to %s", r.agents[best].label())))
+		} else {
+			fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("%s is cooling down until %s, switching to %s",
+				previous.label(), previous.cooldownUntil.Format("15:04"), r.agents[best].label())))
+		}
+	}
+	r.active = best
+	r.backend = r.activeAgent().backend
+
+	if wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// coolDownActiveAgent puts the active agent on a rate limit cooldown until
+// just after resetAt, or for the fixed backoff when resetAt is zero, and
+// switches to the next available one. Returns how long to wait before any
+// agent is available (0 if another agent can take over straight away).
+func (r *Runner) coolDownActiveAgent(now, resetAt time.Time) time.Duration {
+	r.activeAgent().cooldownUntil = rateLimitCooldown(now, resetAt)
+	return r.selectAgent(now)
+}
+
+// validateAgents checks an `agents` list and that it isn't combined with a
+// single `agent` in the same file.
+func validateAgents(agent string, agents []AgentSpec) error {
+	if len(agents) == 0 {
+		return nil
+	}
+	if agent != "" {
+		return fmt.Errorf("'agent' and 'agents' cannot both be set")
+	}
+	for i, spec := range agents {
+		if strings.TrimSpace(spec.Agent) == "" {
+			return fmt.Errorf("agents[%d] is missing 'agent'", i)
+		}
+	}
+	return nil
+}
+
+// expandAgentTildes expands ~ in each agent command.
+func expandAgentTildes(agents []AgentSpec) {
+	for i := range agents {
+		agents[i].Agent = expandTilde(agents[i].Agent)
+	}
+}
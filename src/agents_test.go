@@ -0,0 +1,145 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveAgents(t *testing.T) {
+	chain := []AgentSpec{
+		{Agent: "claude", AgentFlags: "--model opus"},
+		{Agent: "codex"},
+	}
+
+	tests := []struct {
+		name      string
+		config    Config
+		task      Task
+		opts      RunnerOptions
+		wantAgent []string
+		wantFlags []string
+	}{
+		{
+			name:      "global agent",
+			config:    Config{Agent: "claude", AgentFlags: "--global"},
+			wantAgent: []string{"claude"},
+			wantFlags: []string{"--global"},
+		},
+		{
+			name:      "task agent and flags override global",
+			config:    Config{Agent: "claude", AgentFlags: "--global"},
+			task:      Task{Agent: "codex", AgentFlags: "--task"},
+			wantAgent: []string{"codex"},
+			wantFlags: []string{"--task"},
+		},
+		{
+			name:      "global chain",
+			config:    Config{Agents: chain, AgentFlags: "--global"},
+			wantAgent: []string{"claude", "codex"},
+			wantFlags: []string{"--model opus", ""},
+		},
+		{
+			name:      "task chain overrides global agent",
+			config:    Config{Agent: "claude"},
+			task:      Task{Agents: chain},
+			wantAgent: []string{"claude", "codex"},
+			wantFlags: []string{"--model opus", ""},
+		},
+		{
+			name:      "task agent overrides global chain",
+			config:    Config{Agents: chain},
+			task:      Task{Agent: "codex"},
+			wantAgent: []string{"codex"},
+			wantFlags: []string{""},
+		},
+		{
+			name:      "CLI agent overrides chain",
+			task:      Task{Agents: chain},
+			opts:      RunnerOptions{Agent: "custom", AgentFlags: "--cli"},
+			wantAgent: []string{"custom"},
+			wantFlags: []string{"--cli"},
+		},
+		{
+			name:      "CLI flags apply to preferred agent",
+			task:      Task{Agents: chain},
+			opts:      RunnerOptions{AgentFlags: "--cli"},
+			wantAgent: []string{"claude", "codex"},
+			wantFlags: []string{"--cli", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Runner{env: &Environment{Config: tt.config}, task: tt.task, opts: tt.opts}
+			got := r.resolveAgents()
+			if len(got) != len(tt.wantAgent) {
+				t.Fatalf("resolveAgents() returned %d agents, want %d", len(got), len(tt.wantAgent))
+			}
+			for i, agent := range got {
+				if agent.Agent != tt.wantAgent[i] || agent.Flags != tt.wantFlags[i] {
+					t.Errorf("agent %d = %q %q, want %q %q", i, agent.Agent, agent.Flags, tt.wantAgent[i], tt.wantFlags[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCoolDownActiveAgentFallsBackAndReturns(t *testing.T) {
+	r := &Runner{env: &Environment{}, task: Task{Agents: []AgentSpec{{Agent: "claude"}, {Agent: "codex"}}}}
+	r.agents = r.resolveAgents()
+	now := time.Now()
+
+	captureStdout(t, func() {
+		r.selectAgent(now)
+		if r.backend.DisplayName() != "Claude" {
+			t.Fatalf("initial backend = %s, want Claude", r.backend.DisplayName())
+		}
+
+		if wait := r.coolDownActiveAgent(now, time.Time{}); wait != 0 {
+			t.Fatalf("wait = %s, want 0 with a fallback available", wait)
+		}
+		if r.backend.DisplayName() != "Codex" {
+			t.Fatalf("backend after rate limit = %s, want Codex", r.backend.DisplayName())
+		}
+
+		// Still on the fallback during the cooldown
+		r.selectAgent(now.Add(rateLimitBackoff / 2))
+		if r.backend.DisplayName() != "Codex" {
+			t.Fatalf("backend during cooldown = %s, want Codex", r.backend.DisplayName())
+		}
+
+		// Fallback rate limited too: wait for the preferred agent's cooldown
+		later := now.Add(rateLimitBackoff / 2)
+		if wait := r.coolDownActiveAgent(later, time.Time{}); wait != rateLimitBackoff/2 {
+			t.Fatalf("wait = %s, want %s", wait, rateLimitBackoff/2)
+		}
+		if r.backend.DisplayName() != "Claude" {
+			t.Fatalf("backend with all agents cooling down = %s, want Claude (available first)", r.backend.DisplayName())
+		}
+
+		r.selectAgent(now.Add(rateLimitBackoff))
+		if r.backend.DisplayName() != "Claude" {
+			t.Fatalf("backend after cooldown = %s, want Claude", r.backend.DisplayName())
+		}
+	})
+}
+
+func TestCoolDownSingleAgentWaitsFullBackoff(t *testing.T) {
+	r := &Runner{env: &Environment{Config: Config{Agent: "claude"}}}
+	r.agents = r.resolveAgents()
+
+	if wait := r.coolDownActiveAgent(time.Now(), time.Time{}); wait != rateLimitBackoff {
+		t.Fatalf("wait = %s, want %s", wait, rateLimitBackoff)
+	}
+}
+
+func TestCoolDownUntilParsedReset(t *testing.T) {
+	r := &Runner{env: &Environment{Config: Config{Agent: "claude"}}}
+	r.agents = r.resolveAgents()
+
+	now := time.Now()
+	wait := r.coolDownActiveAgent(now, now.Add(10*time.Minute))
+	if wait < 10*time.Minute || wait >= 10*time.Minute+rateLimitJitter {
+		t.Fatalf("wait = %s, want 10m plus up to %s jitter", wait, rateLimitJitter)
+	}
+}
@@ -1,26 +1,70 @@
 package main
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
-// Backend abstracts an AI command backend (Claude, Codex, etc.).
+// Backend abstracts an AI command backend (Claude, Codex, Gemini, etc.).
 type Backend interface {
 	// BuildCommand constructs the shell command string to execute.
 	BuildCommand(baseCmd, extraFlags, prompt string) string
+	// ResumeCommand constructs the shell command that continues an earlier
+	// session with a follow-up prompt.
+	ResumeCommand(baseCmd, extraFlags, sessionID, prompt string) string
 	// ProcessLine parses one line of JSON output from the backend.
-	// Returns text to stream to the terminal/log, and whether the session is complete.
-	ProcessLine(line string) (streamText string, sessionDone bool)
-	// RateLimitPhrases returns substrings that indicate rate limiting.
+	ProcessLine(line string) LineEvent
+	// RateLimitPhrases returns substrings that indicate rate limiting. They
+	// are matched against the errors the backend reports and stderr only.
 	RateLimitPhrases() []string
+	// RateLimitReset returns when a rate limit reported in the output resets,
+	// if the output says.
+	RateLimitReset(output string, now time.Time) (time.Time, bool)
+	// ClassifyFailure decides from the errors a failed session reported and
+	// its exit code whether to retry, stop the run or give up on the
+	// candidate.
+	ClassifyFailure(errors string, exitCode int) Failure
 	// DisplayName returns the backend name for UI messages.
 	DisplayName() string
 }
 
+// DirectBackend is a Backend that runs sessions itself instead of through an
+// agent CLI, such as an HTTP API. RunAICommand hands it the whole request.
+type DirectBackend interface {
+	Backend
+	// Check validates the agent and flags before the run, in place of
+	// looking up the agent command.
+	Check(agent, flags string) error
+	// Run runs one session.
+	Run(req AIRequest) (AIResult, error)
+}
+
+// LineEvent is the information a backend extracts from one line of output.
+type LineEvent struct {
+	Text      string      // Text to stream to the terminal/log
+	Done      bool        // Whether the session is complete
+	Error     string      // Error the CLI reported, as opposed to the model's text; checked for rate limits
+	SessionID string      // Session/conversation ID, when the line carries one
+	Usage     *Usage      // Token usage, when the line reports it
+	Tools     []ToolEvent // Tool calls that finished on this line
+}
+
 // NewBackend auto-detects the backend from the command name.
-// If baseCmd starts with "codex", returns the Codex backend; otherwise Claude.
+// If baseCmd starts with "codex" or "gemini", returns the Codex or Gemini
+// backend, and if it is an http(s) URL the OpenAI-compatible backend;
+// otherwise Claude.
 func NewBackend(baseCmd string) Backend {
+	if isHTTPAgent(baseCmd) {
+		return &OpenAIBackend{}
+	}
 	cmd := strings.Fields(baseCmd)
-	if len(cmd) > 0 && cmd[0] == "codex" {
-		return &CodexBackend{}
+	if len(cmd) > 0 {
+		switch cmd[0] {
+		case "codex":
+			return &CodexBackend{}
+		case "gemini":
+			return &GeminiBackend{}
+		}
 	}
 	return &ClaudeBackend{}
 }
@@ -3,12 +3,15 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // Claude stream event types
 type streamEvent struct {
-	Type  string                 `json:"type"`
-	Event map[string]interface{} `json:"event,omitempty"`
+	Type      string                 `json:"type"`
+	Event     map[string]interface{} `json:"event,omitempty"`
+	SessionID string                 `json:"session_id,omitempty"`
 }
 
 // contentBlockDelta represents the delta content in a Claude stream event
@@ -21,13 +24,39 @@ type contentBlockDelta struct {
 
 // resultEvent represents the final result event from Claude
 type resultEvent struct {
-	Type   string `json:"type"`
-	Result string `json:"result,omitempty"`
+	Type         string   `json:"type"`
+	Subtype      string   `json:"subtype,omitempty"`
+	IsError      bool     `json:"is_error,omitempty"`
+	Result       string   `json:"result,omitempty"`
+	TotalCostUSD *float64 `json:"total_cost_usd,omitempty"`
+	Usage        *struct {
+		InputTokens              int64 `json:"input_tokens"`
+		OutputTokens             int64 `json:"output_tokens"`
+		CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+		CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	} `json:"usage,omitempty"`
+}
+
+// claudeMessage is an assistant or user message in Claude's stream. Assistant
+// messages carry tool_use blocks and user messages their tool_result blocks.
+type claudeMessage struct {
+	Message struct {
+		Content []struct {
+			Type      string                 `json:"type"`
+			ID        string                 `json:"id"`
+			Name      string                 `json:"name"`
+			Input     map[string]interface{} `json:"input"`
+			ToolUseID string                 `json:"tool_use_id"`
+			IsError   bool                   `json:"is_error"`
+			Content   json.RawMessage        `json:"content"`
+		} `json:"content"`
+	} `json:"message"`
 }
 
 // ClaudeBackend implements Backend for the Claude CLI.
 type ClaudeBackend struct {
 	messageHasContent bool
+	tools             map[string]ToolEvent // tool_use blocks waiting for their result, by ID
 }
 
 func (b *ClaudeBackend) BuildCommand(baseCmd, extraFlags, prompt string) string {
@@ -42,12 +71,19 @@ func (b *ClaudeBackend) BuildCommand(baseCmd, extraFlags, prompt string) string
 		baseCmd, jsonFlags, delimiter, prompt, delimiter)
 }
 
-func (b *ClaudeBackend) ProcessLine(line string) (string, bool) {
+func (b *ClaudeBackend) ResumeCommand(baseCmd, extraFlags, sessionID, prompt string) string {
+	return b.BuildCommand(baseCmd, strings.TrimSpace("--resume "+shellQuote(sessionID)+" "+extraFlags), prompt)
+}
+
+func (b *ClaudeBackend) ProcessLine(line string) LineEvent {
 	var se streamEvent
 	if json.Unmarshal([]byte(line), &se) != nil {
-		return "", false
+		return LineEvent{}
 	}
 
+	// Every event carries the session ID, starting with the init event
+	ev := LineEvent{SessionID: se.SessionID}
+
 	switch se.Type {
 	case "stream_event":
 		if eventType, ok := se.Event["type"].(string); ok {
@@ -56,28 +92,153 @@ func (b *ClaudeBackend) ProcessLine(line string) (string, bool) {
 				var delta contentBlockDelta
 				if json.Unmarshal(eventJSON, &delta) == nil && delta.Delta.Type == "text_delta" && delta.Delta.Text != "" {
 					b.messageHasContent = true
-					return delta.Delta.Text, false
+					ev.Text = delta.Delta.Text
+					return ev
 				}
 			}
 			if eventType == "message_stop" {
 				if b.messageHasContent {
 					b.messageHasContent = false
-					return "\n", false
+					ev.Text = "\n"
+					return ev
 				}
 				b.messageHasContent = false
 			}
 		}
+	case "assistant", "user":
+		ev.Tools = b.processToolBlocks(line)
 	case "result":
-		return "", true
+		ev.Done = true
+		ev.Usage = parseClaudeUsage(line)
+		ev.Error = claudeResultError(line)
+	}
+
+	return ev
+}
+
+// processToolBlocks remembers the tool calls an assistant message starts and
+// returns those a user message reports results for.
+func (b *ClaudeBackend) processToolBlocks(line string) []ToolEvent {
+	var msg claudeMessage
+	if json.Unmarshal([]byte(line), &msg) != nil {
+		return nil
+	}
+
+	var finished []ToolEvent
+	for _, block := range msg.Message.Content {
+		switch block.Type {
+		case "tool_use":
+			if b.tools == nil {
+				b.tools = make(map[string]ToolEvent)
+			}
+			b.tools[block.ID] = claudeToolEvent(block.Name, block.Input)
+		case "tool_result":
+			tool, ok := b.tools[block.ToolUseID]
+			if !ok {
+				continue
+			}
+			delete(b.tools, block.ToolUseID)
+			finished = append(finished, finishClaudeTool(tool, block.IsError, claudeToolOutput(block.Content)))
+		}
+	}
+	return finished
+}
+
+// claudeToolOutput reads a tool_result's content, which is either a string or
+// a list of text blocks.
+func claudeToolOutput(content json.RawMessage) string {
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return text
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(content, &blocks) != nil {
+		return ""
+	}
+	var parts []string
+	for _, block := range blocks {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// claudeResultError returns the error a result event reports, such as a usage
+// limit, or "" if the session succeeded.
+func claudeResultError(line string) string {
+	var result resultEvent
+	if json.Unmarshal([]byte(line), &result) != nil {
+		return ""
+	}
+	if !result.IsError && !strings.HasPrefix(result.Subtype, "error") {
+		return ""
+	}
+	if result.Result != "" {
+		return result.Result
+	}
+	return result.Subtype
+}
+
+// parseClaudeUsage extracts the token usage and cost of a session from its
+// result event, or nil if the event reports neither.
+func parseClaudeUsage(line string) *Usage {
+	var result resultEvent
+	if json.Unmarshal([]byte(line), &result) != nil || (result.Usage == nil && result.TotalCostUSD == nil) {
+		return nil
 	}
 
-	return "", false
+	var usage Usage
+	if result.Usage != nil {
+		usage.InputTokens = result.Usage.InputTokens
+		usage.OutputTokens = result.Usage.OutputTokens
+		usage.CacheReadTokens = result.Usage.CacheReadInputTokens
+		usage.CacheWriteTokens = result.Usage.CacheCreationInputTokens
+	}
+	if result.TotalCostUSD != nil {
+		usage.CostUSD = *result.TotalCostUSD
+		usage.HasCost = true
+	}
+	return &usage
 }
 
 func (b *ClaudeBackend) RateLimitPhrases() []string {
 	return []string{"You've hit your limit"}
 }
 
+// RateLimitReset reads the reset time from Claude's limit message, e.g.
+// "resets 3pm (America/New_York)", or the older "limit reached|<unix time>".
+func (b *ClaudeBackend) RateLimitReset(output string, now time.Time) (time.Time, bool) {
+	if reset, ok := parseResetUnix(output); ok {
+		return reset, true
+	}
+	return parseResetClock(output, now)
+}
+
+// claudeFailures are Claude's errors that retrying will not fix.
+var claudeFailures = FailurePhrases{
+	Fatal: []string{
+		"Invalid API key",
+		"Please run /login",
+		"OAuth token has expired",
+		"authentication_error",
+		"permission_error",
+		"not_found_error",
+		"Credit balance is too low",
+	},
+	Candidate: []string{
+		"Prompt is too long",
+		"error_max_turns",
+	},
+}
+
+func (b *ClaudeBackend) ClassifyFailure(errors string, exitCode int) Failure {
+	return claudeFailures.Classify(errors, exitCode)
+}
+
 func (b *ClaudeBackend) DisplayName() string {
 	return "Claude"
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClaudeResumeCommandAddsResumeFlag(t *testing.T) {
+	cmd := (&ClaudeBackend{}).ResumeCommand("claude", "--model opus", "abc-123", "hello")
+	if !strings.Contains(cmd, "--verbose --resume 'abc-123' --model opus -p <<") {
+		t.Fatalf("ResumeCommand() = %q, want --resume before extra flags", cmd)
+	}
+}
+
+func TestClaudeProcessLineCapturesSessionID(t *testing.T) {
+	b := &ClaudeBackend{}
+
+	ev := b.ProcessLine(`{"type":"system","subtype":"init","session_id":"abc-123"}`)
+	if ev.SessionID != "abc-123" {
+		t.Fatalf("init SessionID = %q, want abc-123", ev.SessionID)
+	}
+
+	ev = b.ProcessLine(`{"type":"result","session_id":"abc-123"}`)
+	if !ev.Done || ev.SessionID != "abc-123" {
+		t.Fatalf("result event = %+v, want Done with session ID", ev)
+	}
+}
+
+func TestClaudeProcessLineReportsUsage(t *testing.T) {
+	ev := (&ClaudeBackend{}).ProcessLine(`{"type":"result","total_cost_usd":0.25,"usage":{"input_tokens":10,"output_tokens":20,"cache_read_input_tokens":300,"cache_creation_input_tokens":40}}`)
+	if ev.Usage == nil {
+		t.Fatal("expected usage on the result event")
+	}
+	want := Usage{InputTokens: 10, OutputTokens: 20, CacheReadTokens: 300, CacheWriteTokens: 40, CostUSD: 0.25, HasCost: true}
+	if *ev.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", *ev.Usage, want)
+	}
+
+	if ev := (&ClaudeBackend{}).ProcessLine(`{"type":"result"}`); ev.Usage != nil {
+		t.Errorf("Usage = %+v, want nil when the result has none", *ev.Usage)
+	}
+}
+
+func TestClaudeProcessLineReportsToolCalls(t *testing.T) {
+	b := &ClaudeBackend{}
+	ev := b.ProcessLine(`{"type":"assistant","message":{"content":[` +
+		`{"type":"text","text":"Building"},` +
+		`{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"make -j8"}},` +
+		`{"type":"tool_use","id":"t2","name":"Edit","input":{"file_path":"/repo/src/foo.c","old_string":"a","new_string":"b"}}]}}`)
+	if len(ev.Tools) != 0 {
+		t.Fatalf("Tools = %+v, want none until the results arrive", ev.Tools)
+	}
+
+	ev = b.ProcessLine(`{"type":"user","message":{"content":[` +
+		`{"type":"tool_result","tool_use_id":"t2","content":[{"type":"text","text":"updated"}]},` +
+		`{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"Exit code 2\nmake: *** [all] Error 2"}]}}`)
+	want := []ToolEvent{
+		{Name: "Edit", Target: "/repo/src/foo.c", Output: "updated"},
+		{Name: "Bash", Command: "make -j8", Failed: true, Exit: 2, Output: "Exit code 2\nmake: *** [all] Error 2"},
+	}
+	if !reflect.DeepEqual(ev.Tools, want) {
+		t.Errorf("Tools = %+v, want %+v", ev.Tools, want)
+	}
+	if len(b.tools) != 0 {
+		t.Errorf("pending tools = %v, want none", b.tools)
+	}
+}
@@ -4,24 +4,67 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // Codex JSONL event types
 type codexEvent struct {
-	Type string          `json:"type"`
-	Item json.RawMessage `json:"item,omitempty"`
+	Type     string          `json:"type"`
+	Item     json.RawMessage `json:"item,omitempty"`
+	ThreadID string          `json:"thread_id,omitempty"`
+	Usage    *codexUsage     `json:"usage,omitempty"`
+	Message  string          `json:"message,omitempty"` // error events
+	Error    *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"` // turn.failed events
+}
+
+// codexUsage is the token usage reported when a turn completes. Codex does not
+// report a cost, and its input count includes cached input.
+type codexUsage struct {
+	InputTokens       int64 `json:"input_tokens"`
+	CachedInputTokens int64 `json:"cached_input_tokens"`
+	OutputTokens      int64 `json:"output_tokens"`
 }
 
 type codexItem struct {
 	ID   string `json:"id"`
 	Type string `json:"type"`
 	Text string `json:"text,omitempty"`
+
+	// command_execution
+	Command          string `json:"command,omitempty"`
+	AggregatedOutput string `json:"aggregated_output,omitempty"`
+	ExitCode         *int   `json:"exit_code,omitempty"`
+	Status           string `json:"status,omitempty"`
+
+	// file_change
+	Changes []struct {
+		Path string `json:"path"`
+		Kind string `json:"kind"`
+	} `json:"changes,omitempty"`
+}
+
+// codexChangeNames names file_change kinds in tool summaries.
+var codexChangeNames = map[string]string{
+	"add":    "Add",
+	"delete": "Delete",
+	"update": "Edit",
 }
 
 // CodexBackend implements Backend for the OpenAI Codex CLI.
 type CodexBackend struct{}
 
 func (b *CodexBackend) BuildCommand(baseCmd, extraFlags, prompt string) string {
+	return b.buildCommand(baseCmd, extraFlags, "", prompt)
+}
+
+// ResumeCommand continues a thread with "codex exec resume <thread id>".
+func (b *CodexBackend) ResumeCommand(baseCmd, extraFlags, sessionID, prompt string) string {
+	return b.buildCommand(baseCmd, extraFlags, "resume "+shellQuote(sessionID)+" ", prompt)
+}
+
+func (b *CodexBackend) buildCommand(baseCmd, extraFlags, subcommand, prompt string) string {
 	const delimiter = "__NIGEL_PROMPT_EOF__"
 	cmd := strings.TrimSpace(baseCmd)
 	if cmd == "codex" {
@@ -35,11 +78,11 @@ func (b *CodexBackend) BuildCommand(baseCmd, extraFlags, prompt string) string {
 	// codex exec --json reads the prompt from stdin when using "-"
 	// Heredoc avoids shell quoting issues
 	if extraFlags != "" {
-		return fmt.Sprintf("%s --json %s - <<'%s'\n%s\n%s",
-			cmd, extraFlags, delimiter, prompt, delimiter)
+		return fmt.Sprintf("%s --json %s %s- <<'%s'\n%s\n%s",
+			cmd, extraFlags, subcommand, delimiter, prompt, delimiter)
 	}
-	return fmt.Sprintf("%s --json - <<'%s'\n%s\n%s",
-		cmd, delimiter, prompt, delimiter)
+	return fmt.Sprintf("%s --json %s- <<'%s'\n%s\n%s",
+		cmd, subcommand, delimiter, prompt, delimiter)
 }
 
 func hasFlag(s, flag string) bool {
@@ -51,27 +94,78 @@ func hasFlag(s, flag string) bool {
 	return false
 }
 
-func (b *CodexBackend) ProcessLine(line string) (string, bool) {
+func (b *CodexBackend) ProcessLine(line string) LineEvent {
 	var ev codexEvent
 	if json.Unmarshal([]byte(line), &ev) != nil {
-		return "", false
+		return LineEvent{}
 	}
 
 	switch ev.Type {
+	case "thread.started":
+		return LineEvent{SessionID: ev.ThreadID}
 	case "item.completed":
 		var item codexItem
-		if json.Unmarshal(ev.Item, &item) == nil && item.Type == "agent_message" && item.Text != "" {
-			return item.Text + "\n", false
+		if json.Unmarshal(ev.Item, &item) != nil {
+			break
+		}
+		switch item.Type {
+		case "agent_message":
+			if item.Text != "" {
+				return LineEvent{Text: item.Text + "\n"}
+			}
+		case "command_execution", "file_change":
+			return LineEvent{Tools: codexToolEvents(item)}
 		}
 	case "turn.completed":
-		return "", true
+		done := LineEvent{Done: true}
+		if u := ev.Usage; u != nil {
+			done.Usage = &Usage{
+				InputTokens:     u.InputTokens - u.CachedInputTokens,
+				OutputTokens:    u.OutputTokens,
+				CacheReadTokens: u.CachedInputTokens,
+			}
+		}
+		return done
 	case "turn.failed":
-		return "", true
+		failed := LineEvent{Done: true, Error: "turn failed"}
+		if ev.Error != nil && ev.Error.Message != "" {
+			failed.Error = ev.Error.Message
+		}
+		return failed
 	case "error":
-		return "", true
+		return LineEvent{Done: true, Error: ev.Message}
 	}
 
-	return "", false
+	return LineEvent{}
+}
+
+// codexToolEvents describes a finished command_execution or file_change item;
+// a file change touching several files gives one event per file.
+func codexToolEvents(item codexItem) []ToolEvent {
+	failed := item.Status == "failed" || item.Status == "declined"
+	if item.Type == "command_execution" {
+		tool := ToolEvent{
+			Name:    "Bash",
+			Command: unwrapShell(item.Command),
+			Failed:  failed,
+			Output:  item.AggregatedOutput,
+		}
+		if item.ExitCode != nil && *item.ExitCode != 0 {
+			tool.Exit = *item.ExitCode
+			tool.Failed = true
+		}
+		return []ToolEvent{tool}
+	}
+
+	var tools []ToolEvent
+	for _, change := range item.Changes {
+		name, ok := codexChangeNames[change.Kind]
+		if !ok {
+			name = "Edit"
+		}
+		tools = append(tools, ToolEvent{Name: name, Target: change.Path, Failed: failed})
+	}
+	return tools
 }
 
 func (b *CodexBackend) RateLimitPhrases() []string {
@@ -85,6 +179,31 @@ func (b *CodexBackend) RateLimitPhrases() []string {
 	}
 }
 
+// RateLimitReset reads retry hints from Codex's 429 errors, such as
+// "Retry-After: 30" or "try again in 1m20s".
+func (b *CodexBackend) RateLimitReset(output string, now time.Time) (time.Time, bool) {
+	return parseRetryAfter(output, now)
+}
+
+// codexFailures are Codex's errors that retrying will not fix.
+var codexFailures = FailurePhrases{
+	Fatal: []string{
+		"401 Unauthorized",
+		"invalid_api_key",
+		"Not logged in",
+		"model_not_found",
+		"does not exist or you do not have access",
+	},
+	Candidate: []string{
+		"context_length_exceeded",
+		"maximum context length",
+	},
+}
+
+func (b *CodexBackend) ClassifyFailure(errors string, exitCode int) Failure {
+	return codexFailures.Classify(errors, exitCode)
+}
+
 func (b *CodexBackend) DisplayName() string {
 	return "Codex"
 }
@@ -1,7 +1,10 @@
 package main
 
-import "strings"
-import "testing"
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestCodexBuildCommandUsesExecForBareCodex(t *testing.T) {
 	cmd := (&CodexBackend{}).BuildCommand("codex", "", "hello")
@@ -28,3 +31,64 @@ func TestCodexBuildCommandDoesNotDuplicateYolo(t *testing.T) {
 		t.Fatalf("BuildCommand() = %q, want extraFlags --yolo invocation preserved", cmd)
 	}
 }
+
+func TestCodexResumeCommandResumesThread(t *testing.T) {
+	cmd := (&CodexBackend{}).ResumeCommand("codex", "--sandbox read-only", "thread-1", "hello")
+	if !strings.HasPrefix(cmd, "codex exec --json --yolo --sandbox read-only resume 'thread-1' - <<") {
+		t.Fatalf("ResumeCommand() = %q, want codex exec resume invocation", cmd)
+	}
+}
+
+func TestCodexProcessLineCapturesThreadID(t *testing.T) {
+	ev := (&CodexBackend{}).ProcessLine(`{"type":"thread.started","thread_id":"thread-1"}`)
+	if ev.SessionID != "thread-1" {
+		t.Fatalf("SessionID = %q, want thread-1", ev.SessionID)
+	}
+	if ev.Text != "" || ev.Done {
+		t.Fatalf("ProcessLine() = %+v, want session ID only", ev)
+	}
+}
+
+func TestCodexProcessLineReportsUsage(t *testing.T) {
+	ev := (&CodexBackend{}).ProcessLine(`{"type":"turn.completed","usage":{"input_tokens":1000,"cached_input_tokens":800,"output_tokens":50}}`)
+	if !ev.Done || ev.Usage == nil {
+		t.Fatalf("ProcessLine() = %+v, want usage on turn.completed", ev)
+	}
+	want := Usage{InputTokens: 200, OutputTokens: 50, CacheReadTokens: 800}
+	if *ev.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", *ev.Usage, want)
+	}
+}
+
+func TestCodexProcessLineReportsToolCalls(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []ToolEvent
+	}{
+		{
+			name: "failed command",
+			line: `{"type":"item.completed","item":{"id":"i1","type":"command_execution","command":"bash -lc 'make -j8'","aggregated_output":"Error 2\n","exit_code":2,"status":"failed"}}`,
+			want: []ToolEvent{{Name: "Bash", Command: "make -j8", Failed: true, Exit: 2, Output: "Error 2\n"}},
+		},
+		{
+			name: "command",
+			line: `{"type":"item.completed","item":{"id":"i2","type":"command_execution","command":"ls","aggregated_output":"a.go\n","exit_code":0,"status":"completed"}}`,
+			want: []ToolEvent{{Name: "Bash", Command: "ls", Output: "a.go\n"}},
+		},
+		{
+			name: "file change",
+			line: `{"type":"item.completed","item":{"id":"i3","type":"file_change","changes":[{"path":"/repo/a.go","kind":"update"},{"path":"/repo/b.go","kind":"add"}],"status":"completed"}}`,
+			want: []ToolEvent{{Name: "Edit", Target: "/repo/a.go"}, {Name: "Add", Target: "/repo/b.go"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := (&CodexBackend{}).ProcessLine(tt.line)
+			if !reflect.DeepEqual(ev.Tools, tt.want) {
+				t.Errorf("Tools = %+v, want %+v", ev.Tools, tt.want)
+			}
+		})
+	}
+}
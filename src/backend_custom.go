@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackendSpec declares a backend in the `backends:` section of config.yaml, for
+// agent CLIs Nigel has no built-in support for. Events are picked out of the
+// CLI's JSON output with dotted paths such as "delta.text" or "choices.0.text",
+// and conditions of the form "path" (present and not empty/false) or
+// "path == value".
+type BackendSpec struct {
+	DisplayName     string   `yaml:"display_name"`     // Name shown in the UI (default: the backend's name)
+	Command         string   `yaml:"command"`          // $AGENT, $FLAGS and $PROMPT; without $PROMPT the prompt is sent on stdin
+	ResumeCommand   string   `yaml:"resume_command"`   // As command, plus $SESSION_ID (default: start a new session)
+	Text            string   `yaml:"text"`             // Path to text to stream (default: stream every line as plain text)
+	TextNewline     bool     `yaml:"text_newline"`     // Each text value is a whole message; end it with a newline
+	Done            string   `yaml:"done"`             // Condition for the event that ends the session
+	Error           string   `yaml:"error"`            // Condition for an error event, which also ends the session
+	ErrorMessage    string   `yaml:"error_message"`    // Path to the error text (default: the whole line)
+	SessionID       string   `yaml:"session_id"`       // Path to the session ID, for resuming
+	RateLimit       []string `yaml:"rate_limit"`       // Phrases that mean the agent is rate limited; /.../ for regexes
+	FatalErrors     []string `yaml:"fatal_errors"`     // Phrases for errors that stop the run, such as an expired login
+	CandidateErrors []string `yaml:"candidate_errors"` // Phrases for errors that give up on the candidate, such as a context overflow
+}
+
+// builtinBackends are the backends implemented in Go.
+var builtinBackends = map[string]func() Backend{
+	"claude": func() Backend { return &ClaudeBackend{} },
+	"codex":  func() Backend { return &CodexBackend{} },
+	"gemini": func() Backend { return &GeminiBackend{} },
+	"openai": func() Backend { return &OpenAIBackend{} },
+}
+
+// validate checks a backend declared in config.yaml.
+func (s BackendSpec) validate(name string) error {
+	if _, ok := builtinBackends[name]; ok {
+		return fmt.Errorf("backend %s is built in and cannot be redefined", name)
+	}
+	for _, cond := range []struct{ key, value string }{{"done", s.Done}, {"error", s.Error}} {
+		if cond.value != "" {
+			if path, _, _ := parseCondition(cond.value); path == "" {
+				return fmt.Errorf("backend %s has invalid '%s' %q", name, cond.key, cond.value)
+			}
+		}
+	}
+	for _, phrases := range []struct {
+		key  string
+		list []string
+	}{{"rate_limit", s.RateLimit}, {"fatal_errors", s.FatalErrors}, {"candidate_errors", s.CandidateErrors}} {
+		for _, phrase := range phrases.list {
+			if pattern, ok := rateLimitPattern(phrase); ok {
+				if _, err := regexp.Compile(pattern); err != nil {
+					return fmt.Errorf("backend %s has invalid '%s' regex %q: %w", name, phrases.key, phrase, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// newBackend returns the backend called name, or detects it from the agent
+// command when name is empty.
+func newBackend(name, agent string, declared map[string]BackendSpec) Backend {
+	if builtin, ok := builtinBackends[name]; ok {
+		return builtin()
+	}
+	if spec, ok := declared[name]; ok {
+		return &CustomBackend{name: name, spec: spec}
+	}
+	return NewBackend(agent)
+}
+
+// validateBackendNames checks that every backend a task and config.yaml name
+// is built in or declared under `backends:`.
+func validateBackendNames(config Config, task Task) error {
+	names := []string{config.Backend, task.Backend}
+	for _, spec := range append(append([]AgentSpec{}, config.Agents...), task.Agents...) {
+		names = append(names, spec.Backend)
+	}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if _, ok := builtinBackends[name]; ok {
+			continue
+		}
+		if _, ok := config.Backends[name]; !ok {
+			return fmt.Errorf("unknown backend %q (declare it under 'backends:' in config.yaml)", name)
+		}
+	}
+	return nil
+}
+
+// CustomBackend implements Backend from a BackendSpec.
+type CustomBackend struct {
+	name string
+	spec BackendSpec
+}
+
+func (b *CustomBackend) BuildCommand(baseCmd, extraFlags, prompt string) string {
+	template := b.spec.Command
+	if template == "" {
+		template = "$AGENT $FLAGS"
+	}
+	return b.command(template, baseCmd, extraFlags, "", prompt)
+}
+
+func (b *CustomBackend) ResumeCommand(baseCmd, extraFlags, sessionID, prompt string) string {
+	if b.spec.ResumeCommand == "" {
+		return b.BuildCommand(baseCmd, extraFlags, prompt)
+	}
+	return b.command(b.spec.ResumeCommand, baseCmd, extraFlags, sessionID, prompt)
+}
+
+// command interpolates a command template. Without $PROMPT the prompt is sent
+// on stdin through a heredoc, like the built-in backends do.
+func (b *CustomBackend) command(template, baseCmd, extraFlags, sessionID, prompt string) string {
+	const delimiter = "__NIGEL_PROMPT_EOF__"
+	if extraFlags == "" {
+		template = strings.ReplaceAll(template, " $FLAGS", "")
+	}
+	cmd := strings.NewReplacer(
+		"$AGENT", baseCmd,
+		"$FLAGS", extraFlags,
+		"$SESSION_ID", shellQuote(sessionID),
+		"$PROMPT", shellQuote(prompt),
+	).Replace(template)
+	cmd = strings.TrimSpace(cmd)
+	if strings.Contains(template, "$PROMPT") {
+		return cmd
+	}
+	return fmt.Sprintf("%s <<'%s'\n%s\n%s", cmd, delimiter, prompt, delimiter)
+}
+
+func (b *CustomBackend) ProcessLine(line string) LineEvent {
+	if b.spec.Text == "" && b.spec.Done == "" && b.spec.Error == "" && b.spec.SessionID == "" {
+		// Plain text output
+		return LineEvent{Text: line + "\n"}
+	}
+
+	var event interface{}
+	if json.Unmarshal([]byte(line), &event) != nil {
+		if b.spec.Text == "" {
+			return LineEvent{Text: line + "\n"}
+		}
+		return LineEvent{}
+	}
+
+	var ev LineEvent
+	if id, ok := jsonPath(event, b.spec.SessionID).(string); ok && b.spec.SessionID != "" {
+		ev.SessionID = id
+	}
+	if b.spec.Error != "" && matchCondition(event, b.spec.Error) {
+		message := line
+		if text, ok := jsonPath(event, b.spec.ErrorMessage).(string); ok && b.spec.ErrorMessage != "" {
+			message = text
+		}
+		ev.Text = "Error: " + message + "\n"
+		ev.Error = message
+		ev.Done = true
+		return ev
+	}
+	if b.spec.Text != "" {
+		if text, ok := jsonPath(event, b.spec.Text).(string); ok && text != "" {
+			ev.Text = text
+			if b.spec.TextNewline {
+				ev.Text += "\n"
+			}
+		}
+	}
+	ev.Done = b.spec.Done != "" && matchCondition(event, b.spec.Done)
+	return ev
+}
+
+// ClassifyFailure uses the backend's fatal_errors and candidate_errors.
+func (b *CustomBackend) ClassifyFailure(errors string, exitCode int) Failure {
+	return FailurePhrases{Fatal: b.spec.FatalErrors, Candidate: b.spec.CandidateErrors}.Classify(errors, exitCode)
+}
+
+func (b *CustomBackend) RateLimitPhrases() []string {
+	return b.spec.RateLimit
+}
+
+// RateLimitReset looks for any of the reset hints the built-in backends
+// understand.
+func (b *CustomBackend) RateLimitReset(output string, now time.Time) (time.Time, bool) {
+	if reset, ok := parseResetUnix(output); ok {
+		return reset, true
+	}
+	if reset, ok := parseResetClock(output, now); ok {
+		return reset, true
+	}
+	return parseRetryAfter(output, now)
+}
+
+func (b *CustomBackend) DisplayName() string {
+	if b.spec.DisplayName != "" {
+		return b.spec.DisplayName
+	}
+	return b.name
+}
+
+// jsonPath follows a dotted path of object keys and array indexes through a
+// decoded JSON value. Returns nil when the path doesn't exist.
+func jsonPath(value interface{}, path string) interface{} {
+	if path == "" {
+		return value
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			value = v[i]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+// parseCondition splits "path == value" (or "path=value") into its parts.
+// A bare "path" has no value.
+func parseCondition(cond string) (path, value string, hasValue bool) {
+	path, value, hasValue = strings.Cut(cond, "=")
+	path = strings.TrimSpace(path)
+	value = strings.Trim(strings.TrimSpace(strings.TrimPrefix(value, "=")), `"'`)
+	return path, value, hasValue
+}
+
+// matchCondition reports whether a decoded JSON event meets cond.
+func matchCondition(event interface{}, cond string) bool {
+	path, want, hasValue := parseCondition(cond)
+	value := jsonPath(event, path)
+	if hasValue {
+		return value != nil && fmt.Sprint(value) == want
+	}
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+// rateLimitPattern returns the regex in a /.../ rate limit phrase.
+func rateLimitPattern(phrase string) (string, bool) {
+	if len(phrase) > 2 && strings.HasPrefix(phrase, "/") && strings.HasSuffix(phrase, "/") {
+		return phrase[1 : len(phrase)-1], true
+	}
+	return "", false
+}
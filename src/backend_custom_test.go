@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var aiderSpec = BackendSpec{
+	DisplayName:   "Aider",
+	Command:       "$AGENT $FLAGS --message $PROMPT",
+	ResumeCommand: "$AGENT $FLAGS --session $SESSION_ID --message $PROMPT",
+	Text:          "delta.text",
+	Done:          "type == done",
+	Error:         "type == error",
+	ErrorMessage:  "error.message",
+	SessionID:     "session",
+	RateLimit:     []string{"Rate limit reached", `/retry in \d+s/`},
+}
+
+func TestCustomBuildCommandInterpolatesPrompt(t *testing.T) {
+	b := &CustomBackend{name: "aider", spec: aiderSpec}
+
+	cmd := b.BuildCommand("aider", "--yes", "fix 'it'")
+	if want := `aider --yes --message 'fix '"'"'it'"'"''`; cmd != want {
+		t.Errorf("BuildCommand() = %q, want %q", cmd, want)
+	}
+	cmd = b.ResumeCommand("aider", "", "s-1", "again")
+	if want := "aider --session 's-1' --message 'again'"; cmd != want {
+		t.Errorf("ResumeCommand() = %q, want %q", cmd, want)
+	}
+}
+
+func TestCustomBuildCommandSendsPromptOnStdin(t *testing.T) {
+	b := &CustomBackend{name: "plain"}
+
+	cmd := b.BuildCommand("mytool run", "--fast", "hello")
+	if want := "mytool run --fast <<'__NIGEL_PROMPT_EOF__'\nhello\n__NIGEL_PROMPT_EOF__"; cmd != want {
+		t.Errorf("BuildCommand() = %q, want %q", cmd, want)
+	}
+	// Without a resume command every session starts afresh
+	if resume := b.ResumeCommand("mytool run", "--fast", "s-1", "hello"); resume != cmd {
+		t.Errorf("ResumeCommand() = %q, want %q", resume, cmd)
+	}
+}
+
+func TestCustomProcessLine(t *testing.T) {
+	tests := []struct {
+		name string
+		spec BackendSpec
+		line string
+		want LineEvent
+	}{
+		{"text delta", aiderSpec, `{"type":"delta","delta":{"text":"Hello"}}`, LineEvent{Text: "Hello"}},
+		{"session ID", aiderSpec, `{"type":"start","session":"s-1"}`, LineEvent{SessionID: "s-1"}},
+		{"done", aiderSpec, `{"type":"done"}`, LineEvent{Done: true}},
+		{"error", aiderSpec, `{"type":"error","error":{"message":"Rate limit reached"}}`, LineEvent{Text: "Error: Rate limit reached\n", Done: true}},
+		{"not JSON", aiderSpec, "Loading...", LineEvent{}},
+		{"plain text", BackendSpec{}, "Loading...", LineEvent{Text: "Loading...\n"}},
+		{"array index", BackendSpec{Text: "choices.0.text", TextNewline: true}, `{"choices":[{"text":"Hi"}]}`, LineEvent{Text: "Hi\n"}},
+		{"truthy done", BackendSpec{Done: "finished"}, `{"finished":true}`, LineEvent{Done: true}},
+		{"falsy done", BackendSpec{Done: "finished"}, `{"finished":false}`, LineEvent{}},
+		{"numeric condition", BackendSpec{Done: "code == 0"}, `{"code":0}`, LineEvent{Done: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := (&CustomBackend{name: "custom", spec: tt.spec}).ProcessLine(tt.line)
+			if got.Text != tt.want.Text || got.Done != tt.want.Done || got.SessionID != tt.want.SessionID {
+				t.Errorf("ProcessLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomRateLimitRegex(t *testing.T) {
+	b := &CustomBackend{name: "aider", spec: aiderSpec}
+	output := "Error: too many requests, retry in 30s please\n"
+
+	rl, ok := findRateLimitMatch(output, b.RateLimitPhrases())
+	if !ok {
+		t.Fatal("findRateLimitMatch() found no match for the regex phrase")
+	}
+	if rl.phrase != "retry in 30s" {
+		t.Errorf("phrase = %q, want the matched text", rl.phrase)
+	}
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	if reset, ok := b.RateLimitReset("Retry-After: 120", now); !ok || !reset.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("RateLimitReset() = %v, %v, want %v", reset, ok, now.Add(2*time.Minute))
+	}
+}
+
+func TestCustomBackendDisplayName(t *testing.T) {
+	if got := (&CustomBackend{name: "aider", spec: aiderSpec}).DisplayName(); got != "Aider" {
+		t.Errorf("DisplayName() = %q, want Aider", got)
+	}
+	if got := (&CustomBackend{name: "mytool"}).DisplayName(); got != "mytool" {
+		t.Errorf("DisplayName() = %q, want mytool", got)
+	}
+}
+
+func TestResolveAgentsBackends(t *testing.T) {
+	config := Config{
+		Agents: []AgentSpec{
+			{Agent: "claude"},
+			{Agent: "/opt/bin/aider", Backend: "aider"},
+			{Agent: "wrapped-claude"},
+		},
+		Backends: map[string]BackendSpec{"aider": aiderSpec},
+	}
+
+	r := &Runner{env: &Environment{Config: config}}
+	got := agentChainString(r.resolveAgents())
+	if want := "Claude → Aider → Claude"; got != want {
+		t.Errorf("chain = %q, want %q", got, want)
+	}
+
+	// A task-level backend applies to agents that don't name their own
+	r = &Runner{env: &Environment{Config: config}, task: Task{Backend: "codex"}}
+	got = agentChainString(r.resolveAgents())
+	if want := "Codex → Aider → Codex"; got != want {
+		t.Errorf("chain = %q, want %q", got, want)
+	}
+}
+
+func TestNewRunnerRejectsUnknownBackend(t *testing.T) {
+	env := &Environment{
+		ProjectDir: t.TempDir(),
+		Tasks:      map[string]Task{"t": {Name: "t", Dir: t.TempDir(), Backend: "aider"}},
+	}
+	_, err := NewRunner(env, "t", RunnerOptions{DryRun: true})
+	if err == nil || !strings.Contains(err.Error(), `unknown backend "aider"`) {
+		t.Fatalf("NewRunner() error = %v, want unknown backend", err)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Gemini stream-json event types
+type geminiEvent struct {
+	Type       string                 `json:"type"`
+	SessionID  string                 `json:"session_id,omitempty"`
+	Role       string                 `json:"role,omitempty"`
+	Content    string                 `json:"content,omitempty"`
+	ToolName   string                 `json:"tool_name,omitempty"`
+	ToolID     string                 `json:"tool_id,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Status     string                 `json:"status,omitempty"`
+	Output     string                 `json:"output,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+	Error      *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+	Stats *geminiStats `json:"stats,omitempty"`
+}
+
+// geminiStats is the token usage reported with the result event. Gemini does
+// not report a cost, and its input count includes cached input.
+type geminiStats struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	Cached       int64 `json:"cached"`
+}
+
+// geminiToolNames maps Gemini's tool names to the names Claude uses, so tool
+// summaries read the same whichever agent made them.
+var geminiToolNames = map[string]string{
+	"read_file":           "Read",
+	"read_many_files":     "Read",
+	"write_file":          "Write",
+	"replace":             "Edit",
+	"run_shell_command":   "Bash",
+	"glob":                "Glob",
+	"search_file_content": "Grep",
+	"list_directory":      "LS",
+	"web_fetch":           "WebFetch",
+	"google_web_search":   "WebSearch",
+}
+
+// GeminiBackend implements Backend for the Gemini CLI.
+type GeminiBackend struct {
+	messageHasContent bool
+	tools             map[string]ToolEvent // tool_use events waiting for their result, by ID
+}
+
+func (b *GeminiBackend) BuildCommand(baseCmd, extraFlags, prompt string) string {
+	const delimiter = "__NIGEL_PROMPT_EOF__"
+	jsonFlags := "--output-format stream-json"
+
+	// Without --yolo, tools that need approval are unavailable headless
+	if !hasFlag(baseCmd, "--yolo") && !hasFlag(extraFlags, "--yolo") && !strings.Contains(baseCmd+" "+extraFlags, "--approval-mode") {
+		jsonFlags += " --yolo"
+	}
+
+	// gemini runs headless and reads the prompt from stdin when it isn't a
+	// terminal. Heredoc avoids shell quoting issues
+	if extraFlags != "" {
+		return fmt.Sprintf("%s %s %s <<'%s'\n%s\n%s",
+			baseCmd, jsonFlags, extraFlags, delimiter, prompt, delimiter)
+	}
+	return fmt.Sprintf("%s %s <<'%s'\n%s\n%s",
+		baseCmd, jsonFlags, delimiter, prompt, delimiter)
+}
+
+// ResumeCommand continues a session with "--resume <session id>".
+func (b *GeminiBackend) ResumeCommand(baseCmd, extraFlags, sessionID, prompt string) string {
+	return b.BuildCommand(baseCmd, strings.TrimSpace("--resume "+shellQuote(sessionID)+" "+extraFlags), prompt)
+}
+
+func (b *GeminiBackend) ProcessLine(line string) LineEvent {
+	var ge geminiEvent
+	if json.Unmarshal([]byte(line), &ge) != nil {
+		return LineEvent{}
+	}
+
+	var ev LineEvent
+	if ge.Type == "message" {
+		if ge.Role == "assistant" && ge.Content != "" {
+			b.messageHasContent = true
+			ev.Text = ge.Content
+		}
+		return ev
+	}
+
+	// Anything other than a message ends the current assistant message
+	if b.messageHasContent {
+		b.messageHasContent = false
+		ev.Text = "\n"
+	}
+
+	switch ge.Type {
+	case "init":
+		ev.SessionID = ge.SessionID
+	case "tool_use":
+		if b.tools == nil {
+			b.tools = make(map[string]ToolEvent)
+		}
+		b.tools[ge.ToolID] = geminiToolEvent(ge.ToolName, ge.Parameters)
+	case "tool_result":
+		tool, ok := b.tools[ge.ToolID]
+		if !ok {
+			break
+		}
+		delete(b.tools, ge.ToolID)
+		output := ge.Output
+		if ge.Error != nil && ge.Error.Message != "" {
+			output = ge.Error.Message
+		}
+		ev.Tools = []ToolEvent{finishGeminiTool(tool, ge.Status == "error", output)}
+	case "error":
+		if ge.Message != "" {
+			ev.Text += "Error: " + ge.Message + "\n"
+			ev.Error = ge.Message
+		}
+	case "result":
+		ev.Done = true
+		if ge.Status == "error" && ge.Error != nil && ge.Error.Message != "" {
+			ev.Text += "Error: " + ge.Error.Message + "\n"
+			ev.Error = ge.Error.Message
+		}
+		if s := ge.Stats; s != nil {
+			ev.Usage = &Usage{
+				InputTokens:     s.InputTokens - s.Cached,
+				OutputTokens:    s.OutputTokens,
+				CacheReadTokens: s.Cached,
+			}
+		}
+	}
+
+	return ev
+}
+
+// geminiToolEvent describes a Gemini tool_use event.
+func geminiToolEvent(name string, params map[string]interface{}) ToolEvent {
+	if claudeName, ok := geminiToolNames[name]; ok {
+		name = claudeName
+	}
+	tool := claudeToolEvent(name, params)
+	if tool.Command == "" && tool.Target == "" {
+		if path, ok := params["absolute_path"].(string); ok {
+			tool.Target = path
+		}
+	}
+	return tool
+}
+
+// geminiExitRe matches the exit status Gemini reports for shell commands.
+var geminiExitRe = regexp.MustCompile(`(?m)^Exit Code: (\d+)`)
+
+// finishGeminiTool fills in a tool call's result from its tool_result event.
+func finishGeminiTool(tool ToolEvent, isError bool, output string) ToolEvent {
+	tool.Failed = isError
+	tool.Output = output
+	if m := geminiExitRe.FindStringSubmatch(output); m != nil {
+		tool.Exit, _ = strconv.Atoi(m[1])
+		tool.Failed = tool.Failed || tool.Exit != 0
+	}
+	return tool
+}
+
+func (b *GeminiBackend) RateLimitPhrases() []string {
+	return []string{
+		"RESOURCE_EXHAUSTED",
+		"Quota exceeded",
+		"rateLimitExceeded",
+		"status 429",
+		"429 Too Many Requests",
+	}
+}
+
+// geminiRetryRe matches the retry hints in Gemini's quota errors, e.g.
+// "Please retry in 45.2s" or "retryDelay": "45s".
+var geminiRetryRe = regexp.MustCompile(`(?i)(?:retry in|"retryDelay":\s*")\s*(\d+(?:\.\d+)?)s`)
+
+// RateLimitReset reads the retry delay from Gemini's quota errors, falling
+// back to the generic "Retry-After" and "try again in" hints.
+func (b *GeminiBackend) RateLimitReset(output string, now time.Time) (time.Time, bool) {
+	if m := geminiRetryRe.FindStringSubmatch(output); m != nil {
+		seconds, err := strconv.ParseFloat(m[1], 64)
+		if err == nil && seconds > 0 {
+			return now.Add(time.Duration(seconds * float64(time.Second))), true
+		}
+	}
+	return parseRetryAfter(output, now)
+}
+
+// geminiFailures are Gemini's errors that retrying will not fix.
+var geminiFailures = FailurePhrases{
+	Fatal: []string{
+		"API key not valid",
+		"UNAUTHENTICATED",
+		"PERMISSION_DENIED",
+		"NOT_FOUND",
+		"Please set an Auth method",
+	},
+	Candidate: []string{
+		"exceeds the maximum number of tokens",
+		"Maximum session turns exceeded",
+	},
+}
+
+func (b *GeminiBackend) ClassifyFailure(errors string, exitCode int) Failure {
+	return geminiFailures.Classify(errors, exitCode)
+}
+
+func (b *GeminiBackend) DisplayName() string {
+	return "Gemini"
+}
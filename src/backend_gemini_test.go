@@ -0,0 +1,151 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewBackendDetectsGemini(t *testing.T) {
+	if got := NewBackend("gemini --model gemini-2.5-pro").DisplayName(); got != "Gemini" {
+		t.Errorf("NewBackend(gemini) = %s, want Gemini", got)
+	}
+}
+
+func TestGeminiBuildCommandAddsYolo(t *testing.T) {
+	cmd := (&GeminiBackend{}).BuildCommand("gemini", "", "hello")
+	if want := "gemini --output-format stream-json --yolo <<'__NIGEL_PROMPT_EOF__'\nhello\n__NIGEL_PROMPT_EOF__"; cmd != want {
+		t.Fatalf("BuildCommand() = %q, want %q", cmd, want)
+	}
+
+	cmd = (&GeminiBackend{}).BuildCommand("gemini", "--approval-mode auto_edit", "hello")
+	if !strings.HasPrefix(cmd, "gemini --output-format stream-json --approval-mode auto_edit <<") {
+		t.Fatalf("BuildCommand() = %q, want the approval mode preserved", cmd)
+	}
+}
+
+func TestGeminiResumeCommandResumesSession(t *testing.T) {
+	cmd := (&GeminiBackend{}).ResumeCommand("gemini", "-m flash", "s-1", "hello")
+	if !strings.HasPrefix(cmd, "gemini --output-format stream-json --yolo --resume 's-1' -m flash <<") {
+		t.Fatalf("ResumeCommand() = %q, want --resume", cmd)
+	}
+}
+
+func TestGeminiProcessLine(t *testing.T) {
+	b := &GeminiBackend{}
+	lines := []struct {
+		line string
+		want LineEvent
+	}{
+		{`{"type":"init","session_id":"s-1","model":"gemini-2.5-pro"}`, LineEvent{SessionID: "s-1"}},
+		{`{"type":"message","role":"user","content":"Fix it"}`, LineEvent{}},
+		{`{"type":"message","role":"assistant","content":"Looking","delta":true}`, LineEvent{Text: "Looking"}},
+		{`{"type":"message","role":"assistant","content":" now.","delta":true}`, LineEvent{Text: " now."}},
+		{`{"type":"tool_use","tool_name":"replace","tool_id":"t1","parameters":{"file_path":"/p/a.go"}}`, LineEvent{Text: "\n"}},
+		{`{"type":"tool_result","tool_id":"t1","status":"success","output":"ok"}`, LineEvent{Tools: []ToolEvent{{Name: "Edit", Target: "/p/a.go", Output: "ok"}}}},
+		{`{"type":"tool_use","tool_name":"run_shell_command","tool_id":"t2","parameters":{"command":"make"}}`, LineEvent{}},
+		{`{"type":"tool_result","tool_id":"t2","status":"success","output":"Exit Code: 2"}`, LineEvent{Tools: []ToolEvent{{Name: "Bash", Command: "make", Failed: true, Exit: 2, Output: "Exit Code: 2"}}}},
+		{`{"type":"result","status":"success","stats":{"input_tokens":120,"output_tokens":30,"cached":20}}`, LineEvent{Done: true, Usage: &Usage{InputTokens: 100, OutputTokens: 30, CacheReadTokens: 20}}},
+		{"not json", LineEvent{}},
+	}
+
+	for _, tt := range lines {
+		if got := b.ProcessLine(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ProcessLine(%s) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestGeminiProcessLineReportsErrors(t *testing.T) {
+	b := &GeminiBackend{}
+	ev := b.ProcessLine(`{"type":"error","severity":"error","message":"[429] RESOURCE_EXHAUSTED"}`)
+	if ev.Text != "Error: [429] RESOURCE_EXHAUSTED\n" || ev.Done {
+		t.Errorf("error event = %+v, want its message", ev)
+	}
+	ev = b.ProcessLine(`{"type":"result","status":"error","error":{"type":"FatalApiError","message":"Quota exceeded"}}`)
+	if ev.Text != "Error: Quota exceeded\n" || !ev.Done {
+		t.Errorf("error result = %+v, want its message and done", ev)
+	}
+}
+
+func TestGeminiRateLimitReset(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		output string
+		want   time.Duration
+		ok     bool
+	}{
+		{"Quota exceeded. Please retry in 42.5s.", 42500 * time.Millisecond, true},
+		{`"retryDelay": "30s"`, 30 * time.Second, true},
+		{"Retry-After: 60", time.Minute, true},
+		{"Quota exceeded", 0, false},
+	}
+
+	for _, tt := range tests {
+		reset, ok := (&GeminiBackend{}).RateLimitReset(tt.output, now)
+		if ok != tt.ok || (ok && !reset.Equal(now.Add(tt.want))) {
+			t.Errorf("RateLimitReset(%q) = %v, %v, want %v, %v", tt.output, reset, ok, now.Add(tt.want), tt.ok)
+		}
+	}
+}
+
+func TestGeminiWithMockAgent(t *testing.T) {
+	mock, err := filepath.Abs("../test-environment/mock-gemini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	var tools []ToolEvent
+	var text strings.Builder
+	result, err := RunAICommand(AIRequest{
+		Backend:  &GeminiBackend{},
+		BaseCmd:  mock,
+		Prompt:   "Fix the issue: item-1",
+		WorkDir:  dir,
+		ExtraEnv: []string{"MOCK_GEMINI_DELAY=0", "MOCK_GEMINI_FIX=1"},
+		StreamCb: func(s string) { text.WriteString(s) },
+		ToolCb:   func(tool ToolEvent) { tools = append(tools, tool) },
+	})
+	if err != nil {
+		t.Fatalf("RunAICommand() error = %v", err)
+	}
+
+	if result.SessionID != "mock-gemini-session" {
+		t.Errorf("SessionID = %q, want mock-gemini-session", result.SessionID)
+	}
+	if !strings.Contains(text.String(), "[mock-gemini] Looking into 'item-1'.\n") {
+		t.Errorf("streamed text = %q, want the agent's message", text.String())
+	}
+	if len(tools) != 1 || tools[0].Summary(dir) != "▶ Bash: ls -a" {
+		t.Errorf("tools = %+v, want one ls -a call", tools)
+	}
+	if want := (Usage{InputTokens: 100, OutputTokens: 30, CacheReadTokens: 20}); result.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", result.Usage, want)
+	}
+}
+
+func TestGeminiMockAgentRateLimit(t *testing.T) {
+	mock, err := filepath.Abs("../test-environment/mock-gemini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &GeminiBackend{}
+	result, _ := RunAICommand(AIRequest{
+		Backend:  b,
+		BaseCmd:  mock,
+		Prompt:   "Fix the issue: item-1",
+		WorkDir:  t.TempDir(),
+		ExtraEnv: []string{"MOCK_GEMINI_RATE_LIMIT=1"},
+	})
+
+	if _, ok := findRateLimitMatch(result.Errors, b.RateLimitPhrases()); !ok {
+		t.Fatalf("no rate limit phrase in errors %q", result.Errors)
+	}
+	now := time.Now()
+	if reset, ok := b.RateLimitReset(result.Errors, now); !ok || !reset.Equal(now.Add(42500*time.Millisecond)) {
+		t.Errorf("RateLimitReset() = %v, %v, want 42.5s from now", reset, ok)
+	}
+}
@@ -0,0 +1,610 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// OpenAIBackend implements Backend for models served behind an
+// OpenAI-compatible /v1/chat/completions endpoint. There is no CLI: the agent
+// is the endpoint's base URL, and Nigel runs the tool loop itself, giving the
+// model tools to read and write files and run shell commands in the project.
+type OpenAIBackend struct {
+	calls []openAIToolCall // Tool calls streamed in the current response, by index
+}
+
+// openAIOptions are the agent_flags an OpenAI-compatible agent understands.
+type openAIOptions struct {
+	model     string // --model
+	maxTurns  int    // --max-turns: requests per session before giving up
+	apiKeyEnv string // --api-key-env: variable holding the API key
+}
+
+const (
+	openAIDefaultMaxTurns = 50
+	openAIMaxToolOutput   = 20000 // Longest tool result sent back to the model
+)
+
+// openAISystemPrompt tells the model how to work; the task prompt follows as
+// the user message.
+const openAISystemPrompt = "You are a coding agent working in a software project. " +
+	"Use the tools to read and change files and to run shell commands; paths are relative to the project root. " +
+	"Make the changes the user asks for, check them, then reply with a short summary."
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+type openAIRequest struct {
+	Model         string          `json:"model,omitempty"`
+	Messages      []openAIMessage `json:"messages"`
+	Tools         []openAITool    `json:"tools"`
+	Stream        bool            `json:"stream"`
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+// openAIChunk is one server-sent event of a streamed chat completion.
+type openAIChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens        int64 `json:"prompt_tokens"`
+		CompletionTokens    int64 `json:"completion_tokens"`
+		PromptTokensDetails *struct {
+			CachedTokens int64 `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// openAITools are the tools offered to the model, with the names their calls
+// are shown under.
+var openAITools = []struct {
+	name, display, description, parameters string
+}{
+	{"read_file", "Read", "Read a file in the project.",
+		`{"type":"object","properties":{"path":{"type":"string","description":"Path relative to the project root"}},"required":["path"]}`},
+	{"write_file", "Write", "Create or overwrite a file in the project.",
+		`{"type":"object","properties":{"path":{"type":"string","description":"Path relative to the project root"},"content":{"type":"string","description":"The whole new file content"}},"required":["path","content"]}`},
+	{"run_shell", "Bash", "Run a bash command in the project root and return its output and exit code.",
+		`{"type":"object","properties":{"command":{"type":"string"}},"required":["command"]}`},
+}
+
+// parseOpenAIFlags reads agent_flags for an OpenAI-compatible agent.
+func parseOpenAIFlags(flags string) (openAIOptions, error) {
+	opts := openAIOptions{maxTurns: openAIDefaultMaxTurns, apiKeyEnv: "OPENAI_API_KEY"}
+	fields := strings.Fields(flags)
+	for i := 0; i < len(fields); i++ {
+		flag := fields[i]
+		if i+1 >= len(fields) {
+			return opts, fmt.Errorf("flag %s needs a value", flag)
+		}
+		value := fields[i+1]
+		i++
+		switch flag {
+		case "--model":
+			opts.model = value
+		case "--max-turns":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return opts, fmt.Errorf("--max-turns must be a positive number, got %q", value)
+			}
+			opts.maxTurns = n
+		case "--api-key-env":
+			opts.apiKeyEnv = value
+		default:
+			return opts, fmt.Errorf("unknown flag %s (expected --model, --max-turns or --api-key-env)", flag)
+		}
+	}
+	return opts, nil
+}
+
+// openAIEndpoint returns the chat completions URL for a base URL such as
+// "http://localhost:8000/v1".
+func openAIEndpoint(baseURL string) string {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if strings.HasSuffix(baseURL, "/chat/completions") {
+		return baseURL
+	}
+	return baseURL + "/chat/completions"
+}
+
+// isHTTPAgent reports whether the agent is an endpoint URL rather than a CLI.
+func isHTTPAgent(agent string) bool {
+	return strings.HasPrefix(agent, "http://") || strings.HasPrefix(agent, "https://")
+}
+
+// BuildCommand describes the request for previews; the session itself is run
+// by Run.
+func (b *OpenAIBackend) BuildCommand(baseCmd, extraFlags, prompt string) string {
+	cmd := "POST " + openAIEndpoint(baseCmd)
+	if opts, err := parseOpenAIFlags(extraFlags); err == nil && opts.model != "" {
+		cmd += " (model " + opts.model + ")"
+	}
+	return cmd
+}
+
+// ResumeCommand starts a new conversation: the endpoint keeps no sessions.
+func (b *OpenAIBackend) ResumeCommand(baseCmd, extraFlags, sessionID, prompt string) string {
+	return b.BuildCommand(baseCmd, extraFlags, prompt)
+}
+
+// ProcessLine reads one line of a streamed response. Text deltas are returned;
+// tool call deltas are collected until the response ends.
+func (b *OpenAIBackend) ProcessLine(line string) LineEvent {
+	data, ok := strings.CutPrefix(line, "data:")
+	if !ok {
+		return LineEvent{}
+	}
+	data = strings.TrimSpace(data)
+	if data == "[DONE]" {
+		return LineEvent{Done: true}
+	}
+	var chunk openAIChunk
+	if json.Unmarshal([]byte(data), &chunk) != nil {
+		return LineEvent{}
+	}
+
+	var ev LineEvent
+	if chunk.Error != nil {
+		ev.Text = "Error: " + chunk.Error.Message + "\n"
+		ev.Error = chunk.Error.Message
+		ev.Done = true
+		return ev
+	}
+	for _, choice := range chunk.Choices {
+		ev.Text += choice.Delta.Content
+		for _, delta := range choice.Delta.ToolCalls {
+			for len(b.calls) <= delta.Index {
+				b.calls = append(b.calls, openAIToolCall{Type: "function"})
+			}
+			call := &b.calls[delta.Index]
+			if delta.ID != "" {
+				call.ID = delta.ID
+			}
+			if delta.Function.Name != "" {
+				call.Function.Name = delta.Function.Name
+			}
+			call.Function.Arguments += delta.Function.Arguments
+		}
+	}
+	if u := chunk.Usage; u != nil {
+		ev.Usage = &Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+		if u.PromptTokensDetails != nil {
+			ev.Usage.CacheReadTokens = u.PromptTokensDetails.CachedTokens
+			ev.Usage.InputTokens -= u.PromptTokensDetails.CachedTokens
+		}
+	}
+	return ev
+}
+
+func (b *OpenAIBackend) RateLimitPhrases() []string {
+	return []string{"HTTP 429"}
+}
+
+// RateLimitReset reads the Retry-After header of a 429 response.
+func (b *OpenAIBackend) RateLimitReset(output string, now time.Time) (time.Time, bool) {
+	return parseRetryAfter(output, now)
+}
+
+// openAIFailures are endpoint errors that retrying will not fix: a rejected
+// key, an unknown model or endpoint, and a prompt that does not fit.
+var openAIFailures = FailurePhrases{
+	Fatal: []string{
+		"HTTP 401",
+		"HTTP 403",
+		"HTTP 404",
+		"model_not_found",
+	},
+	Candidate: []string{
+		"context_length_exceeded",
+		"maximum context length",
+	},
+}
+
+func (b *OpenAIBackend) ClassifyFailure(errors string, exitCode int) Failure {
+	return openAIFailures.Classify(errors, exitCode)
+}
+
+func (b *OpenAIBackend) DisplayName() string {
+	return "OpenAI"
+}
+
+// openAISession is the state of one Run: the conversation so far and what has
+// been collected from it.
+type openAISession struct {
+	backend  *OpenAIBackend
+	req      AIRequest
+	opts     openAIOptions
+	endpoint string
+	messages []openAIMessage
+	errors   strings.Builder // Errors reported by the endpoint, for rate limit detection
+	usage    Usage
+	idle     *time.Timer // Cancels the session when the endpoint goes quiet; nil without idle_timeout
+	tail     outputTail  // Last raw lines received, logged on an idle timeout
+}
+
+// errAgentIdle cancels a session that produced no output for idle_timeout.
+var errAgentIdle = errors.New("agent idle")
+
+// Check validates the endpoint URL and agent_flags.
+func (b *OpenAIBackend) Check(agent, flags string) error {
+	if !isHTTPAgent(agent) {
+		return fmt.Errorf("agent %s is not an http(s) URL", agent)
+	}
+	if _, err := parseOpenAIFlags(flags); err != nil {
+		return fmt.Errorf("invalid agent_flags for %s: %w", agent, err)
+	}
+	return nil
+}
+
+// Run holds a conversation with the endpoint, running the tools the model
+// calls, until it answers without calling any.
+func (b *OpenAIBackend) Run(req AIRequest) (AIResult, error) {
+	opts, err := parseOpenAIFlags(req.ExtraFlags)
+	if err != nil {
+		return AIResult{}, err
+	}
+	if req.Procs == nil {
+		req.Procs = defaultProcessTracker
+	}
+	s := &openAISession{
+		backend:  b,
+		req:      req,
+		opts:     opts,
+		endpoint: openAIEndpoint(req.BaseCmd),
+		messages: []openAIMessage{
+			{Role: "system", Content: openAISystemPrompt},
+			{Role: "user", Content: req.Prompt},
+		},
+		tail: outputTail{max: idleTailLines},
+	}
+	if req.LogWriter != nil {
+		fmt.Fprintf(req.LogWriter, "Command: %s\n", b.BuildCommand(req.BaseCmd, req.ExtraFlags, req.Prompt))
+	}
+
+	ctx := context.Background()
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+	if req.IdleTimeout > 0 {
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		s.idle = time.AfterFunc(req.IdleTimeout, func() { cancel(errAgentIdle) })
+		defer s.idle.Stop()
+	}
+
+	err = s.run(ctx)
+
+	// Add a final newline after streaming is complete
+	if req.StreamCb != nil {
+		req.StreamCb("\n")
+	}
+	if req.LogWriter != nil {
+		fmt.Fprintln(req.LogWriter)
+	}
+
+	result := AIResult{Errors: s.errors.String(), Usage: s.usage}
+	if context.Cause(ctx) == errAgentIdle {
+		logIdleTimeout(req.LogWriter, req.IdleTimeout, s.tail.String(), "")
+		return result, &timeoutError{duration: req.IdleTimeout, idle: true}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return result, &timeoutError{duration: req.Timeout}
+	}
+	return result, err
+}
+
+func (s *openAISession) run(ctx context.Context) error {
+	for turn := 0; turn < s.opts.maxTurns; turn++ {
+		done, err := s.turn(ctx)
+		if err != nil || done {
+			return err
+		}
+	}
+	s.write(fmt.Sprintf("Stopped after %d turns\n", s.opts.maxTurns))
+	return nil
+}
+
+// touch records a line of activity and restarts the idle timeout.
+func (s *openAISession) touch(line string) {
+	s.tail.Add(line)
+	if s.idle != nil {
+		s.idle.Reset(s.req.IdleTimeout)
+	}
+}
+
+// write streams text to the console and the log.
+func (s *openAISession) write(text string) {
+	if s.req.StreamCb != nil {
+		s.req.StreamCb(text)
+	}
+	if s.req.LogWriter != nil {
+		fmt.Fprint(s.req.LogWriter, text)
+	}
+}
+
+// turn sends the conversation and streams the reply. It runs any tools the
+// model called and returns true once it called none.
+func (s *openAISession) turn(ctx context.Context) (bool, error) {
+	body := openAIRequest{Model: s.opts.model, Messages: s.messages, Stream: true}
+	body.StreamOptions.IncludeUsage = true
+	for _, t := range openAITools {
+		tool := openAITool{Type: "function"}
+		tool.Function.Name = t.name
+		tool.Function.Description = t.description
+		tool.Function.Parameters = json.RawMessage(t.parameters)
+		body.Tools = append(body.Tools, tool)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return false, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	if key := os.Getenv(s.opts.apiKeyEnv); key != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, s.statusError(resp)
+	}
+
+	s.backend.calls = nil
+	var reply strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 10*1024*1024) // 10MB max token size
+	for scanner.Scan() {
+		s.touch(scanner.Text())
+		ev := s.backend.ProcessLine(scanner.Text())
+		if ev.Usage != nil {
+			s.usage.Add(*ev.Usage)
+		}
+		if ev.Error != "" {
+			s.errors.WriteString(ev.Error + "\n")
+		}
+		if ev.Text != "" {
+			s.write(ev.Text)
+			reply.WriteString(ev.Text)
+		}
+		if ev.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	calls := s.backend.calls
+	s.backend.calls = nil
+	s.messages = append(s.messages, openAIMessage{Role: "assistant", Content: reply.String(), ToolCalls: calls})
+	if len(calls) == 0 {
+		return true, nil
+	}
+
+	if reply.Len() > 0 && !strings.HasSuffix(reply.String(), "\n") {
+		s.write("\n")
+	}
+	for _, call := range calls {
+		tool, result := s.runTool(ctx, call)
+		s.touch(tool.Summary(s.req.WorkDir))
+		if s.req.ToolCb != nil {
+			s.req.ToolCb(tool)
+		}
+		if s.req.LogWriter != nil {
+			fmt.Fprintln(s.req.LogWriter, tool.Summary(s.req.WorkDir))
+		}
+		if len(result) > openAIMaxToolOutput {
+			result = result[:openAIMaxToolOutput] + "\n[output truncated]"
+		}
+		s.messages = append(s.messages, openAIMessage{Role: "tool", Content: result, ToolCallID: call.ID})
+	}
+	return false, ctx.Err()
+}
+
+// statusError reports a failed request. The status line and any Retry-After
+// header go into the output and the errors, where a 429 is picked up as a
+// rate limit.
+func (s *openAISession) statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	text := "HTTP " + resp.Status + "\n"
+	if retry := resp.Header.Get("Retry-After"); retry != "" {
+		text += "Retry-After: " + retry + "\n"
+	}
+	if detail := strings.TrimSpace(string(body)); detail != "" {
+		text += detail + "\n"
+	}
+	s.write(text)
+	s.errors.WriteString(text)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		resetAt, _ := s.backend.RateLimitReset(text, time.Now())
+		return &rateLimitError{
+			msg:     s.backend.DisplayName() + " rate limit hit",
+			phrase:  "HTTP 429",
+			context: strings.TrimSpace(text),
+			resetAt: resetAt,
+		}
+	}
+	return fmt.Errorf("%s returned HTTP %s", s.endpoint, resp.Status)
+}
+
+// runTool runs one tool call, returning it as a ToolEvent and the result to
+// send back to the model.
+func (s *openAISession) runTool(ctx context.Context, call openAIToolCall) (ToolEvent, string) {
+	tool := ToolEvent{Name: call.Function.Name}
+	for _, t := range openAITools {
+		if t.name == call.Function.Name {
+			tool.Name = t.display
+		}
+	}
+
+	var args struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+		return failedTool(tool, fmt.Sprintf("invalid arguments: %v", err))
+	}
+
+	switch call.Function.Name {
+	case "read_file":
+		tool.Target = args.Path
+		path, err := projectPath(s.req.WorkDir, args.Path)
+		if err != nil {
+			return failedTool(tool, err.Error())
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return failedTool(tool, err.Error())
+		}
+		tool.Output = string(data)
+		return tool, string(data)
+	case "write_file":
+		tool.Target = args.Path
+		path, err := projectPath(s.req.WorkDir, args.Path)
+		if err != nil {
+			return failedTool(tool, err.Error())
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return failedTool(tool, err.Error())
+		}
+		if err := os.WriteFile(path, []byte(args.Content), 0644); err != nil {
+			return failedTool(tool, err.Error())
+		}
+		return tool, fmt.Sprintf("Wrote %d bytes to %s", len(args.Content), args.Path)
+	case "run_shell":
+		tool.Command = args.Command
+		output, exit, err := s.runShell(ctx, args.Command)
+		if err != nil {
+			return failedTool(tool, err.Error())
+		}
+		tool.Output = output
+		tool.Exit = exit
+		tool.Failed = exit != 0
+		return tool, fmt.Sprintf("%s\nExit code: %d", output, exit)
+	}
+	return failedTool(tool, "unknown tool "+call.Function.Name)
+}
+
+// failedTool marks a tool call as failed with message as its result.
+func failedTool(tool ToolEvent, message string) (ToolEvent, string) {
+	tool.Failed = true
+	tool.Output = message
+	return tool, "Error: " + message
+}
+
+// projectPath resolves a path the model gave, refusing paths outside the
+// project.
+func projectPath(workDir, path string) (string, error) {
+	if path == "" {
+		return "", errors.New("path is empty")
+	}
+	full := path
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(workDir, path)
+	}
+	rel, err := filepath.Rel(workDir, full)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s is outside the project", path)
+	}
+	return full, nil
+}
+
+// runShell runs a command for the model in the project directory, returning
+// its combined output and exit code.
+func (s *openAISession) runShell(ctx context.Context, command string) (string, int, error) {
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Dir = s.req.WorkDir
+	cmd.Env = commandEnv(s.req.ExtraEnv)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return "", 0, err
+	}
+	s.req.Procs.Set(cmd.Process)
+	err := cmd.Wait()
+	s.req.Procs.Clear()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return output.String(), 0, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() > 0:
+		return output.String(), exitErr.ExitCode(), nil
+	}
+	if ctx.Err() != nil {
+		return output.String(), 0, ctx.Err()
+	}
+	return output.String(), 0, err
+}
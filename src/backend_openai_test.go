@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openAIStandIn is an in-process OpenAI-compatible server that answers each
+// chat completion request with the next scripted list of SSE chunks.
+type openAIStandIn struct {
+	mu        sync.Mutex
+	responses [][]string
+	requests  []openAIRequest
+	auth      []string
+}
+
+func newOpenAIStandIn(t *testing.T, responses ...[]string) (*openAIStandIn, *httptest.Server) {
+	t.Helper()
+	s := &openAIStandIn{responses: responses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req openAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		if len(s.responses) == 0 {
+			s.mu.Unlock()
+			http.Error(w, "no more scripted responses", http.StatusInternalServerError)
+			return
+		}
+		chunks := s.responses[0]
+		s.responses = s.responses[1:]
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+func textChunk(text string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"choices": []interface{}{map[string]interface{}{"delta": map[string]interface{}{"content": text}}},
+	})
+	return string(data)
+}
+
+func toolChunk(index int, id, name, args string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"choices": []interface{}{map[string]interface{}{"delta": map[string]interface{}{
+			"tool_calls": []interface{}{map[string]interface{}{
+				"index":    index,
+				"id":       id,
+				"function": map[string]interface{}{"name": name, "arguments": args},
+			}},
+		}}},
+	})
+	return string(data)
+}
+
+func TestNewBackendDetectsHTTPAgent(t *testing.T) {
+	if got := NewBackend("http://localhost:8000/v1").DisplayName(); got != "OpenAI" {
+		t.Errorf("NewBackend(url) = %s, want OpenAI", got)
+	}
+}
+
+func TestParseOpenAIFlags(t *testing.T) {
+	opts, err := parseOpenAIFlags("--model qwen3-coder --max-turns 10 --api-key-env LOCAL_KEY")
+	if err != nil {
+		t.Fatalf("parseOpenAIFlags() error = %v", err)
+	}
+	if opts.model != "qwen3-coder" || opts.maxTurns != 10 || opts.apiKeyEnv != "LOCAL_KEY" {
+		t.Errorf("parseOpenAIFlags() = %+v", opts)
+	}
+
+	for _, flags := range []string{"--model", "--max-turns 0", "--fast yes"} {
+		if _, err := parseOpenAIFlags(flags); err == nil {
+			t.Errorf("parseOpenAIFlags(%q) succeeded, want error", flags)
+		}
+	}
+}
+
+func TestOpenAIEndpoint(t *testing.T) {
+	for _, base := range []string{"http://h/v1", "http://h/v1/", "http://h/v1/chat/completions"} {
+		if got := openAIEndpoint(base); got != "http://h/v1/chat/completions" {
+			t.Errorf("openAIEndpoint(%q) = %q", base, got)
+		}
+	}
+}
+
+func TestOpenAIRunsToolLoop(t *testing.T) {
+	t.Setenv("TEST_OPENAI_KEY", "secret")
+	standIn, server := newOpenAIStandIn(t,
+		[]string{
+			textChunk("Reading the file."),
+			toolChunk(0, "call-1", "read_file", `{"path":`),
+			toolChunk(0, "", "", `"a.txt"}`),
+			toolChunk(1, "call-2", "write_file", `{"path":"sub/b.txt","content":"fixed\n"}`),
+		},
+		[]string{
+			toolChunk(0, "call-3", "run_shell", `{"command":"cat sub/b.txt; exit 3"}`),
+		},
+		[]string{
+			textChunk("Done: "),
+			textChunk("wrote b.txt"),
+			`{"choices":[],"usage":{"prompt_tokens":120,"completion_tokens":30,"prompt_tokens_details":{"cached_tokens":20}}}`,
+		},
+	)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("broken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var text strings.Builder
+	var tools []string
+	result, err := RunAICommand(AIRequest{
+		Backend:    &OpenAIBackend{},
+		BaseCmd:    server.URL + "/v1",
+		ExtraFlags: "--model local --api-key-env TEST_OPENAI_KEY",
+		Prompt:     "Fix a.txt",
+		WorkDir:    dir,
+		StreamCb:   func(s string) { text.WriteString(s) },
+		ToolCb:     func(tool ToolEvent) { tools = append(tools, tool.Summary(dir)) },
+	})
+	if err != nil {
+		t.Fatalf("RunAICommand() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sub", "b.txt"))
+	if err != nil || string(data) != "fixed\n" {
+		t.Errorf("sub/b.txt = %q, %v, want written by the tool", data, err)
+	}
+	wantTools := []string{"▶ Read a.txt", "▶ Write sub/b.txt", "▶ Bash: cat sub/b.txt; exit 3 (exit 3)"}
+	if strings.Join(tools, "|") != strings.Join(wantTools, "|") {
+		t.Errorf("tools = %q, want %q", tools, wantTools)
+	}
+	if want := "Reading the file.\nDone: wrote b.txt\n"; text.String() != want {
+		t.Errorf("streamed text = %q, want %q", text.String(), want)
+	}
+	if want := (Usage{InputTokens: 100, OutputTokens: 30, CacheReadTokens: 20}); result.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", result.Usage, want)
+	}
+
+	if len(standIn.requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(standIn.requests))
+	}
+	if standIn.auth[0] != "Bearer secret" || standIn.requests[0].Model != "local" || !standIn.requests[0].Stream {
+		t.Errorf("first request = %+v (auth %q)", standIn.requests[0], standIn.auth[0])
+	}
+	// The second request carries the assistant's tool calls and their results
+	msgs := standIn.requests[1].Messages
+	if len(msgs) != 5 || len(msgs[2].ToolCalls) != 2 || msgs[2].ToolCalls[0].Function.Arguments != `{"path":"a.txt"}` {
+		t.Fatalf("second request messages = %+v", msgs)
+	}
+	if msgs[3].Role != "tool" || msgs[3].ToolCallID != "call-1" || msgs[3].Content != "broken\n" {
+		t.Errorf("read_file result = %+v", msgs[3])
+	}
+	last := standIn.requests[2].Messages
+	if got := last[len(last)-1].Content; got != "fixed\n\nExit code: 3" {
+		t.Errorf("run_shell result = %q", got)
+	}
+}
+
+func TestOpenAIToolRefusesPathsOutsideProject(t *testing.T) {
+	_, server := newOpenAIStandIn(t,
+		[]string{toolChunk(0, "call-1", "write_file", `{"path":"../escape.txt","content":"x"}`)},
+		[]string{textChunk("ok")},
+	)
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "project")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var tools []ToolEvent
+	_, err := RunAICommand(AIRequest{
+		Backend: &OpenAIBackend{},
+		BaseCmd: server.URL + "/v1",
+		WorkDir: dir,
+		ToolCb:  func(tool ToolEvent) { tools = append(tools, tool) },
+	})
+	if err != nil {
+		t.Fatalf("RunAICommand() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escape.txt")); err == nil {
+		t.Error("write_file wrote outside the project")
+	}
+	if len(tools) != 1 || !tools[0].Failed {
+		t.Errorf("tools = %+v, want one failed write", tools)
+	}
+}
+
+func TestOpenAIRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, `{"error":{"message":"slow down"}}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	b := &OpenAIBackend{}
+	before := time.Now()
+	result, err := RunAICommand(AIRequest{Backend: b, BaseCmd: server.URL + "/v1", WorkDir: t.TempDir()})
+
+	rl, ok := err.(*rateLimitError)
+	if !ok {
+		t.Fatalf("RunAICommand() error = %v, want rateLimitError", err)
+	}
+	if rl.resetAt.Before(before.Add(30*time.Second)) || rl.resetAt.After(time.Now().Add(30*time.Second)) {
+		t.Errorf("resetAt = %v, want 30s from now", rl.resetAt)
+	}
+	if _, ok := findRateLimitMatch(result.Errors, b.RateLimitPhrases()); !ok {
+		t.Errorf("errors %q have no rate limit phrase", result.Errors)
+	}
+}
+
+func TestOpenAITimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	_, err := RunAICommand(AIRequest{
+		Backend: &OpenAIBackend{},
+		BaseCmd: server.URL + "/v1",
+		WorkDir: t.TempDir(),
+		Timeout: 100 * time.Millisecond,
+	})
+	if _, ok := err.(*timeoutError); !ok {
+		t.Fatalf("RunAICommand() error = %v, want timeoutError", err)
+	}
+}
+
+func TestOpenAIStopsAfterMaxTurns(t *testing.T) {
+	loop := []string{toolChunk(0, "call", "run_shell", `{"command":"true"}`)}
+	standIn, server := newOpenAIStandIn(t, loop, loop, loop)
+
+	var log bytes.Buffer
+	_, err := RunAICommand(AIRequest{
+		Backend:    &OpenAIBackend{},
+		BaseCmd:    server.URL + "/v1",
+		ExtraFlags: "--max-turns 2",
+		WorkDir:    t.TempDir(),
+		LogWriter:  &log,
+	})
+	if err != nil {
+		t.Fatalf("RunAICommand() error = %v", err)
+	}
+	if len(standIn.requests) != 2 {
+		t.Errorf("got %d requests, want 2", len(standIn.requests))
+	}
+	if !strings.Contains(log.String(), "Stopped after 2 turns") {
+		t.Errorf("log = %q, want the turn limit noted", log.String())
+	}
+}
+
+func TestOpenAIIdleTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\n", textChunk("Thinking"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	var log strings.Builder
+	_, err := RunAICommand(AIRequest{
+		Backend:     &OpenAIBackend{},
+		BaseCmd:     server.URL + "/v1",
+		WorkDir:     t.TempDir(),
+		Timeout:     time.Minute,
+		IdleTimeout: 200 * time.Millisecond,
+		LogWriter:   &log,
+	})
+	if timeoutErr, ok := err.(*timeoutError); !ok || !timeoutErr.idle {
+		t.Fatalf("RunAICommand() error = %v, want an idle timeoutError", err)
+	}
+	if !strings.Contains(log.String(), "Idle timeout: no output for 200ms") || !strings.Contains(log.String(), "Thinking") {
+		t.Errorf("log missing the idle timeout and last lines:\n%s", log.String())
+	}
+}
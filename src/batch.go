@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SelectBatch returns up to size candidates that are not ignored, starting
+// with the one SelectCandidate would pick. When by is set, only candidates
+// whose by field matches the first candidate's join the batch.
+func SelectBatch(candidates []Candidate, ignored *IgnoredList, size int, by string) []Candidate {
+	if size < 1 {
+		size = 1
+	}
+
+	var batch []Candidate
+	var group string
+	for _, c := range candidates {
+		if ignored != nil && ignored.Contains(c.Key) {
+			continue
+		}
+		if len(batch) > 0 && by != "" {
+			if value, ok := batchField(&c, by); !ok || value != group {
+				continue
+			}
+		}
+		if len(batch) == 0 && by != "" {
+			group, _ = batchField(&c, by)
+		}
+		batch = append(batch, c)
+		if len(batch) == size {
+			break
+		}
+	}
+	return batch
+}
+
+// batchField returns the value batch_by groups on: a map key, or an array
+// index when by is a number.
+func batchField(c *Candidate, by string) (string, bool) {
+	if c.IsArray() {
+		if i, err := strconv.Atoi(by); err == nil {
+			return c.GetIndex(i)
+		}
+	}
+	return c.GetKey(by)
+}
+
+// batchCandidate combines a batch into the candidate the pipeline works on.
+// Its key lists the member keys and its data is a JSON array of the members'
+// data. A batch of one is just that candidate.
+func batchCandidate(batch []Candidate) *Candidate {
+	if len(batch) == 1 {
+		return &batch[0]
+	}
+
+	keys := make([]string, len(batch))
+	data := make([]json.RawMessage, len(batch))
+	for i, c := range batch {
+		keys[i] = c.Key
+		data[i] = c.Data
+	}
+	raw, _ := json.Marshal(data) // Members are valid JSON
+	return &Candidate{Key: strings.Join(keys, ", "), Data: raw, batch: batch}
+}
+
+// members returns the candidates c stands for: the batch members, or c itself.
+func (c *Candidate) members() []Candidate {
+	if len(c.batch) > 0 {
+		return c.batch
+	}
+	return []Candidate{*c}
+}
+
+// foreachRe matches a per-candidate block: $FOREACH_INPUT ... $END_FOREACH
+var foreachRe = regexp.MustCompile(`(?s)\$FOREACH_INPUT(.*?)\$END_FOREACH`)
+
+// InterpolateBatchPrompt interpolates a prompt for a batch of candidates.
+// $INPUTS is a JSON array of every candidate, and the text between
+// $FOREACH_INPUT and $END_FOREACH is repeated for each candidate, with $INPUT
+// and $INDEX (1-based) referring to that candidate. Elsewhere $INPUT refers to
+// the first candidate, so single-candidate templates work unchanged.
+func InterpolateBatchPrompt(template string, batch []Candidate, taskID int64) (string, error) {
+	data := make([]json.RawMessage, len(batch))
+	for i, c := range batch {
+		data[i] = c.Data
+	}
+	inputs, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode $INPUTS: %w", err)
+	}
+
+	// Interpolate each piece separately so candidate text is never itself
+	// interpolated
+	var result strings.Builder
+	interpolate := func(text string, candidate *Candidate) error {
+		for i, part := range strings.Split(text, "$INPUTS") {
+			if i > 0 {
+				result.Write(inputs)
+			}
+			interpolated, err := InterpolatePrompt(part, candidate, taskID)
+			if err != nil {
+				return err
+			}
+			result.WriteString(interpolated)
+		}
+		return nil
+	}
+
+	last := 0
+	for _, loc := range foreachRe.FindAllStringSubmatchIndex(template, -1) {
+		if err := interpolate(template[last:loc[0]], &batch[0]); err != nil {
+			return "", err
+		}
+		body := template[loc[2]:loc[3]]
+		for i := range batch {
+			if err := interpolate(strings.ReplaceAll(body, "$INDEX", strconv.Itoa(i+1)), &batch[i]); err != nil {
+				return "", err
+			}
+		}
+		last = loc[1]
+	}
+	if err := interpolate(template[last:], &batch[0]); err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// splitBatch splits the members of candidate into those missing from the
+// re-checked candidates (fixed) and those still reported.
+func splitBatch(candidate *Candidate, remaining []Candidate) (fixed, unfixed []Candidate) {
+	for _, member := range candidate.members() {
+		if containsKey(remaining, member.Key) {
+			unfixed = append(unfixed, member)
+		} else {
+			fixed = append(fixed, member)
+		}
+	}
+	return fixed, unfixed
+}
+
+// settleBatch marks the members the agent did not fix once a batch is
+// committed, and returns the candidate for the fixed members.
+func (r *Runner) settleBatch(candidate *Candidate) (*Candidate, error) {
+	unfixed := r.batchUnfixed
+	if len(unfixed) == 0 {
+		return candidate, nil
+	}
+
+	var fixed []Candidate
+	for _, member := range candidate.members() {
+		if !containsKey(unfixed, member.Key) {
+			fixed = append(fixed, member)
+		}
+	}
+	for _, member := range unfixed {
+		fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("✗ Candidate %s not fixed", member.Key)))
+		if r.ignoredList != nil {
+			if err := r.ignoredList.Add(member.Key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return batchCandidate(fixed), nil
+}
+
+// batchDetails lists the batch members that were not fixed for the log
+// outcome, or "" when there are none.
+func (r *Runner) batchDetails() string {
+	if len(r.batchUnfixed) == 0 {
+		return ""
+	}
+	keys := make([]string, len(r.batchUnfixed))
+	for i, c := range r.batchUnfixed {
+		keys[i] = c.Key
+	}
+	return "not fixed: " + strings.Join(keys, ", ")
+}
+
+// ignoreCandidate adds candidate, or each member of a batch, to the ignored list.
+func (r *Runner) ignoreCandidate(candidate *Candidate) error {
+	if r.ignoredList == nil {
+		return nil
+	}
+	for _, member := range candidate.members() {
+		if err := r.ignoredList.Add(member.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
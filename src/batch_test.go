@@ -0,0 +1,189 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelectBatch(t *testing.T) {
+	candidates, err := ParseCandidates([]byte(`[
+		{"file": "a.go", "line": 1},
+		{"file": "b.go", "line": 2},
+		{"file": "a.go", "line": 3},
+		{"file": "a.go", "line": 4}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrays, err := ParseCandidates([]byte(`[["a.go", 1], ["b.go", 2], ["a.go", 3]]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		candidates []Candidate
+		size       int
+		by         string
+		ignore     []int
+		want       []int // Indexes into candidates
+	}{
+		{"size zero selects one", candidates, 0, "", nil, []int{0}},
+		{"takes the first n", candidates, 2, "", nil, []int{0, 1}},
+		{"fewer than n available", candidates, 10, "", nil, []int{0, 1, 2, 3}},
+		{"skips ignored", candidates, 2, "", []int{0}, []int{1, 2}},
+		{"groups by map key", candidates, 3, "file", nil, []int{0, 2, 3}},
+		{"group follows first selected", candidates, 3, "file", []int{0}, []int{1}},
+		{"groups by array index", arrays, 3, "0", nil, []int{0, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ignored := &IgnoredList{entries: map[string]bool{}, attempts: map[string]int{}}
+			for _, i := range tt.ignore {
+				ignored.entries[tt.candidates[i].Key] = true
+			}
+
+			batch := SelectBatch(tt.candidates, ignored, tt.size, tt.by)
+			if len(batch) != len(tt.want) {
+				t.Fatalf("got %d candidates, want %d", len(batch), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if batch[i].Key != tt.candidates[want].Key {
+					t.Errorf("batch[%d] = %s, want %s", i, batch[i].Key, tt.candidates[want].Key)
+				}
+			}
+		})
+	}
+}
+
+func TestInterpolateBatchPrompt(t *testing.T) {
+	batch, err := ParseCandidates([]byte(`[["a.go", "unused x"], ["b.go", "unused $INPUTS"]]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"inputs", "Fix: $INPUTS", `Fix: [["a.go","unused x"],["b.go","unused $INPUTS"]]`},
+		{"foreach", "Fix:\n$FOREACH_INPUT$INDEX. $INPUT[1] in $INPUT[0]\n$END_FOREACHDone", "Fix:\n1. unused x in a.go\n2. unused $INPUTS in b.go\nDone"},
+		{"input outside loop is first", "Start with $INPUT[0]", "Start with a.go"},
+		{"task id", "$TASK_ID $FOREACH_INPUT$TASK_ID $END_FOREACH", "7 7 7 "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InterpolateBatchPrompt(tt.template, batch, 7)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := InterpolateBatchPrompt("$FOREACH_INPUT$INPUT[0]$END_FOREACH", []Candidate{{Key: "s", Data: []byte(`"s"`)}}, 7); err == nil {
+		t.Error("expected an error indexing a string candidate in a loop")
+	}
+}
+
+func TestRunIterationBatchMarksCandidatesIndividually(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`touch fixed`,
+		Task{
+			CandidateSource: `if [ -f fixed ]; then echo '["c2"]'; else echo '["c1", "c2", "c3", "c4"]'; fi`,
+			Prompt:          "Fix all of:\n$FOREACH_INPUT- $INPUT\n$END_FOREACH",
+			BatchSize:       3,
+		},
+		Config{
+			ResetCommand:   "touch reset",
+			SuccessCommand: "echo $CANDIDATE > committed",
+		},
+	)
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	prompt, _ := os.ReadFile(filepath.Join(dir, ".prompt-1"))
+	if !strings.Contains(string(prompt), "- c1\n- c2\n- c3\n") || strings.Contains(string(prompt), "c4") {
+		t.Errorf("prompt = %q, want the first three candidates", prompt)
+	}
+
+	committed, err := os.ReadFile(filepath.Join(dir, "committed"))
+	if err != nil {
+		t.Fatal("expected a commit when part of the batch was fixed")
+	}
+	if strings.TrimSpace(string(committed)) != "c1, c3" {
+		t.Errorf("$CANDIDATE = %q, want the fixed candidates", committed)
+	}
+	if !runner.ignoredList.Contains("c2") {
+		t.Error("expected the unfixed candidate to be ignored")
+	}
+	if runner.ignoredList.Contains("c1") || runner.ignoredList.Contains("c3") {
+		t.Error("fixed candidates should not be ignored")
+	}
+	log, _ := os.ReadFile(AgentLogPath(runner.task.Dir))
+	if !strings.Contains(string(log), "not fixed: c2") {
+		t.Errorf("agent log does not record the unfixed candidate:\n%s", log)
+	}
+}
+
+func TestRunIterationBatchNotFixed(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`true`,
+		Task{
+			CandidateSource: `echo '["c1", "c2"]'`,
+			BatchSize:       2,
+		},
+		Config{
+			ResetCommand:   "touch reset",
+			SuccessCommand: "touch committed",
+		},
+	)
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	if _, err := os.Stat(filepath.Join(dir, "committed")); err == nil {
+		t.Error("success command ran although nothing in the batch was fixed")
+	}
+	if !runner.ignoredList.Contains("c1") || !runner.ignoredList.Contains("c2") {
+		t.Error("expected every batch member to be ignored")
+	}
+}
+
+func TestRunStateBatchRoundTrip(t *testing.T) {
+	batch := batchCandidate([]Candidate{
+		{Key: "a", Data: []byte(`"a"`)},
+		{Key: `{"n":1}`, Data: []byte(`{"n":1}`)},
+	})
+	path := filepath.Join(t.TempDir(), "state.json")
+	state := &RunState{Candidate: batch.Key, Data: batch.Data}
+	for _, member := range batch.batch {
+		state.Batch = append(state.Batch, member.Key)
+	}
+	if err := SaveRunState(path, state); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadRunState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := loaded.candidate()
+	if len(got.batch) != 2 || got.batch[1].Key != `{"n":1}` || string(got.batch[1].Data) != `{"n":1}` {
+		t.Errorf("restored batch = %+v", got.batch)
+	}
+}
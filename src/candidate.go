@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // Candidate represents a work item from the candidate source output.
@@ -18,6 +19,8 @@ import (
 type Candidate struct {
 	Key  string          // JSON serialization of the full candidate (for uniqueness)
 	Data json.RawMessage // Raw JSON data (string, array, or map)
+
+	batch []Candidate // Members when the candidate stands for a batch
 }
 
 // HashPartition specifies which partition of candidates a worker should process
@@ -302,7 +305,9 @@ func FilterByPartition(candidates []Candidate, partition HashPartition) []Candid
 }
 
 // IgnoredList manages the list of already-processed candidates.
+// It is safe for concurrent use by multiple workers.
 type IgnoredList struct {
+	mu        sync.Mutex
 	path      string
 	entries   map[string]bool // For file-based ignore list
 	attempts  map[string]int  // Track attempts per candidate key
@@ -367,6 +372,8 @@ func NewIgnoredListFromCommand(command, workDir string) (*IgnoredList, error) {
 }
 
 func (l *IgnoredList) Contains(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	if l.maxRepeat > 0 {
 		return l.attempts[key] >= l.maxRepeat
 	}
@@ -377,6 +384,8 @@ func (l *IgnoredList) Contains(key string) bool {
 // When repeat mode is enabled, existing entries (from file) are marked as done
 // so they won't be retried. Only new candidates will get up to N attempts.
 func (l *IgnoredList) SetMaxRepeat(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.maxRepeat = n
 	if n > 0 {
 		for key := range l.entries {
@@ -388,7 +397,17 @@ func (l *IgnoredList) SetMaxRepeat(n int) {
 	}
 }
 
+// Attempts returns how many times key has been attempted.
+func (l *IgnoredList) Attempts(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.attempts[key]
+}
+
 func (l *IgnoredList) Add(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	// Increment attempt count
 	l.attempts[key]++
 
@@ -407,6 +426,7 @@ func (l *IgnoredList) Add(key string) error {
 
 // persistKey writes a key to the ignored log file and marks it in entries.
 // Command-based lists (no path) are only tracked in memory.
+// The caller must hold l.mu.
 func (l *IgnoredList) persistKey(key string) error {
 	if l.entries[key] {
 		return nil
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// vanishedKeys returns the keys in before that are gone from after, other
+// than the selected candidate (or batch members), sorted.
+func vanishedKeys(before map[string]bool, after []Candidate, candidate *Candidate) []string {
+	remaining := candidateKeys(after)
+	for _, member := range candidate.members() {
+		remaining[member.Key] = true
+	}
+	remaining[candidate.Key] = true
+
+	var keys []string
+	for key := range before {
+		if !remaining[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// recordCollateral notes the other candidates that disappeared along with the
+// selected one. Without a recorded before set there is nothing to compare.
+func (r *Runner) recordCollateral(candidate *Candidate, after []Candidate) {
+	r.collateral = nil
+	if r.candidatesBefore == nil {
+		return
+	}
+	r.collateral = vanishedKeys(r.candidatesBefore, after, candidate)
+}
+
+// reportCollateral mentions the recorded collateral fixes once the re-check
+// is done. Best-of-N runs report only the winning attempt's.
+func (r *Runner) reportCollateral() {
+	if len(r.collateral) > 0 {
+		fmt.Fprintln(r.console(), ColorInfo(fmt.Sprintf("%d other candidates also disappeared", len(r.collateral))))
+	}
+}
+
+// fixedCandidates lists every candidate a change fixed: the selected
+// candidate (or fixed batch members) followed by collateral fixes.
+func (r *Runner) fixedCandidates(candidate *Candidate) []string {
+	var keys []string
+	for _, member := range candidate.members() {
+		keys = append(keys, member.Key)
+	}
+	return append(keys, r.collateral...)
+}
+
+// logCollateralFixes reports collateral fixes once a change is committed, with
+// a COLLATERAL_FIX log entry for each naming the candidate that triggered it.
+func (r *Runner) logCollateralFixes(candidate *Candidate) {
+	if len(r.collateral) == 0 {
+		return
+	}
+	fmt.Fprintln(r.console(), ColorSuccess(fmt.Sprintf("✓ Also fixed %d other candidates:", len(r.collateral))))
+	for _, key := range r.collateral {
+		fmt.Fprintf(r.console(), "  - %s\n", key)
+		if r.agentLogger != nil {
+			r.agentLogger.LogCollateralFix(key, candidate.Key)
+		}
+	}
+	r.countOutcome(OutcomeCollateralFix, len(r.collateral))
+}
+
+// countOutcome adds to the outcome counts for the end-of-run summary.
+func (r *Runner) countOutcome(outcome Outcome, n int) {
+	if r.outcomes == nil {
+		r.outcomes = make(map[Outcome]int)
+	}
+	r.outcomes[outcome] += n
+}
+
+// summaryLabels are the outcomes shown in the end-of-run summary, in order.
+var summaryLabels = []struct {
+	outcome Outcome
+	label   string
+}{
+	{OutcomeFixed, "fixed"},
+	{OutcomeCollateralFix, "collateral fixes"},
+	{OutcomeBestEffort, "best effort"},
+	{OutcomeNotFixed, "not fixed"},
+	{OutcomeFixedReverted, "fixed but reverted"},
+	{OutcomeBuildFailed, "build failed"},
+	{OutcomeGuardrailViolation, "guardrail violations"},
+	{OutcomeAgentFailed, "agent failures"},
+	{OutcomeIdleTimeout, "idle timeouts"},
+}
+
+// runSummary describes a finished run, e.g.
+// "Summary: 4 iterations, 3 fixed, 12 collateral fixes, 1 not fixed".
+func runSummary(iterations int, outcomes map[Outcome]int) string {
+	parts := []string{fmt.Sprintf("%d iterations", iterations)}
+	for _, s := range summaryLabels {
+		if n := outcomes[s.outcome]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, s.label))
+		}
+	}
+	return "Summary: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVanishedKeys(t *testing.T) {
+	before := candidateKeys([]Candidate{{Key: "a"}, {Key: "d"}, {Key: "b"}, {Key: "c"}})
+	after := []Candidate{{Key: "c"}, {Key: "e"}}
+
+	got := vanishedKeys(before, after, &Candidate{Key: "a"})
+	if want := []string{"b", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("vanishedKeys() = %v, want %v", got, want)
+	}
+
+	batch := batchCandidate([]Candidate{{Key: "a"}, {Key: "b"}})
+	got = vanishedKeys(before, after, batch)
+	if want := []string{"d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("vanishedKeys() for batch = %v, want %v", got, want)
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	tests := []struct {
+		name       string
+		iterations int
+		outcomes   map[Outcome]int
+		want       string
+	}{
+		{"empty", 0, nil, "Summary: 0 iterations"},
+		{"mixed", 4, map[Outcome]int{
+			OutcomeNotFixed:      1,
+			OutcomeFixed:         3,
+			OutcomeCollateralFix: 12,
+		}, "Summary: 4 iterations, 3 fixed, 12 collateral fixes, 1 not fixed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runSummary(tt.iterations, tt.outcomes); got != tt.want {
+				t.Errorf("runSummary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunIterationRecordsCollateralFixes(t *testing.T) {
+	// Fixing c1 also clears c2 and c3
+	runner, dir := newScriptedRunner(t, "touch fixed",
+		Task{
+			CandidateSource: `if [ -f fixed ]; then echo '["c4"]'; else echo '["c1","c2","c3","c4"]'; fi`,
+		},
+		Config{
+			ResetCommand:   "true",
+			SuccessCommand: "printf '%s' $FIXED_CANDIDATES > committed",
+		},
+	)
+
+	stdout := captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	committed, err := os.ReadFile(filepath.Join(dir, "committed"))
+	if err != nil {
+		t.Fatalf("expected success command to run: %v", err)
+	}
+	if string(committed) != "c1\nc2\nc3" {
+		t.Errorf("$FIXED_CANDIDATES = %q, want c1, c2 and c3 on separate lines", committed)
+	}
+	for _, want := range []string{"Also fixed 2 other candidates:", "  - c2\n", "  - c3\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout missing %q:\n%s", want, stdout)
+		}
+	}
+
+	log, err := os.ReadFile(runner.agentLogger.Path())
+	if err != nil {
+		t.Fatalf("failed to read agent log: %v", err)
+	}
+	for _, want := range []string{
+		"Outcome: COLLATERAL_FIX\nCandidate: c2\nFixed by: c1\n",
+		"Outcome: COLLATERAL_FIX\nCandidate: c3\nFixed by: c1\n",
+	} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("agent log missing %q:\n%s", want, log)
+		}
+	}
+
+	if got := runSummary(1, runner.outcomes); got != "Summary: 1 iterations, 1 fixed, 2 collateral fixes" {
+		t.Errorf("summary = %q", got)
+	}
+}
@@ -133,7 +133,7 @@ func displayWidth(s string) int {
 }
 
 // StartupBanner creates the startup banner with cat ASCII art
-func StartupBanner(taskName, logPath, mode string) string {
+func StartupBanner(taskName, logPath, mode, agent string) string {
 	cat := []string{
 		"　　　　　   __",
 		"　　　　 ／フ   フ",
@@ -162,12 +162,16 @@ func StartupBanner(taskName, logPath, mode string) string {
 		4: "Task: " + taskName,
 		5: "Logs: " + logPath,
 		6: "Mode: " + mode,
+		7: "Agent: " + agent,
 	}
 
 	// Remove logs line if no path provided
 	if logPath == "" {
 		delete(labels, 5)
 	}
+	if agent == "" {
+		delete(labels, 7)
+	}
 
 	var result strings.Builder
 
@@ -94,7 +94,7 @@ func TestIterationBanner(t *testing.T) {
 }
 
 func TestStartupBanner(t *testing.T) {
-	result := StartupBanner("my-task", "/path/to/logs", "standard")
+	result := StartupBanner("my-task", "/path/to/logs", "standard", "Claude")
 
 	// Should contain task name with label
 	if !strings.Contains(result, "Task: my-task") {
@@ -111,6 +111,11 @@ func TestStartupBanner(t *testing.T) {
 		t.Error("Startup banner should contain 'Logs: /path/to/logs'")
 	}
 
+	// Should contain agent with label
+	if !strings.Contains(result, "Agent: Claude") {
+		t.Error("Startup banner should contain 'Agent: Claude'")
+	}
+
 	// Should contain bold "Nigel"
 	if !strings.Contains(result, colorBold+"Nigel") {
 		t.Error("Startup banner should contain bold 'Nigel'")
@@ -128,7 +133,7 @@ func TestStartupBanner(t *testing.T) {
 }
 
 func TestStartupBannerDryRun(t *testing.T) {
-	result := StartupBanner("my-task", "/path/to/agent.log", "dry-run")
+	result := StartupBanner("my-task", "/path/to/agent.log", "dry-run", "")
 
 	// Should contain task name
 	if !strings.Contains(result, "my-task") {
@@ -144,4 +149,9 @@ func TestStartupBannerDryRun(t *testing.T) {
 	if !strings.Contains(result, "dry-run") {
 		t.Error("Startup banner should show dry-run mode")
 	}
+
+	// Should omit the agent line when no agent is given
+	if strings.Contains(result, "Agent:") {
+		t.Error("Startup banner should not show an empty agent line")
+	}
 }
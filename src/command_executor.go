@@ -2,9 +2,13 @@ package main
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
+	"time"
 )
 
 // CommandExecutor executes shell commands.
@@ -18,6 +22,13 @@ type CommandExecutor interface {
 	// RunShowOnFail executes a command, showing output only on failure.
 	RunShowOnFail(command, workDir string) (bool, error)
 
+	// RunCapture executes a command and returns its combined output without printing it.
+	RunCapture(command, workDir string) (bool, string, error)
+
+	// RunCaptureTimeout is RunCapture with a time limit (0 = none). A command
+	// that runs out of time is killed and reported as failed.
+	RunCaptureTimeout(command, workDir string, timeout time.Duration) (bool, string, error)
+
 	// HasUncommittedChanges checks if there are uncommitted git changes.
 	HasUncommittedChanges(workDir string) (bool, error)
 }
@@ -25,6 +36,22 @@ type CommandExecutor interface {
 // RealCommandExecutor executes actual shell commands.
 type RealCommandExecutor struct {
 	ExtraEnv []string
+	Output   io.Writer       // Destination for command output (defaults to stdout/stderr)
+	Procs    *ProcessTracker // Tracks commands run in their own process group (default tracker when nil)
+}
+
+func (r *RealCommandExecutor) stdout() io.Writer {
+	if r.Output != nil {
+		return r.Output
+	}
+	return os.Stdout
+}
+
+func (r *RealCommandExecutor) stderr() io.Writer {
+	if r.Output != nil {
+		return r.Output
+	}
+	return os.Stderr
 }
 
 // Run executes a shell command and returns success status.
@@ -32,8 +59,8 @@ func (r *RealCommandExecutor) Run(command, workDir string) (bool, error) {
 	cmd := exec.Command("bash", "-c", command)
 	cmd.Dir = workDir
 	cmd.Env = commandEnv(r.ExtraEnv)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = r.stdout()
+	cmd.Stderr = r.stderr()
 
 	err := cmd.Run()
 	if err != nil {
@@ -76,10 +103,10 @@ func (r *RealCommandExecutor) RunShowOnFail(command, workDir string) (bool, erro
 		if _, ok := err.(*exec.ExitError); ok {
 			// Command failed - print captured output
 			if stdout.Len() > 0 {
-				os.Stdout.Write(stdout.Bytes())
+				r.stdout().Write(stdout.Bytes())
 			}
 			if stderr.Len() > 0 {
-				os.Stderr.Write(stderr.Bytes())
+				r.stderr().Write(stderr.Bytes())
 			}
 			return false, nil
 		}
@@ -88,6 +115,73 @@ func (r *RealCommandExecutor) RunShowOnFail(command, workDir string) (bool, erro
 	return true, nil
 }
 
+// RunCapture executes a shell command and returns its success status and combined output.
+func (r *RealCommandExecutor) RunCapture(command, workDir string) (bool, string, error) {
+	cmd := exec.Command("bash", "-c", command)
+	cmd.Dir = workDir
+	cmd.Env = commandEnv(r.ExtraEnv)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return false, output.String(), nil
+		}
+		return false, output.String(), err
+	}
+	return true, output.String(), nil
+}
+
+// RunCaptureTimeout executes a shell command like RunCapture, killing its
+// process group if it runs longer than timeout. The command's process group
+// does not get the terminal's SIGINT, so it is tracked to be killed when Nigel
+// stops.
+func (r *RealCommandExecutor) RunCaptureTimeout(command, workDir string, timeout time.Duration) (bool, string, error) {
+	if timeout <= 0 {
+		return r.RunCapture(command, workDir)
+	}
+
+	cmd := exec.Command("bash", "-c", command)
+	cmd.Dir = workDir
+	cmd.Env = commandEnv(r.ExtraEnv)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Start(); err != nil {
+		return false, "", err
+	}
+	procs := r.Procs
+	if procs == nil {
+		procs = defaultProcessTracker
+	}
+	procs.Set(cmd.Process)
+	defer procs.Clear()
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return false, output.String() + fmt.Sprintf("\nTimed out after %s\n", timeout), nil
+	}
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return false, output.String(), nil
+		}
+		return false, output.String(), err
+	}
+	return true, output.String(), nil
+}
+
 // HasUncommittedChanges checks if there are uncommitted git changes.
 func (r *RealCommandExecutor) HasUncommittedChanges(workDir string) (bool, error) {
 	cmd := exec.Command("git", "diff", "--quiet")
@@ -1,11 +1,17 @@
 package main
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"time"
+)
 
 // MockCommandExecutor is a test double for CommandExecutor.
 type MockCommandExecutor struct {
 	// Commands to results mapping
 	Results map[string]CommandResult
+	// Commands to captured output mapping (for RunCapture)
+	Outputs map[string]string
 	// Record of calls made
 	Calls []CallRecord
 	// Mock for HasUncommittedChanges
@@ -29,6 +35,7 @@ type CallRecord struct {
 func NewMockCommandExecutor() *MockCommandExecutor {
 	return &MockCommandExecutor{
 		Results:          make(map[string]CommandResult),
+		Outputs:          make(map[string]string),
 		Calls:            make([]CallRecord, 0),
 		HasChangesResult: false, // Default: no changes
 		HasChangesErr:    nil,
@@ -65,6 +72,22 @@ func (m *MockCommandExecutor) RunShowOnFail(command, workDir string) (bool, erro
 	return true, nil
 }
 
+// RunCapture executes a command, recording the call and returning the configured result and output.
+func (m *MockCommandExecutor) RunCapture(command, workDir string) (bool, string, error) {
+	m.Calls = append(m.Calls, CallRecord{Command: command, WorkDir: workDir})
+	output := m.Outputs[command]
+	if result, ok := m.Results[command]; ok {
+		return result.Success, output, result.Error
+	}
+	// Default: success
+	return true, output, nil
+}
+
+// RunCaptureTimeout behaves like RunCapture; the mock never times out.
+func (m *MockCommandExecutor) RunCaptureTimeout(command, workDir string, timeout time.Duration) (bool, string, error) {
+	return m.RunCapture(command, workDir)
+}
+
 // HasUncommittedChanges returns the configured result.
 func (m *MockCommandExecutor) HasUncommittedChanges(workDir string) (bool, error) {
 	return m.HasChangesResult, m.HasChangesErr
@@ -75,6 +98,11 @@ func (m *MockCommandExecutor) SetResult(command string, success bool, err error)
 	m.Results[command] = CommandResult{Success: success, Error: err}
 }
 
+// SetOutput sets the captured output returned by RunCapture for a specific command.
+func (m *MockCommandExecutor) SetOutput(command, output string) {
+	m.Outputs[command] = output
+}
+
 // SetHasChanges sets the result for HasUncommittedChanges.
 func (m *MockCommandExecutor) SetHasChanges(hasChanges bool, err error) {
 	m.HasChangesResult = hasChanges
@@ -121,3 +149,76 @@ func TestRealCommandExecutorReceivesExtraEnv(t *testing.T) {
 		t.Fatal("RunSilent() = false, want true")
 	}
 }
+
+func TestRealCommandExecutorRunCaptureReturnsOutput(t *testing.T) {
+	executor := &RealCommandExecutor{}
+
+	ok, output, err := executor.RunCapture("echo out; echo err >&2; exit 3", ".")
+	if err != nil {
+		t.Fatalf("RunCapture() error = %v", err)
+	}
+	if ok {
+		t.Fatal("RunCapture() = true, want false for non-zero exit")
+	}
+	if output != "out\nerr\n" {
+		t.Fatalf("output = %q, want combined stdout and stderr", output)
+	}
+}
+
+func TestRealCommandExecutorRunCaptureTimeoutKillsCommand(t *testing.T) {
+	executor := &RealCommandExecutor{}
+
+	start := time.Now()
+	ok, output, err := executor.RunCaptureTimeout("echo started; sleep 10 & wait", ".", 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("RunCaptureTimeout() error = %v", err)
+	}
+	if ok {
+		t.Fatal("RunCaptureTimeout() = true, want false after timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("command ran for %s; the process group was not killed", elapsed)
+	}
+	if !strings.Contains(output, "started") || !strings.Contains(output, "Timed out after 200ms") {
+		t.Fatalf("output = %q, want command output and timeout note", output)
+	}
+}
+
+func TestRealCommandExecutorRunCaptureTimeoutIsTracked(t *testing.T) {
+	procs := NewProcessTracker()
+	executor := &RealCommandExecutor{Procs: procs}
+
+	done := make(chan bool, 1)
+	go func() {
+		ok, _, _ := executor.RunCaptureTimeout("sleep 10 & wait", ".", time.Minute)
+		done <- ok
+	}()
+
+	// Stopping Nigel kills the tracked process group, as on Ctrl-C
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		procs.mu.Lock()
+		running := procs.process != nil
+		procs.mu.Unlock()
+		if running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stage was never registered with the process tracker")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	procs.Kill()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("RunCaptureTimeout() = true, want false for a killed stage")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stage kept running after its process group was killed")
+	}
+	if procs.process != nil {
+		t.Error("tracker still holds the finished stage")
+	}
+}
@@ -13,13 +13,25 @@ import (
 )
 
 type Config struct {
-	Agent          string `yaml:"agent"`
-	AgentFlags     string `yaml:"agent_flags"`
-	ClaudeCommand  string `yaml:"claude_command"`
-	ClaudeFlags    string `yaml:"claude_flags"`
-	SuccessCommand string `yaml:"success_command"`
-	ResetCommand   string `yaml:"reset_command"`
-	VerifyCommand  string `yaml:"verify_command"`
+	Agent          string      `yaml:"agent"`
+	AgentFlags     string      `yaml:"agent_flags"`
+	Agents         []AgentSpec `yaml:"agents"`  // Fallback chain, most preferred first
+	Backend        string      `yaml:"backend"` // Backend for the agent (default: detected from the command)
+	ClaudeCommand  string      `yaml:"claude_command"`
+	ClaudeFlags    string      `yaml:"claude_flags"`
+	SuccessCommand string      `yaml:"success_command"`
+	ResetCommand   string      `yaml:"reset_command"`
+	VerifyCommand  string      `yaml:"verify_command"`
+
+	Verify []VerifyStage `yaml:"verify"` // Staged alternative to verify_command
+
+	ToolOutput string `yaml:"tool_output"` // How agent tool calls are shown: hide, summary (default) or full
+
+	Backends map[string]BackendSpec `yaml:"backends"` // Custom backends tasks and agents can select by name
+
+	Schedules map[string]Schedule `yaml:"schedules"` // Named time windows tasks and --schedule can select
+
+	Hooks `yaml:",inline"`
 }
 
 type Task struct {
@@ -30,13 +42,42 @@ type Task struct {
 	Template         string        `yaml:"template"`
 	AgentFlags       string        `yaml:"agent_flags"`
 	Agent            string        `yaml:"agent"`
+	Agents           []AgentSpec   `yaml:"agents"`  // Fallback chain, most preferred first
+	Backend          string        `yaml:"backend"` // Backend for the agent (overrides the global one)
 	ClaudeCommand    string        `yaml:"claude_command"`
 	ClaudeFlags      string        `yaml:"claude_flags"`
 	SuccessCommand   string        `yaml:"success_command"`
+	VerifyCommand    string        `yaml:"verify_command"` // Override the global verify command
 	AcceptBestEffort bool          `yaml:"accept_best_effort"`
 	Timeout          time.Duration `yaml:"timeout"`
-	IgnoreList       string        `yaml:"ignore_list"` // Command to generate ignore list
-	Repeat           int           `yaml:"repeat"`      // Retry each candidate N times
+	IdleTimeout      time.Duration `yaml:"idle_timeout"`      // Kill the agent after this long without output (default: never)
+	IgnoreList       string        `yaml:"ignore_list"`       // Command to generate ignore list
+	Repeat           int           `yaml:"repeat"`            // Retry each candidate N times
+	Order            string        `yaml:"order"`             // Candidate order (see ParseOrder)
+	MaxRepairRounds  int           `yaml:"max_repair_rounds"` // Follow-up sessions after a failed verify/re-check
+	RepairPrompt     string        `yaml:"repair_prompt"`     // Prompt for repair rounds ($VERIFY_OUTPUT, $RECHECK_RESULT, $PROMPT)
+
+	AttemptsPerCandidate int    `yaml:"attempts_per_candidate"` // Best-of-N: run each candidate N times in temporary worktrees
+	Selection            string `yaml:"selection"`              // How the best attempt is chosen: smallest_diff or score
+	ScoreCommand         string `yaml:"score_command"`          // Prints a number; lower is better
+	AcceptIf             string `yaml:"accept_if"`              // Judge candidates by score_command instead of presence
+	NoRegressions        bool   `yaml:"no_regressions"`         // Reject changes that introduce new candidates
+
+	TimeoutContinuations int           `yaml:"timeout_continuations"` // Resume a timed-out session N times to wrap up
+	ContinuationTimeout  time.Duration `yaml:"continuation_timeout"`  // Budget for each continuation (default 10m)
+
+	BatchSize int    `yaml:"batch_size"` // Send up to N candidates in one prompt ($INPUTS)
+	BatchBy   string `yaml:"batch_by"`   // Only batch candidates sharing this map key or array index
+
+	MaxCost   float64 `yaml:"max_cost"`   // Stop the run once agents have cost this many USD
+	MaxTokens int64   `yaml:"max_tokens"` // Stop the run once agents have used this many tokens
+
+	Schedules []string `yaml:"schedules"` // Names of the schedules that apply to this task
+
+	Verify []VerifyStage `yaml:"verify"` // Override the global verify stages
+
+	Hooks      `yaml:",inline"` // Override the global hooks
+	Guardrails `yaml:",inline"` // Limits on what the agent may change
 }
 
 type Environment struct {
@@ -69,10 +110,11 @@ func DiscoverEnvironment() (*Environment, error) {
 	}
 
 	config.normalize()
-	if config.Agent == "" {
+	if config.Agent == "" && len(config.Agents) == 0 {
 		config.Agent = "claude"
 	}
 	config.Agent = expandTilde(config.Agent)
+	expandAgentTildes(config.Agents)
 
 	tasks, err := loadTasks(runnerDir)
 	if err != nil {
@@ -105,6 +147,25 @@ func loadConfig(path string) (*Config, error) {
 	}
 
 	config.normalize()
+	if err := validateAgents(config.Agent, config.Agents); err != nil {
+		return nil, err
+	}
+	if err := validateVerify(config.Verify, config.VerifyCommand); err != nil {
+		return nil, err
+	}
+	if err := validateToolOutput(config.ToolOutput); err != nil {
+		return nil, fmt.Errorf("invalid 'tool_output': %w", err)
+	}
+	for name, schedule := range config.Schedules {
+		if err := schedule.parse(name); err != nil {
+			return nil, err
+		}
+	}
+	for name, backend := range config.Backends {
+		if err := backend.validate(name); err != nil {
+			return nil, err
+		}
+	}
 	return &config, nil
 }
 
@@ -148,11 +209,15 @@ func loadTasks(runnerDir string) (map[string]Task, error) {
 
 		task.normalize()
 		task.Agent = expandTilde(task.Agent)
+		expandAgentTildes(task.Agents)
 
 		// Apply defaults
 		if task.Timeout == 0 {
 			task.Timeout = 1 * time.Hour
 		}
+		if task.ContinuationTimeout == 0 {
+			task.ContinuationTimeout = 10 * time.Minute
+		}
 
 		if task.CandidateSource == "" {
 			return nil, fmt.Errorf("task %s missing required field 'candidate_source'", entry.Name())
@@ -163,6 +228,57 @@ func loadTasks(runnerDir string) (map[string]Task, error) {
 		if task.Prompt != "" && task.Template != "" {
 			return nil, fmt.Errorf("task %s cannot have both 'prompt' and 'template'", entry.Name())
 		}
+		if task.MaxRepairRounds < 0 {
+			return nil, fmt.Errorf("task %s 'max_repair_rounds' cannot be negative", entry.Name())
+		}
+		if _, err := ParseOrder(task.Order); err != nil {
+			return nil, fmt.Errorf("task %s has invalid 'order': %w", entry.Name(), err)
+		}
+		if task.AttemptsPerCandidate < 0 {
+			return nil, fmt.Errorf("task %s 'attempts_per_candidate' cannot be negative", entry.Name())
+		}
+		switch task.Selection {
+		case "", SelectionSmallestDiff:
+		case SelectionScore:
+			if task.ScoreCommand == "" {
+				return nil, fmt.Errorf("task %s 'selection: score' requires 'score_command'", entry.Name())
+			}
+		default:
+			return nil, fmt.Errorf("task %s has invalid 'selection' %q (expected smallest_diff or score)", entry.Name(), task.Selection)
+		}
+		switch task.AcceptIf {
+		case "":
+		case AcceptDecreased, AcceptIncreased, AcceptUnchangedOrBetter:
+			if task.ScoreCommand == "" {
+				return nil, fmt.Errorf("task %s 'accept_if' requires 'score_command'", entry.Name())
+			}
+		default:
+			return nil, fmt.Errorf("task %s has invalid 'accept_if' %q (expected decreased, increased or unchanged_or_better)", entry.Name(), task.AcceptIf)
+		}
+		if task.BatchSize < 0 {
+			return nil, fmt.Errorf("task %s 'batch_size' cannot be negative", entry.Name())
+		}
+		if task.BatchBy != "" && task.BatchSize < 2 {
+			return nil, fmt.Errorf("task %s 'batch_by' requires 'batch_size' of 2 or more", entry.Name())
+		}
+		if task.MaxCost < 0 {
+			return nil, fmt.Errorf("task %s 'max_cost' cannot be negative", entry.Name())
+		}
+		if task.MaxTokens < 0 {
+			return nil, fmt.Errorf("task %s 'max_tokens' cannot be negative", entry.Name())
+		}
+		if task.TimeoutContinuations < 0 {
+			return nil, fmt.Errorf("task %s 'timeout_continuations' cannot be negative", entry.Name())
+		}
+		if task.IdleTimeout < 0 {
+			return nil, fmt.Errorf("task %s 'idle_timeout' cannot be negative", entry.Name())
+		}
+		if err := validateVerify(task.Verify, task.VerifyCommand); err != nil {
+			return nil, fmt.Errorf("task %s %w", entry.Name(), err)
+		}
+		if err := task.Guardrails.validate(); err != nil {
+			return nil, fmt.Errorf("task %s %w", entry.Name(), err)
+		}
 
 		tasks[task.Name] = *task
 	}
@@ -184,6 +300,9 @@ func loadTask(path string) (*Task, error) {
 	}
 
 	task.normalize()
+	if err := validateAgents(task.Agent, task.Agents); err != nil {
+		return nil, err
+	}
 	return &task, nil
 }
 
@@ -60,6 +60,130 @@ agent: "claude"
 `,
 			wantErr: false,
 		},
+		{
+			name: "agent fallback chain",
+			yaml: `
+agents:
+  - agent: "claude"
+    agent_flags: "--model opus"
+  - agent: "codex"
+`,
+			wantErr: false,
+		},
+		{
+			name: "agent and agents together",
+			yaml: `
+agent: "claude"
+agents:
+  - agent: "codex"
+`,
+			wantErr: true,
+		},
+		{
+			name: "agents entry without agent",
+			yaml: `
+agents:
+  - agent_flags: "--fast"
+`,
+			wantErr: true,
+		},
+		{
+			name: "schedules",
+			yaml: `
+schedules:
+  standup:
+    timezone: "Europe/London"
+    days: [mon, tue, wed, thu, fri]
+    start: "09:30"
+    end: "10:00"
+  nights:
+    start: "22:00"
+    end: "07:00"
+    mode: allow
+`,
+			wantErr: false,
+		},
+		{
+			name: "hooks",
+			yaml: `
+after_candidate: "git clean -fd"
+on_run_complete: "notify-send done"
+`,
+			wantErr: false,
+		},
+		{
+			name: "schedule with invalid mode",
+			yaml: `
+schedules:
+  standup:
+    mode: "maybe"
+`,
+			wantErr: true,
+		},
+		{
+			name: "verify stages",
+			yaml: `
+verify:
+  - name: quick
+    command: "go vet ./..."
+    timeout: 2m
+  - name: full
+    command: "go test ./..."
+`,
+			wantErr: false,
+		},
+		{
+			name: "verify stage without command",
+			yaml: `
+verify:
+  - name: quick
+`,
+			wantErr: true,
+		},
+		{
+			name: "custom backend",
+			yaml: `
+agent: "aider"
+backend: aider
+backends:
+  aider:
+    display_name: Aider
+    command: "$AGENT $FLAGS --message $PROMPT"
+    text: "delta.text"
+    done: "type == done"
+    error: "type == error"
+    error_message: "error.message"
+    rate_limit: ["Rate limit reached", "/retry in \\d+s/"]
+`,
+			wantErr: false,
+		},
+		{
+			name: "custom backend redefining a built-in",
+			yaml: `
+backends:
+  claude:
+    command: "claude -p $PROMPT"
+`,
+			wantErr: true,
+		},
+		{
+			name: "custom backend with invalid rate limit regex",
+			yaml: `
+backends:
+  aider:
+    rate_limit: ["/retry in (/"]
+`,
+			wantErr: true,
+		},
+		{
+			name: "verify and verify_command together",
+			yaml: `
+verify_command: "make"
+verify:
+  - command: "make test"
+`,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -156,7 +280,26 @@ candidate_source: "cargo check 2>&1 | grep error"
 prompt: "Fix this issue: $INPUT"
 agent_flags: "--fast"
 agent: "/custom/codex"
+backend: codex
 accept_best_effort: true
+idle_timeout: "10m"
+max_repair_rounds: 2
+repair_prompt: "Fix the build: $VERIFY_OUTPUT"
+order: "by_field:priority:desc"
+attempts_per_candidate: 3
+selection: smallest_diff
+score_command: "wc -c < out.bin"
+accept_if: decreased
+no_regressions: true
+max_changed_files: 5
+allowed_paths_from: $INPUT["file"]
+forbidden_paths: ["**/*_test.go"]
+batch_size: 5
+batch_by: file
+schedules: [standup]
+before_candidate: "make headers"
+on_failure: "echo $OUTCOME"
+verify_command: "go vet $CANDIDATE"
 `,
 			wantErr: false,
 		},
@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -17,6 +18,7 @@ import (
 // timeoutError indicates AI execution timed out
 type timeoutError struct {
 	duration time.Duration
+	idle     bool // The agent went quiet for duration, rather than running out of time
 }
 
 // StreamCallback is called for each chunk of text received from the AI backend.
@@ -45,6 +47,9 @@ func commandEnv(extraEnv []string) []string {
 }
 
 func (e *timeoutError) Error() string {
+	if e.idle {
+		return fmt.Sprintf("no output for %s", e.duration)
+	}
 	return fmt.Sprintf("timeout after %s", e.duration)
 }
 
@@ -52,8 +57,50 @@ func (e *timeoutError) IsTimeout() bool {
 	return true
 }
 
+// idleTailLines is how many lines of raw output are logged when an agent is
+// killed for going quiet.
+const idleTailLines = 20
+
+// outputTail keeps the last lines of an agent's raw output.
+type outputTail struct {
+	lines []string
+	max   int
+}
+
+func (t *outputTail) Add(line string) {
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+}
+
+func (t *outputTail) String() string {
+	return strings.Join(t.lines, "\n")
+}
+
+// logIdleTimeout records why an idle agent was killed, with the last lines it
+// printed, so a wedged agent can be diagnosed from the agent log.
+func logIdleTimeout(w io.Writer, idle time.Duration, tail, stderr string) {
+	if w == nil {
+		return
+	}
+	fmt.Fprintf(w, "Idle timeout: no output for %s. Last lines of output:\n", idle)
+	if tail == "" {
+		tail = "(none)"
+	}
+	fmt.Fprintln(w, tail)
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		lines := strings.Split(stderr, "\n")
+		if len(lines) > idleTailLines {
+			lines = lines[len(lines)-idleTailLines:]
+		}
+		fmt.Fprintf(w, "Stderr:\n%s\n", strings.Join(lines, "\n"))
+	}
+}
+
 // RunCandidateSource executes a candidate source command and returns its stdout.
-func RunCandidateSource(source, workDir string, extraEnv ...[]string) ([]byte, error) {
+// The process is registered with procs (or the default tracker when nil) while it runs.
+func RunCandidateSource(procs *ProcessTracker, source, workDir string, extraEnv ...[]string) ([]byte, error) {
 	cmd := exec.Command("bash", "-c", source)
 	cmd.Dir = workDir
 	env := []string(nil)
@@ -67,6 +114,9 @@ func RunCandidateSource(source, workDir string, extraEnv ...[]string) ([]byte, e
 	// kill the candidate source and surface as a spurious failure. Track the
 	// process so SIGINT can still tear it down via KillRunningProcess.
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if procs == nil {
+		procs = defaultProcessTracker
+	}
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -75,9 +125,9 @@ func RunCandidateSource(source, workDir string, extraEnv ...[]string) ([]byte, e
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("candidate source failed: %w\nstderr: %s", err, stderr.String())
 	}
-	runningProcess = cmd.Process
+	procs.Set(cmd.Process)
 	err := cmd.Wait()
-	runningProcess = nil
+	procs.Clear()
 
 	if err != nil {
 		return nil, fmt.Errorf("candidate source failed: %w\nstderr: %s", err, stderr.String())
@@ -86,23 +136,106 @@ func RunCandidateSource(source, workDir string, extraEnv ...[]string) ([]byte, e
 	return stdout.Bytes(), nil
 }
 
-// runningProcess tracks the currently running AI process for signal forwarding
-var runningProcess *os.Process
+// ProcessTracker tracks the child process a single runner is waiting on so it
+// can be torn down on timeout or interrupt. Each worker owns its own tracker.
+type ProcessTracker struct {
+	mu      sync.Mutex
+	process *os.Process
+}
+
+var (
+	trackersMu sync.Mutex
+	trackers   []*ProcessTracker
 
-// KillRunningProcess terminates the running AI process if any
-func KillRunningProcess() {
-	if p := runningProcess; p != nil {
+	// defaultProcessTracker is used when callers don't supply their own tracker.
+	defaultProcessTracker = NewProcessTracker()
+)
+
+// NewProcessTracker creates a tracker and registers it with KillRunningProcess.
+func NewProcessTracker() *ProcessTracker {
+	t := &ProcessTracker{}
+	trackersMu.Lock()
+	trackers = append(trackers, t)
+	trackersMu.Unlock()
+	return t
+}
+
+// Set records p as the currently running process.
+func (t *ProcessTracker) Set(p *os.Process) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.process = p
+}
+
+// Clear forgets the currently running process.
+func (t *ProcessTracker) Clear() {
+	t.Set(nil)
+}
+
+// Kill terminates the tracked process group, if any.
+func (t *ProcessTracker) Kill() {
+	t.mu.Lock()
+	p := t.process
+	t.mu.Unlock()
+	if p != nil {
 		// Kill the entire process group
 		syscall.Kill(-p.Pid, syscall.SIGTERM)
 	}
 }
 
+// KillRunningProcess terminates the running processes of every tracker.
+func KillRunningProcess() {
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+	for _, t := range trackers {
+		t.Kill()
+	}
+}
+
+// AIRequest describes one agent session.
+type AIRequest struct {
+	Backend     Backend
+	BaseCmd     string
+	ExtraFlags  string
+	Prompt      string
+	SessionID   string // When set, resume this session instead of starting a new one
+	WorkDir     string
+	LogWriter   io.Writer
+	Timeout     time.Duration
+	IdleTimeout time.Duration // Kill the agent when no output line arrives for this long (0 = never)
+	ExtraEnv    []string
+	StreamCb    StreamCallback  // Invoked for each chunk of text received
+	ToolCb      func(ToolEvent) // Invoked for each finished tool call
+	Procs       *ProcessTracker // Tracks the process while it runs (default tracker when nil)
+}
+
+// AIResult is what RunAICommand collected from a session.
+type AIResult struct {
+	Errors    string // Errors the backend reported, plus stderr (for rate limit detection)
+	SessionID string // Last session ID reported by the backend
+	Usage     Usage  // Token usage reported by the backend
+	ExitCode  int    // Exit code of the agent command; -1 if it was killed
+}
+
 // RunAICommand executes an AI command with prompt, timeout, and streaming output.
-// The streamCb callback is invoked for each chunk of text received.
-// Returns the accumulated output (for rate limit detection) and any error.
-func RunAICommand(backend Backend, baseCmd, extraFlags, prompt, workDir string, logWriter io.Writer, timeout time.Duration, extraEnv []string, streamCb StreamCallback) (string, error) {
+// Returns what was collected from the session (even on error) and any error.
+func RunAICommand(req AIRequest) (AIResult, error) {
+	backend := req.Backend
+	if direct, ok := backend.(DirectBackend); ok {
+		return direct.Run(req)
+	}
+	logWriter := req.LogWriter
+	streamCb := req.StreamCb
+	procs := req.Procs
+	if procs == nil {
+		procs = defaultProcessTracker
+	}
+
 	// Build the command via the backend
-	cmdStr := backend.BuildCommand(baseCmd, extraFlags, prompt)
+	cmdStr := backend.BuildCommand(req.BaseCmd, req.ExtraFlags, req.Prompt)
+	if req.SessionID != "" {
+		cmdStr = backend.ResumeCommand(req.BaseCmd, req.ExtraFlags, req.SessionID, req.Prompt)
+	}
 
 	// Log the exact command being executed (for debugging hangs)
 	if logWriter != nil {
@@ -112,8 +245,8 @@ func RunAICommand(backend Backend, baseCmd, extraFlags, prompt, workDir string,
 	args := []string{"-c", cmdStr}
 
 	cmd := exec.Command("bash", args...)
-	cmd.Dir = workDir
-	cmd.Env = commandEnv(extraEnv)
+	cmd.Dir = req.WorkDir
+	cmd.Env = commandEnv(req.ExtraEnv)
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setpgid:   true,
 		Pdeathsig: syscall.SIGTERM,
@@ -122,7 +255,7 @@ func RunAICommand(backend Backend, baseCmd, extraFlags, prompt, workDir string,
 	// Create pipe for stdout so we can read line-by-line
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
-		return "", err
+		return AIResult{}, err
 	}
 
 	// Capture stderr to buffer
@@ -131,36 +264,67 @@ func RunAICommand(backend Backend, baseCmd, extraFlags, prompt, workDir string,
 
 	// Start the process and track it for signal forwarding
 	if err := cmd.Start(); err != nil {
-		return "", err
+		return AIResult{}, err
 	}
-	runningProcess = cmd.Process
+	procs.Set(cmd.Process)
 
 	// Goroutine to read stdout line-by-line and delegate parsing to the backend
 	type streamResult struct {
-		fullOutput string
-		err        error
+		errors    string
+		sessionID string
+		usage     Usage
+		tail      string
+		err       error
 	}
 	resultCh := make(chan streamResult, 1)
+	activity := make(chan struct{}, 1)
+	finished := make(chan struct{})
 
 	go func() {
-		var fullOutput strings.Builder
+		tail := outputTail{max: idleTailLines}
+		var reported strings.Builder
+		var sessionID string
+		var usage Usage
 		scanner := bufio.NewScanner(stdoutPipe)
 		scanner.Buffer(nil, 10*1024*1024) // 10MB max token size
 
 		for scanner.Scan() {
 			line := scanner.Text()
+			tail.Add(line)
+			select {
+			case activity <- struct{}{}:
+			default:
+			}
 
-			text, done := backend.ProcessLine(line)
-			if text != "" {
+			ev := backend.ProcessLine(line)
+			if ev.SessionID != "" {
+				sessionID = ev.SessionID
+			}
+			if ev.Usage != nil {
+				usage.Add(*ev.Usage)
+			}
+			if ev.Error != "" {
+				reported.WriteString(ev.Error + "\n")
+			}
+			for _, tool := range ev.Tools {
+				if req.ToolCb != nil {
+					req.ToolCb(tool)
+				}
+				if logWriter != nil {
+					fmt.Fprintln(logWriter, tool.Summary(req.WorkDir))
+				}
+			}
+			if ev.Text != "" {
 				if streamCb != nil {
-					streamCb(text)
+					streamCb(ev.Text)
 				}
 				if logWriter != nil {
-					fmt.Fprint(logWriter, text)
+					fmt.Fprint(logWriter, ev.Text)
 				}
-				fullOutput.WriteString(text)
 			}
-			if done {
+			if ev.Done {
+				// The session is over, so silence from here on is not a hang
+				close(finished)
 				// Drain remaining output to avoid blocking the process
 				for scanner.Scan() {
 					if logWriter != nil {
@@ -171,10 +335,9 @@ func RunAICommand(backend Backend, baseCmd, extraFlags, prompt, workDir string,
 			}
 
 			// Log raw line for debugging if it wasn't consumed as stream text
-			if text == "" && logWriter != nil {
+			if ev.Text == "" && logWriter != nil {
 				fmt.Fprintln(logWriter, line)
 			}
-			fullOutput.WriteString(line + "\n")
 		}
 
 		// Add a final newline after streaming is complete
@@ -185,50 +348,90 @@ func RunAICommand(backend Backend, baseCmd, extraFlags, prompt, workDir string,
 			fmt.Fprintln(logWriter)
 		}
 
-		// Include stderr in output for rate limit detection
-		if stderrBuf.Len() > 0 {
-			fullOutput.WriteString(stderrBuf.String())
-		}
-
 		resultCh <- streamResult{
-			fullOutput: fullOutput.String(),
-			err:        scanner.Err(),
+			errors:    reported.String(),
+			sessionID: sessionID,
+			usage:     usage,
+			tail:      tail.String(),
+			err:       scanner.Err(),
 		}
 	}()
 
-	// Wait for completion or timeout
-	var waitErr error
-	if timeout > 0 {
-		done := make(chan error, 1)
-		go func() {
-			done <- cmd.Wait()
-		}()
+	// Wait for the stream to finish (or time out) before reaping the process:
+	// cmd.Wait closes the stdout pipe, so calling it first could cut the
+	// reader off mid-stream.
+	var deadline, idle <-chan time.Time
+	if req.Timeout > 0 {
+		deadline = time.After(req.Timeout)
+	}
+	var idleTimer *time.Timer
+	if req.IdleTimeout > 0 {
+		idleTimer = time.NewTimer(req.IdleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
 
+	var result streamResult
+	timedOut, idledOut := false, false
+	for waiting := true; waiting; {
 		select {
-		case <-time.After(timeout):
-			KillRunningProcess()
-			runningProcess = nil
-			// Wait for the stream reader to finish
-			result := <-resultCh
-			return result.fullOutput, &timeoutError{duration: timeout}
-		case waitErr = <-done:
-			runningProcess = nil
+		case <-deadline:
+			procs.Kill()
+			timedOut = true
+			result = <-resultCh
+			waiting = false
+		case <-idle:
+			procs.Kill()
+			idledOut = true
+			result = <-resultCh
+			waiting = false
+		case <-activity:
+			if idleTimer != nil {
+				if !idleTimer.Stop() {
+					select {
+					case <-idleTimer.C:
+					default:
+					}
+				}
+				idleTimer.Reset(req.IdleTimeout)
+			}
+		case <-finished:
+			if idleTimer != nil {
+				idleTimer.Stop()
+				idleTimer, idle = nil, nil
+			}
+			finished = nil
+		case result = <-resultCh:
+			waiting = false
 		}
-	} else {
-		waitErr = cmd.Wait()
-		runningProcess = nil
+	}
+	waitErr := cmd.Wait()
+	procs.Clear()
+
+	// Rate limits are only looked for in what the CLI reported as errors, never
+	// in the model's own text
+	aiResult := AIResult{
+		Errors:    result.errors + stderrBuf.String(),
+		SessionID: result.sessionID,
+		Usage:     result.usage,
+		ExitCode:  cmd.ProcessState.ExitCode(),
 	}
 
-	// Get the full output from the stream reader
-	result := <-resultCh
+	if timedOut {
+		return aiResult, &timeoutError{duration: req.Timeout}
+	}
+	if idledOut {
+		logIdleTimeout(logWriter, req.IdleTimeout, result.tail, stderrBuf.String())
+		return aiResult, &timeoutError{duration: req.IdleTimeout, idle: true}
+	}
 	if result.err != nil {
-		return result.fullOutput, result.err
+		return aiResult, result.err
 	}
 	if waitErr != nil && stderrBuf.Len() > 0 {
-		return result.fullOutput, fmt.Errorf("%w\nstderr: %s", waitErr, strings.TrimSpace(stderrBuf.String()))
+		return aiResult, fmt.Errorf("%w\nstderr: %s", waitErr, strings.TrimSpace(stderrBuf.String()))
 	}
 
-	return result.fullOutput, waitErr
+	return aiResult, waitErr
 }
 
 // Regex patterns for $INPUT interpolation
@@ -390,6 +593,43 @@ func InterpolateCommand(command string, candidate *Candidate, taskName string) s
 	return result
 }
 
+// commandVarRe matches the variables InterpolateCandidateCommand replaces.
+var commandVarRe = regexp.MustCompile(`\$INPUT\[[^\]]*\]|\$INPUT\b|\$CANDIDATE|\$TASK_NAME|\$TASK_ID`)
+
+// InterpolateCandidateCommand replaces template variables in a command run
+// for a candidate. Supports $CANDIDATE, $TASK_NAME, $TASK_ID and the $INPUT
+// forms of InterpolatePrompt. $CANDIDATE and $INPUT values are shell-quoted;
+// for a batch, each $INPUT form expands to every member's value, quoted
+// separately and joined with spaces. Variables are replaced in one pass, so
+// candidate text is never itself interpolated.
+func InterpolateCandidateCommand(command string, candidate *Candidate, taskName string, taskID int64) (string, error) {
+	var firstErr error
+	result := commandVarRe.ReplaceAllStringFunc(command, func(variable string) string {
+		switch variable {
+		case "$CANDIDATE":
+			return shellQuote(candidate.Key)
+		case "$TASK_NAME":
+			return taskName
+		case "$TASK_ID":
+			return strconv.FormatInt(taskID, 10)
+		}
+		members := candidate.members()
+		values := make([]string, len(members))
+		for i := range members {
+			value, err := InterpolatePrompt(variable, &members[i], taskID)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			values[i] = shellQuote(value)
+		}
+		return strings.Join(values, " ")
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return result, nil
+}
+
 // LoadTemplate reads a template file and returns its contents.
 func LoadTemplate(path string) (string, error) {
 	data, err := os.ReadFile(path)
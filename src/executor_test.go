@@ -348,6 +348,54 @@ func TestInterpolateCommand(t *testing.T) {
 	}
 }
 
+func TestInterpolateCandidateCommand(t *testing.T) {
+	batch := batchCandidate([]Candidate{
+		{Key: "a", Data: json.RawMessage(`{"file":"a b.go"}`)},
+		{Key: "b", Data: json.RawMessage(`{"file":"c.go"}`)},
+	})
+
+	tests := []struct {
+		name      string
+		command   string
+		candidate *Candidate
+		want      string
+	}{
+		{"path with a space", `go vet $INPUT["file"]`,
+			&Candidate{Key: "a b.go", Data: json.RawMessage(`{"file":"a b.go"}`)},
+			`go vet 'a b.go'`},
+		{"shell code in the candidate", "echo $INPUT",
+			&Candidate{Key: "x", Data: json.RawMessage(`"$(touch pwned); it's"`)},
+			`echo '$(touch pwned); it'"'"'s'`},
+		{"candidate text is not interpolated", "echo $INPUT[0] $TASK_NAME",
+			&Candidate{Key: "x", Data: json.RawMessage(`["$CANDIDATE"]`)},
+			`echo '$CANDIDATE' lint`},
+		{"other variables", "run $CANDIDATE $TASK_ID",
+			&Candidate{Key: "k", Data: json.RawMessage(`"k"`)},
+			"run 'k' 7"},
+		{"batch expands to every member", `go vet $INPUT["file"] # $CANDIDATE`, batch,
+			`go vet 'a b.go' 'c.go' # 'a, b'`},
+		{"missing key", `echo $INPUT["line"]`,
+			&Candidate{Key: "k", Data: json.RawMessage(`{"file":"a.go"}`)},
+			"echo ''"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InterpolateCandidateCommand(tt.command, tt.candidate, "lint", 7)
+			if err != nil {
+				t.Fatalf("InterpolateCandidateCommand() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("InterpolateCandidateCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := InterpolateCandidateCommand("echo $INPUT[0]", &Candidate{Key: "k", Data: json.RawMessage(`"k"`)}, "lint", 7); err == nil {
+		t.Error("expected an error for an array index on a string candidate")
+	}
+}
+
 func TestLargeJSONLineParsing(t *testing.T) {
 	// Test that scanner can handle lines larger than default 64KB buffer
 	// This verifies the fix for "bufio.Scanner: token too long" error
@@ -593,20 +641,32 @@ func (b stderrBackend) BuildCommand(baseCmd, extraFlags, prompt string) string {
 	return "echo hidden-reason >&2; exit 7"
 }
 
-func (b stderrBackend) ProcessLine(line string) (string, bool) {
-	return "", false
+func (b stderrBackend) ResumeCommand(baseCmd, extraFlags, sessionID, prompt string) string {
+	return b.BuildCommand(baseCmd, extraFlags, prompt)
+}
+
+func (b stderrBackend) ProcessLine(line string) LineEvent {
+	return LineEvent{}
 }
 
 func (b stderrBackend) RateLimitPhrases() []string {
 	return nil
 }
 
+func (b stderrBackend) RateLimitReset(output string, now time.Time) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (b stderrBackend) ClassifyFailure(errors string, exitCode int) Failure {
+	return Failure{}
+}
+
 func (b stderrBackend) DisplayName() string {
 	return "Test"
 }
 
 func TestRunAICommandIncludesStderrOnFailure(t *testing.T) {
-	_, err := RunAICommand(stderrBackend{}, "", "", "", ".", nil, 0, nil, nil)
+	_, err := RunAICommand(AIRequest{Backend: stderrBackend{}, WorkDir: "."})
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -659,6 +719,7 @@ func TestCommandEnvFiltersParentTimeout(t *testing.T) {
 
 func TestRunCandidateSourceReceivesTimeoutEnv(t *testing.T) {
 	output, err := RunCandidateSource(
+		nil,
 		`printf '%s:%s' "$NIGEL_TIMEOUT_SECONDS" "$NIGEL_TIMEOUT_DEADLINE_UNIX"`,
 		".",
 		timeoutEnv(2*time.Minute, time.Unix(333, 0)),
@@ -682,7 +743,7 @@ func TestRunCandidateSourceRunsInOwnProcessGroup(t *testing.T) {
 	source := `child_pgid=$(ps -o pgid= -p $$ | tr -d ' ')
 parent_pgid=$(ps -o pgid= -p $PPID | tr -d ' ')
 printf '%s:%s' "$child_pgid" "$parent_pgid"`
-	output, err := RunCandidateSource(source, ".")
+	output, err := RunCandidateSource(nil, source, ".")
 	if err != nil {
 		t.Fatalf("RunCandidateSource() error = %v", err)
 	}
@@ -705,31 +766,109 @@ func (b envBackend) BuildCommand(baseCmd, extraFlags, prompt string) string {
 	return `printf '%s:%s\n' "$NIGEL_TIMEOUT_SECONDS" "$NIGEL_TIMEOUT_DEADLINE_UNIX"`
 }
 
-func (b envBackend) ProcessLine(line string) (string, bool) {
-	return line, true
+func (b envBackend) ResumeCommand(baseCmd, extraFlags, sessionID, prompt string) string {
+	return b.BuildCommand(baseCmd, extraFlags, prompt)
+}
+
+func (b envBackend) ProcessLine(line string) LineEvent {
+	return LineEvent{Text: line, Done: true}
 }
 
 func (b envBackend) RateLimitPhrases() []string {
 	return nil
 }
 
+func (b envBackend) RateLimitReset(output string, now time.Time) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (b envBackend) ClassifyFailure(errors string, exitCode int) Failure {
+	return Failure{}
+}
+
 func (b envBackend) DisplayName() string {
 	return "Test"
 }
 
 func TestRunAICommandReceivesTimeoutEnv(t *testing.T) {
-	output, err := RunAICommand(
-		envBackend{},
-		"", "", "", ".",
-		nil,
-		90*time.Second,
-		timeoutEnv(90*time.Second, time.Unix(444, 0)),
-		nil,
-	)
+	var log bytes.Buffer
+	_, err := RunAICommand(AIRequest{
+		Backend:   envBackend{},
+		WorkDir:   ".",
+		Timeout:   90 * time.Second,
+		ExtraEnv:  timeoutEnv(90*time.Second, time.Unix(444, 0)),
+		LogWriter: &log,
+	})
 	if err != nil {
 		t.Fatalf("RunAICommand() error = %v", err)
 	}
-	if !strings.Contains(output, "90:444") {
-		t.Fatalf("output = %q, want timeout env", output)
+	if !strings.Contains(log.String(), "90:444") {
+		t.Fatalf("log = %q, want timeout env", log.String())
+	}
+}
+
+// shellBackend runs a fixed script in place of the agent, parsing its output
+// with the embedded backend.
+type shellBackend struct {
+	Backend
+	script string
+}
+
+func (b shellBackend) BuildCommand(baseCmd, extraFlags, prompt string) string {
+	return b.script
+}
+
+func TestRunAICommandIdleTimeout(t *testing.T) {
+	var log bytes.Buffer
+	start := time.Now()
+	_, err := RunAICommand(AIRequest{
+		Backend:     shellBackend{Backend: &ClaudeBackend{}, script: "echo line-1; echo line-2; echo stuck >&2; sleep 10"},
+		WorkDir:     ".",
+		Timeout:     time.Minute,
+		IdleTimeout: 200 * time.Millisecond,
+		LogWriter:   &log,
+	})
+
+	timeoutErr, ok := err.(*timeoutError)
+	if !ok || !timeoutErr.idle {
+		t.Fatalf("RunAICommand() error = %v, want an idle timeoutError", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("idle agent ran for %s", elapsed)
+	}
+	for _, want := range []string{"Idle timeout: no output for 200ms", "line-1\nline-2\n", "Stderr:\nstuck"} {
+		if !strings.Contains(log.String(), want) {
+			t.Errorf("log missing %q:\n%s", want, log.String())
+		}
+	}
+}
+
+func TestRunAICommandIdleTimeoutResetsOnOutput(t *testing.T) {
+	var log bytes.Buffer
+	_, err := RunAICommand(AIRequest{
+		Backend:     shellBackend{Backend: &ClaudeBackend{}, script: "for i in 1 2 3 4 5; do echo tick; sleep 0.1; done"},
+		WorkDir:     ".",
+		IdleTimeout: 300 * time.Millisecond,
+		LogWriter:   &log,
+	})
+	if err != nil {
+		t.Fatalf("RunAICommand() error = %v, want the steady agent to finish", err)
+	}
+	if n := strings.Count(log.String(), "tick\n"); n != 5 {
+		t.Errorf("output has %d ticks, want 5", n)
+	}
+}
+
+func TestRunAICommandIdleTimeoutStopsOnceDone(t *testing.T) {
+	result, err := RunAICommand(AIRequest{
+		Backend:     shellBackend{Backend: &ClaudeBackend{}, script: `echo '{"type":"result","subtype":"success","result":"done"}'; sleep 0.5`},
+		WorkDir:     ".",
+		IdleTimeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("RunAICommand() error = %v, want the finished session to succeed", err)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("exit code = %d, want 0", result.ExitCode)
 	}
 }
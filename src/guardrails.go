@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Guardrails limit the changes an agent may make for a candidate. They are
+// checked once the agent's work has passed verify and re-check, before it is
+// committed; a violation resets the changes.
+type Guardrails struct {
+	MaxChangedFiles  int      `yaml:"max_changed_files"`  // Most files a change may touch (0 = no limit)
+	MaxChangedLines  int      `yaml:"max_changed_lines"`  // Most lines a change may add plus delete (0 = no limit)
+	AllowedPaths     []string `yaml:"allowed_paths"`      // Globs the changed files must match
+	ForbiddenPaths   []string `yaml:"forbidden_paths"`    // Globs no changed file may match
+	AllowedPathsFrom string   `yaml:"allowed_paths_from"` // Allowed globs from the candidate, e.g. $INPUT["file"]
+}
+
+// enabled reports whether any guardrail is set.
+func (g Guardrails) enabled() bool {
+	return g.MaxChangedFiles > 0 || g.MaxChangedLines > 0 || len(g.AllowedPaths) > 0 ||
+		len(g.ForbiddenPaths) > 0 || g.AllowedPathsFrom != ""
+}
+
+// validate checks the guardrails from task.yaml.
+func (g Guardrails) validate() error {
+	if g.MaxChangedFiles < 0 {
+		return fmt.Errorf("'max_changed_files' cannot be negative")
+	}
+	if g.MaxChangedLines < 0 {
+		return fmt.Errorf("'max_changed_lines' cannot be negative")
+	}
+	for _, pattern := range append(append([]string{}, g.AllowedPaths...), g.ForbiddenPaths...) {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("path globs cannot be empty")
+		}
+	}
+	return nil
+}
+
+// fileChange is one changed file in the working tree and how many lines it
+// adds plus deletes.
+type fileChange struct {
+	path    string
+	lines   int
+	outside bool // The file is outside the project directory, e.g. "../verify.sh"
+}
+
+// changedFiles lists the uncommitted changes in the whole repository that
+// contains dir, including untracked files, since the success command usually
+// commits them all. Paths are relative to dir.
+func changedFiles(dir string) ([]fileChange, error) {
+	root, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := gitOutput(dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	change := func(path string, lines int) fileChange {
+		if strings.HasPrefix(path, prefix) {
+			return fileChange{path: strings.TrimPrefix(path, prefix), lines: lines}
+		}
+		up := strings.Repeat("../", strings.Count(prefix, "/"))
+		return fileChange{path: up + path, lines: lines, outside: true}
+	}
+
+	numstat, err := gitOutput(root, "diff", "--numstat", "--no-renames", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []fileChange
+	for _, line := range strings.Split(numstat, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		// Binary files show "-" and count as one line
+		added, err1 := strconv.Atoi(fields[0])
+		deleted, err2 := strconv.Atoi(fields[1])
+		lines := added + deleted
+		if err1 != nil || err2 != nil {
+			lines = 1
+		}
+		changes = append(changes, change(fields[2], lines))
+	}
+
+	untracked, err := gitOutput(root, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range strings.Split(untracked, "\n") {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Count(string(data), "\n")
+		if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+			lines++
+		}
+		changes = append(changes, change(path, lines))
+	}
+	return changes, nil
+}
+
+// globRe converts a path glob to a regular expression. `*` and `?` stay within
+// one path segment, `**` spans segments, and a pattern also matches everything
+// under it, so "docs" covers "docs/a.md".
+func globRe(pattern string) *regexp.Regexp {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(pattern), "./"), "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("(?:/.*)?$")
+	return regexp.MustCompile(re.String())
+}
+
+// matchesAny reports whether path matches any of the globs.
+func matchesAny(globs []string, path string) bool {
+	for _, glob := range globs {
+		if globRe(glob).MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedPaths returns the allowed globs for candidate: allowed_paths plus
+// allowed_paths_from interpolated for each candidate (or batch member), split
+// on whitespace. Returns nil when neither is set, allowing every path.
+func (r *Runner) allowedPaths(candidate *Candidate) ([]string, error) {
+	g := r.task.Guardrails
+	if g.AllowedPathsFrom == "" {
+		return g.AllowedPaths, nil
+	}
+	allowed := append([]string{}, g.AllowedPaths...)
+	for _, member := range candidate.members() {
+		value, err := InterpolatePrompt(g.AllowedPathsFrom, &member, r.env.TaskID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to interpolate 'allowed_paths_from': %w", err)
+		}
+		allowed = append(allowed, strings.Fields(value)...)
+	}
+	return allowed, nil
+}
+
+// guardrailViolations checks the working tree against the task's guardrails
+// and describes each violation, naming the offending paths.
+func (r *Runner) guardrailViolations(candidate *Candidate) ([]string, error) {
+	g := r.task.Guardrails
+	if !g.enabled() {
+		return nil, nil
+	}
+
+	changes, err := changedFiles(r.env.ProjectDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+	allowed, err := r.allowedPaths(candidate)
+	if err != nil {
+		return nil, err
+	}
+	restricted := g.AllowedPathsFrom != "" || len(g.AllowedPaths) > 0
+
+	var violations, outsideProject, forbidden, outside []string
+	lines := 0
+	for _, change := range changes {
+		lines += change.lines
+		if change.outside {
+			outsideProject = append(outsideProject, change.path)
+		} else if matchesAny(g.ForbiddenPaths, change.path) {
+			forbidden = append(forbidden, change.path)
+		} else if restricted && !matchesAny(allowed, change.path) {
+			outside = append(outside, change.path)
+		}
+	}
+
+	if g.MaxChangedFiles > 0 && len(changes) > g.MaxChangedFiles {
+		violations = append(violations, fmt.Sprintf("%d files changed (max %d)", len(changes), g.MaxChangedFiles))
+	}
+	if g.MaxChangedLines > 0 && lines > g.MaxChangedLines {
+		violations = append(violations, fmt.Sprintf("%d lines changed (max %d)", lines, g.MaxChangedLines))
+	}
+	if len(outsideProject) > 0 {
+		violations = append(violations, "paths outside the project changed: "+strings.Join(outsideProject, ", "))
+	}
+	if len(forbidden) > 0 {
+		violations = append(violations, "forbidden paths changed: "+strings.Join(forbidden, ", "))
+	}
+	if len(outside) > 0 {
+		violations = append(violations, "paths outside allowed_paths changed: "+strings.Join(outside, ", "))
+	}
+	return violations, nil
+}
+
+// handleGuardrailViolation resets changes that broke the guardrails and logs
+// the violations.
+func (r *Runner) handleGuardrailViolation(candidate *Candidate, violations []string) (bool, error) {
+	fmt.Fprintln(r.console(), ColorError(fmt.Sprintf("✗ Candidate %s broke the guardrails:", candidate.Key)))
+	for _, violation := range violations {
+		fmt.Fprintf(r.console(), "  - %s\n", violation)
+	}
+
+	if !r.runResetAndVerify() {
+		return false, &fatalError{msg: "failed to reset"}
+	}
+	r.logOutcome(OutcomeGuardrailViolation, "reverted: "+strings.Join(violations, "; "))
+
+	if err := r.ignoreCandidate(candidate); err != nil {
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGlobRe(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"src/*.go", "src/a.go", true},
+		{"src/*.go", "src/sub/a.go", false},
+		{"src/**/*.go", "src/a.go", true},
+		{"src/**/*.go", "src/sub/deep/a.go", true},
+		{"**/*_test.go", "pkg/a_test.go", true},
+		{"**/*_test.go", "a_test.go", true},
+		{"docs", "docs/guide/a.md", true},
+		{"docs/", "docs/a.md", true},
+		{"docs", "docs.md", false},
+		{"./Makefile", "Makefile", true},
+		{"file?.txt", "file1.txt", true},
+		{"a.go", "a_go", false},
+	}
+
+	for _, tt := range tests {
+		if got := globRe(tt.pattern).MatchString(tt.path); got != tt.want {
+			t.Errorf("globRe(%q) matches %q = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+// newGuardedRunner returns a scripted runner whose project directory is a git
+// repository with a committed a.go, main_test.go and verify.sh.
+func newGuardedRunner(t *testing.T, agentScript string, guardrails Guardrails) (*Runner, string) {
+	t.Helper()
+
+	runner, dir := newScriptedRunner(t, agentScript,
+		Task{
+			CandidateSource: `if [ -f fixed ]; then echo '[]'; else echo '[{"file":"a.go"}]'; fi`,
+			Guardrails:      guardrails,
+		},
+		Config{
+			ResetCommand:   "git checkout -- . && git clean -fdq",
+			SuccessCommand: "git add -A && git commit -qm fix",
+		},
+	)
+
+	files := map[string]string{
+		".gitignore":   "test-task/\nfake-agent\n.calls\n.prompt-*\n",
+		"a.go":         "package a\n",
+		"main_test.go": "package a\n",
+		"verify.sh":    "true\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qm", "init")
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	return runner, dir
+}
+
+func TestChangedFiles(t *testing.T) {
+	_, dir := newGuardedRunner(t, "", Guardrails{})
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package b\n\nfunc B() {}\n"), 0644)
+	os.Remove(filepath.Join(dir, "verify.sh"))
+	os.WriteFile(filepath.Join(dir, "new.go"), []byte("one\ntwo"), 0644)
+
+	changes, err := changedFiles(dir)
+	if err != nil {
+		t.Fatalf("changedFiles failed: %v", err)
+	}
+	want := []fileChange{{path: "a.go", lines: 4}, {path: "verify.sh", lines: 1}, {path: "new.go", lines: 2}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changedFiles() = %v, want %v", changes, want)
+	}
+}
+
+func TestChangedFilesOutsideProject(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "app")
+	for name, content := range map[string]string{
+		"app/a.go":          "package a\n",
+		"scripts/verify.sh": "true\n",
+	} {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, root, "init", "-q")
+	runGit(t, root, "add", "-A")
+	runGit(t, root, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qm", "init")
+
+	os.WriteFile(filepath.Join(project, "a.go"), []byte("package b\n"), 0644)
+	os.WriteFile(filepath.Join(root, "scripts", "verify.sh"), []byte("exit 0\n"), 0644)
+	os.WriteFile(filepath.Join(root, "scripts", "new.sh"), []byte("true\n"), 0644)
+
+	changes, err := changedFiles(project)
+	if err != nil {
+		t.Fatalf("changedFiles failed: %v", err)
+	}
+	want := []fileChange{
+		{path: "a.go", lines: 2},
+		{path: "../scripts/verify.sh", lines: 2, outside: true},
+		{path: "../scripts/new.sh", lines: 1, outside: true},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changedFiles() = %v, want %v", changes, want)
+	}
+
+	// Changes outside the project count against the limits and are a violation
+	// of their own, whatever the globs say
+	runner := &Runner{
+		env:  &Environment{ProjectDir: project},
+		task: Task{Guardrails: Guardrails{MaxChangedFiles: 2, ForbiddenPaths: []string{"**/*_test.go"}}},
+	}
+	violations, err := runner.guardrailViolations(&Candidate{Key: "c1"})
+	if err != nil {
+		t.Fatalf("guardrailViolations failed: %v", err)
+	}
+	wantViolations := []string{
+		"3 files changed (max 2)",
+		"paths outside the project changed: ../scripts/verify.sh, ../scripts/new.sh",
+	}
+	if !reflect.DeepEqual(violations, wantViolations) {
+		t.Errorf("guardrailViolations() = %q, want %q", violations, wantViolations)
+	}
+}
+
+func TestRunIterationGuardrailViolation(t *testing.T) {
+	tests := []struct {
+		name       string
+		guardrails Guardrails
+		want       string
+	}{
+		{
+			name:       "forbidden path",
+			guardrails: Guardrails{ForbiddenPaths: []string{"**/*_test.go", "verify.sh"}},
+			want:       "forbidden paths changed: main_test.go, verify.sh",
+		},
+		{
+			name:       "outside allowed_paths_from",
+			guardrails: Guardrails{AllowedPathsFrom: `$INPUT["file"]`},
+			want:       "paths outside allowed_paths changed: main_test.go, verify.sh, fixed",
+		},
+		{
+			name:       "too many files",
+			guardrails: Guardrails{MaxChangedFiles: 2},
+			want:       "4 files changed (max 2)",
+		},
+		{
+			name:       "too many lines",
+			guardrails: Guardrails{MaxChangedLines: 3},
+			want:       "4 lines changed (max 3)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, dir := newGuardedRunner(t,
+				"echo fix >> a.go; rm main_test.go; echo exit 0 > verify.sh; touch fixed",
+				tt.guardrails)
+
+			stdout := captureStdout(t, func() {
+				if _, err := runner.runIteration(); err != nil {
+					t.Fatalf("runIteration failed: %v", err)
+				}
+			})
+
+			if runner.outcome != OutcomeGuardrailViolation {
+				t.Errorf("outcome = %s, want %s", runner.outcome, OutcomeGuardrailViolation)
+			}
+			if !strings.Contains(stdout, tt.want) {
+				t.Errorf("stdout missing %q:\n%s", tt.want, stdout)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "main_test.go")); err != nil {
+				t.Error("changes were not reset")
+			}
+			log, _ := os.ReadFile(runner.agentLogger.Path())
+			if !strings.Contains(string(log), "Outcome: GUARDRAIL_VIOLATION") || !strings.Contains(string(log), tt.want) {
+				t.Errorf("agent log missing the violation:\n%s", log)
+			}
+		})
+	}
+}
+
+func TestRunIterationWithinGuardrails(t *testing.T) {
+	runner, dir := newGuardedRunner(t, "echo fix >> a.go; touch fixed", Guardrails{
+		MaxChangedFiles:  2,
+		MaxChangedLines:  2,
+		AllowedPaths:     []string{"fixed"},
+		AllowedPathsFrom: `$INPUT["file"]`,
+		ForbiddenPaths:   []string{"**/*_test.go"},
+	})
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	if runner.outcome != OutcomeFixed {
+		t.Errorf("outcome = %s, want %s", runner.outcome, OutcomeFixed)
+	}
+	if log, err := gitOutput(dir, "log", "--oneline"); err != nil || !strings.Contains(log, "fix") {
+		t.Errorf("expected the fix to be committed, git log: %q (%v)", log, err)
+	}
+}
+
+func TestRecoverInterruptedRunChecksGuardrails(t *testing.T) {
+	runner, dir := newGuardedRunner(t, "", Guardrails{ForbiddenPaths: []string{"**/*_test.go"}})
+	runner.opts.Resume = true
+	runner.input = strings.NewReader("c\n")
+
+	// Leftover changes from an agent that was interrupted after fixing c1
+	os.Remove(filepath.Join(dir, "main_test.go"))
+	os.WriteFile(filepath.Join(dir, "fixed"), nil, 0644)
+	if err := SaveRunState(runner.statePath, &RunState{Candidate: "c1", Data: []byte(`{"file":"a.go"}`), Phase: PhaseRecheck}); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := captureStdout(t, func() {
+		if err := runner.recoverInterruptedRun(); err != nil {
+			t.Fatalf("recoverInterruptedRun failed: %v", err)
+		}
+	})
+
+	if runner.outcome != OutcomeGuardrailViolation {
+		t.Errorf("outcome = %s, want %s", runner.outcome, OutcomeGuardrailViolation)
+	}
+	if want := "forbidden paths changed: main_test.go"; !strings.Contains(stdout, want) {
+		t.Errorf("stdout missing %q:\n%s", want, stdout)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "main_test.go")); err != nil {
+		t.Error("leftover changes were not reset")
+	}
+	if log, _ := gitOutput(dir, "log", "--oneline"); strings.Contains(log, "fix") {
+		t.Errorf("leftover changes were committed despite the violation, git log: %q", log)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hooks are shell commands run around each candidate and at the end of a run.
+// They can be set in config.yaml and task.yaml; task-level hooks win.
+type Hooks struct {
+	BeforeCandidate string `yaml:"before_candidate"` // Before the agent sees a candidate; failing skips it
+	AfterCandidate  string `yaml:"after_candidate"`  // After every candidate, whatever the outcome
+	OnFailure       string `yaml:"on_failure"`       // When a candidate is not fixed
+	OnTimeout       string `yaml:"on_timeout"`       // When the agent times out on a candidate
+	OnBestEffort    string `yaml:"on_best_effort"`   // When partial progress is committed
+	OnRunComplete   string `yaml:"on_run_complete"`  // Once the run finishes
+}
+
+// merge returns h with any unset hooks taken from fallback.
+func (h Hooks) merge(fallback Hooks) Hooks {
+	pick := func(a, b string) string {
+		if a != "" {
+			return a
+		}
+		return b
+	}
+	return Hooks{
+		BeforeCandidate: pick(h.BeforeCandidate, fallback.BeforeCandidate),
+		AfterCandidate:  pick(h.AfterCandidate, fallback.AfterCandidate),
+		OnFailure:       pick(h.OnFailure, fallback.OnFailure),
+		OnTimeout:       pick(h.OnTimeout, fallback.OnTimeout),
+		OnBestEffort:    pick(h.OnBestEffort, fallback.OnBestEffort),
+		OnRunComplete:   pick(h.OnRunComplete, fallback.OnRunComplete),
+	}
+}
+
+// hooks returns the hooks for this run: task-level > global config.
+func (r *Runner) hooks() Hooks {
+	return r.task.Hooks.merge(r.env.Config.Hooks)
+}
+
+// runHook runs a hook command, reporting whether it succeeded. Hook failures
+// are shown but never stop the run.
+func (r *Runner) runHook(name, command, dir string) bool {
+	if command == "" {
+		return true
+	}
+	fmt.Fprintln(r.console(), ColorInfo(fmt.Sprintf("Running %s hook...", name)))
+	ok, err := r.executor.Run(command, dir)
+	if err != nil || !ok {
+		msg := fmt.Sprintf("%s hook failed", name)
+		if err != nil {
+			msg += ": " + err.Error()
+		}
+		fmt.Fprintln(r.console(), ColorWarning(msg))
+		return false
+	}
+	return true
+}
+
+// hookCommand interpolates $CANDIDATE, $TASK_NAME, $OUTCOME and $DURATION
+// (whole seconds) into a candidate hook.
+func (r *Runner) hookCommand(command string, candidate *Candidate, outcome Outcome, duration time.Duration) string {
+	return strings.NewReplacer(
+		"$OUTCOME", string(outcome),
+		"$DURATION", strconv.Itoa(int(duration.Seconds())),
+	).Replace(InterpolateCommand(command, candidate, r.task.Name))
+}
+
+// runBeforeCandidateHook runs before_candidate. When it fails the candidate is
+// skipped: the tree is reset and the candidate is marked as attempted so the
+// run moves on to the next one.
+func (r *Runner) runBeforeCandidateHook(candidate *Candidate) (bool, error) {
+	command := r.hooks().BeforeCandidate
+	if command == "" {
+		return true, nil
+	}
+	if r.runHook("before_candidate", r.hookCommand(command, candidate, "", 0), r.env.ProjectDir) {
+		return true, nil
+	}
+
+	fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("Skipping candidate %s", candidate.Key)))
+	if !r.runReset() {
+		return false, &fatalError{msg: "failed to reset after before_candidate hook failed"}
+	}
+	return false, r.ignoreCandidate(candidate)
+}
+
+// runCandidateHooks runs the hooks that follow a candidate: on_timeout,
+// on_failure or on_best_effort depending on how it went, then after_candidate.
+func (r *Runner) runCandidateHooks(candidate *Candidate, duration time.Duration) {
+	hooks := r.hooks()
+	outcome := r.outcome
+	if outcome == "" {
+		outcome = OutcomeError
+	}
+
+	run := func(name, command string) {
+		if command != "" {
+			r.runHook(name, r.hookCommand(command, candidate, outcome, duration), r.env.ProjectDir)
+		}
+	}
+
+	if r.timedOut {
+		run("on_timeout", hooks.OnTimeout)
+	}
+	switch outcome {
+	case OutcomeNotFixed, OutcomeBuildFailed, OutcomeFixedReverted, OutcomeGuardrailViolation, OutcomeAgentFailed:
+		if !r.timedOut {
+			run("on_failure", hooks.OnFailure)
+		}
+	case OutcomeBestEffort:
+		run("on_best_effort", hooks.OnBestEffort)
+	}
+	run("after_candidate", hooks.AfterCandidate)
+}
+
+// runCompleteHook runs on_run_complete in dir with $TASK_NAME, $DURATION
+// (whole seconds) and $ITERATIONS.
+func (r *Runner) runCompleteHook(dir string, duration time.Duration, iterations int) {
+	command := r.hooks().OnRunComplete
+	if command == "" || r.opts.DryRun {
+		return
+	}
+	command = strings.NewReplacer(
+		"$TASK_NAME", r.task.Name,
+		"$DURATION", strconv.Itoa(int(duration.Seconds())),
+		"$ITERATIONS", strconv.Itoa(iterations),
+	).Replace(command)
+	r.runHook("on_run_complete", command, dir)
+}
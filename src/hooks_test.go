@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunIterationRunsCandidateHooks(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`true`,
+		Task{
+			CandidateSource: `echo '["c1"]'`,
+			Hooks: Hooks{
+				BeforeCandidate: `echo before $CANDIDATE >> hooks.log`,
+				OnFailure:       `echo failure $OUTCOME >> hooks.log`,
+				OnBestEffort:    `echo best effort >> hooks.log`,
+			},
+		},
+		Config{
+			ResetCommand: "true",
+			Hooks: Hooks{
+				AfterCandidate: `echo after $TASK_NAME $OUTCOME $DURATION >> hooks.log`,
+				OnFailure:      `echo global failure >> hooks.log`,
+			},
+		},
+	)
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	log, _ := os.ReadFile(filepath.Join(dir, "hooks.log"))
+	want := "before c1\nfailure NOT_FIXED\nafter test-task NOT_FIXED 0\n"
+	if string(log) != want {
+		t.Errorf("hooks ran:\n%s\nwant:\n%s", log, want)
+	}
+}
+
+func TestFailingBeforeHookSkipsCandidate(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`true`,
+		Task{
+			CandidateSource: `echo '["c1", "c2"]'`,
+			Hooks: Hooks{
+				BeforeCandidate: `[ $CANDIDATE != c1 ]`,
+				AfterCandidate:  `echo after $CANDIDATE >> hooks.log`,
+			},
+		},
+		Config{ResetCommand: "touch reset"},
+	)
+
+	captureStdout(t, func() {
+		done, err := runner.runIteration()
+		if err != nil || done {
+			t.Fatalf("runIteration = %v, %v; want the run to continue", done, err)
+		}
+	})
+
+	if _, err := os.Stat(filepath.Join(dir, ".calls")); err == nil {
+		t.Error("agent ran although before_candidate failed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reset")); err != nil {
+		t.Error("expected a reset after before_candidate failed")
+	}
+	if !runner.ignoredList.Contains("c1") {
+		t.Error("expected the skipped candidate to be marked as attempted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hooks.log")); err == nil {
+		t.Error("after_candidate ran for a skipped candidate")
+	}
+}
+
+func TestRunCompleteHook(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`true`,
+		Task{
+			CandidateSource: `echo '[]'`,
+			Hooks:           Hooks{OnRunComplete: `echo $TASK_NAME $ITERATIONS > complete`},
+		},
+		Config{ResetCommand: "true"},
+	)
+
+	captureStdout(t, func() {
+		if err := runner.loop(); err != nil {
+			t.Fatalf("loop failed: %v", err)
+		}
+	})
+
+	complete, err := os.ReadFile(filepath.Join(dir, "complete"))
+	if err != nil {
+		t.Fatal("on_run_complete did not run")
+	}
+	if strings.TrimSpace(string(complete)) != "test-task 1" {
+		t.Errorf("on_run_complete got %q", complete)
+	}
+}
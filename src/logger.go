@@ -4,20 +4,29 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
-const separator = "================================================================================"
+const (
+	separator    = "================================================================================"
+	subSeparator = "--------------------------------------------------------------------------------"
+)
 
 // Outcome represents the result of processing a candidate.
 type Outcome string
 
 const (
-	OutcomeFixed         Outcome = "FIXED"
-	OutcomeFixedReverted Outcome = "FIXED_BUT_REVERTED" // Fixed but build failed, had to revert
-	OutcomeNotFixed      Outcome = "NOT_FIXED"
-	OutcomeBestEffort    Outcome = "BEST_EFFORT" // Not fixed but partial progress committed
-	OutcomeBuildFailed   Outcome = "BUILD_FAILED"
+	OutcomeFixed              Outcome = "FIXED"
+	OutcomeFixedReverted      Outcome = "FIXED_BUT_REVERTED" // Fixed but build failed, had to revert
+	OutcomeNotFixed           Outcome = "NOT_FIXED"
+	OutcomeBestEffort         Outcome = "BEST_EFFORT" // Not fixed but partial progress committed
+	OutcomeBuildFailed        Outcome = "BUILD_FAILED"
+	OutcomeError              Outcome = "ERROR"               // Stopped by an error before an outcome was reached
+	OutcomeCollateralFix      Outcome = "COLLATERAL_FIX"      // Disappeared along with another candidate's fix
+	OutcomeGuardrailViolation Outcome = "GUARDRAIL_VIOLATION" // Changes broke the task's guardrails, had to revert
+	OutcomeAgentFailed        Outcome = "AGENT_FAILED"        // The agent failed in a way retrying this candidate won't fix
+	OutcomeIdleTimeout        Outcome = "IDLE_TIMEOUT"        // The agent went quiet for idle_timeout and was killed, had to revert
 )
 
 // AgentLogger handles logging of agent interactions.
@@ -35,9 +44,29 @@ func AgentLogPath(taskDir string) string {
 	return filepath.Join(taskDir, "agent.log")
 }
 
+// WorkerLogPath returns the per-worker variant of a task file path,
+// e.g. agent.log becomes agent.worker-2.log.
+func WorkerLogPath(path string, worker int) string {
+	return workerPath(path, fmt.Sprint(worker))
+}
+
+func workerPath(path, worker string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".worker-" + worker + ext
+}
+
 // NewAgentLogger creates a new logger for agent interactions.
 func NewAgentLogger(taskDir string) (*AgentLogger, error) {
-	path := AgentLogPath(taskDir)
+	return openAgentLogger(AgentLogPath(taskDir))
+}
+
+// NewWorkerAgentLogger creates a logger for one worker of a parallel run, so
+// concurrent sessions don't interleave in a single file.
+func NewWorkerAgentLogger(taskDir string, worker int) (*AgentLogger, error) {
+	return openAgentLogger(WorkerLogPath(AgentLogPath(taskDir), worker))
+}
+
+func openAgentLogger(path string) (*AgentLogger, error) {
 	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open agent log: %w", err)
@@ -46,21 +75,45 @@ func NewAgentLogger(taskDir string) (*AgentLogger, error) {
 	return &AgentLogger{file: file}, nil
 }
 
-// StartEntry begins a new log entry with timestamp and prompt.
-func (l *AgentLogger) StartEntry(prompt string) error {
+// StartEntry begins a new log entry with timestamp, the agent handling the
+// candidate, and the prompt.
+func (l *AgentLogger) StartEntry(agent, prompt string) error {
 	l.startTime = time.Now()
 	timestamp := l.startTime.Format("2006-01-02 15:04:05")
 
-	_, err := fmt.Fprintf(l.file, "\n%s\nTimestamp: %s\nPrompt: %s\n%s\n",
-		separator, timestamp, prompt, separator)
+	_, err := fmt.Fprintf(l.file, "\n%s\nTimestamp: %s\nAgent: %s\nPrompt: %s\n%s\n",
+		separator, timestamp, agent, prompt, separator)
+	return err
+}
+
+// StartSubEntry begins a follow-up section within the current entry, such as a
+// repair round, with its own title and prompt.
+func (l *AgentLogger) StartSubEntry(title, prompt string) error {
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+
+	_, err := fmt.Fprintf(l.file, "\n%s\n%s\nTimestamp: %s\nPrompt: %s\n%s\n",
+		subSeparator, title, timestamp, prompt, subSeparator)
 	return err
 }
 
-// LogOutcome logs the result of processing the candidate.
-func (l *AgentLogger) LogOutcome(outcome Outcome, details string) error {
+// LogOutcome logs the result of processing the candidate, and the agent usage
+// when the backend reported any.
+func (l *AgentLogger) LogOutcome(outcome Outcome, details string, usage Usage) error {
 	duration := time.Since(l.startTime)
 	_, err := fmt.Fprintf(l.file, "\n%s\nOutcome: %s\nDuration: %s\nDetails: %s\n",
 		separator, outcome, formatDuration(duration), details)
+	if err != nil || usage.IsZero() {
+		return err
+	}
+	_, err = fmt.Fprintf(l.file, "Usage: %s\n", usage)
+	return err
+}
+
+// LogCollateralFix logs a candidate that was fixed as a side effect of the
+// change for trigger.
+func (l *AgentLogger) LogCollateralFix(candidate, trigger string) error {
+	_, err := fmt.Fprintf(l.file, "\n%s\nOutcome: %s\nCandidate: %s\nFixed by: %s\n",
+		separator, OutcomeCollateralFix, candidate, trigger)
 	return err
 }
 
@@ -46,3 +46,11 @@ func TestNewAgentLoggerCreatesAgentLog(t *testing.T) {
 		t.Fatalf("agent log was not created: %v", err)
 	}
 }
+
+func TestWorkerLogPath(t *testing.T) {
+	got := WorkerLogPath(filepath.Join("nigel", "task", "agent.log"), 2)
+	want := filepath.Join("nigel", "task", "agent.worker-2.log")
+	if got != want {
+		t.Fatalf("WorkerLogPath() = %q, want %q", got, want)
+	}
+}
@@ -23,8 +23,17 @@ func main() {
 	dryRunFlag := flag.Bool("dry-run", false, "Print prompt without executing the agent")
 	verboseFlag := flag.Bool("verbose", false, "Print verbose output")
 	shardFlag := flag.String("shard", "", "Shard index/total (e.g. 1/4 for first of 4 workers)")
-	offPeakOnlyFlag := flag.Bool("off-peak-only", false, "Only run during off-peak hours (pauses during 8AM-2PM ET on weekdays)")
-	chinaOffPeakOnlyFlag := flag.Bool("china-off-peak-only", false, "Only run during China off-peak hours (pauses during 14:00-18:00 UTC+8 daily)")
+	workersFlag := flag.Int("workers", 0, "Run N parallel workers, each in its own git worktree")
+	offPeakOnlyFlag := flag.Bool("off-peak-only", false, "Only run during off-peak hours (same as --schedule off-peak)")
+	orderFlag := flag.String("order", "", "Candidate order: source, reverse, random[:seed], by_field:<key>[:desc], fewest_attempts (overrides task.yaml)")
+	resumeFlag := flag.Bool("resume", false, "Offer to verify and commit work left behind by an interrupted run")
+	watchFlag := flag.Bool("watch", false, "Keep running when no candidates remain, polling for new ones")
+	pollFlag := flag.Duration("poll", DefaultPollInterval, "How often --watch re-runs the candidate source (e.g. 5m, 30s)")
+	maxCostFlag := flag.Float64("max-cost", 0, "Stop once agents have cost this many USD (0 = unlimited, overrides task.yaml)")
+	maxTokensFlag := flag.Int64("max-tokens", 0, "Stop once agents have used this many tokens (0 = unlimited, overrides task.yaml)")
+	scheduleFlag := flag.String("schedule", "", "Comma-separated schedules from config.yaml that pause the run (overrides task.yaml)")
+	toolOutputFlag := flag.String("tool-output", "", "How agent tool calls are shown: hide, summary or full (overrides config.yaml)")
+	chinaOffPeakOnlyFlag := flag.Bool("china-off-peak-only", false, "Only run during China off-peak hours (same as --schedule china-off-peak)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: nigel <task> [options]\n")
@@ -82,21 +91,83 @@ func main() {
 		partition = HashPartition{WorkerCount: total, WorkerIndex: index - 1} // Convert to 0-based internally
 	}
 
+	if *workersFlag < 0 {
+		fmt.Fprintln(os.Stderr, ColorError("Error: --workers must be positive"))
+		os.Exit(1)
+	}
+	if *workersFlag > 1 && *shardFlag != "" {
+		fmt.Fprintln(os.Stderr, ColorError("Error: --workers cannot be combined with --shard"))
+		os.Exit(1)
+	}
+
+	if *watchFlag && *dryRunFlag {
+		fmt.Fprintln(os.Stderr, ColorError("Error: --watch cannot be combined with --dry-run"))
+		os.Exit(1)
+	}
+
+	if *pollFlag <= 0 {
+		fmt.Fprintln(os.Stderr, ColorError("Error: --poll must be positive"))
+		os.Exit(1)
+	}
+
+	if *maxCostFlag < 0 || *maxTokensFlag < 0 {
+		fmt.Fprintln(os.Stderr, ColorError("Error: --max-cost and --max-tokens cannot be negative"))
+		os.Exit(1)
+	}
+
+	if err := validateToolOutput(*toolOutputFlag); err != nil {
+		fmt.Fprintln(os.Stderr, ColorError(fmt.Sprintf("Error: --tool-output: %v", err)))
+		os.Exit(1)
+	}
+
+	// The off-peak flags select predefined schedules
+	var schedules []string
+	for _, name := range strings.Split(*scheduleFlag, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			schedules = append(schedules, name)
+		}
+	}
+	if *offPeakOnlyFlag {
+		schedules = append(schedules, "off-peak")
+	}
+	if *chinaOffPeakOnlyFlag {
+		schedules = append(schedules, "china-off-peak")
+	}
+
 	agent := resolveAlias(*agentFlag, *claudeCommandFlag)
 	agentFlags := resolveAlias(*agentFlagsFlag, *claudeFlagsFlag)
 
 	// Create and run the runner
 	opts := RunnerOptions{
-		Limit:            *limitFlag,
-		TimeLimit:        *timeLimitFlag,
-		DryRun:           *dryRunFlag,
-		Verbose:          *verboseFlag,
-		Partition:        partition,
-		Timeout:          *taskTimeoutFlag,
-		Agent:            agent,
-		AgentFlags:       agentFlags,
-		OffPeakOnly:      *offPeakOnlyFlag,
-		ChinaOffPeakOnly: *chinaOffPeakOnlyFlag,
+		Limit:      *limitFlag,
+		TimeLimit:  *timeLimitFlag,
+		DryRun:     *dryRunFlag,
+		Verbose:    *verboseFlag,
+		Partition:  partition,
+		Timeout:    *taskTimeoutFlag,
+		Agent:      agent,
+		AgentFlags: agentFlags,
+		Schedules:  schedules,
+		Resume:     *resumeFlag,
+		Order:      *orderFlag,
+		Watch:      *watchFlag,
+		Poll:       *pollFlag,
+		MaxCost:    *maxCostFlag,
+		MaxTokens:  *maxTokensFlag,
+		ToolOutput: *toolOutputFlag,
+	}
+
+	if *workersFlag > 1 {
+		pool, err := NewWorkerPool(env, taskName, opts, *workersFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, ColorError(fmt.Sprintf("Error: %v", err)))
+			os.Exit(1)
+		}
+		if err := pool.Run(); err != nil {
+			fmt.Fprintln(os.Stderr, ColorError(fmt.Sprintf("Error: %v", err)))
+			os.Exit(1)
+		}
+		return
 	}
 
 	runner, err := NewRunner(env, taskName, opts)
@@ -153,7 +224,10 @@ func reorderArgs(args []string) []string {
 					"-task-timeout", "--task-timeout", "-agent", "--agent",
 					"-agent-flags", "--agent-flags", "-claude-command", "--claude-command",
 					"-claude-flags", "--claude-flags",
-					"-shard", "--shard":
+					"-shard", "--shard", "-workers", "--workers",
+					"-order", "--order", "-poll", "--poll", "-schedule", "--schedule",
+					"-max-cost", "--max-cost", "-max-tokens", "--max-tokens",
+					"-tool-output", "--tool-output":
 					i++
 					flags = append(flags, args[i])
 				}
@@ -36,6 +36,11 @@ func TestReorderArgsBooleanOffPeakFlags(t *testing.T) {
 			args: []string{"mytask", "--agent", "codex", "--agent-flags", "--yolo"},
 			want: []string{"--agent", "codex", "--agent-flags", "--yolo", "mytask"},
 		},
+		{
+			name: "workers after task",
+			args: []string{"mytask", "--workers", "4"},
+			want: []string{"--workers", "4", "mytask"},
+		},
 		{
 			name: "legacy claude flags after task",
 			args: []string{"mytask", "--claude-command", "claude", "--claude-flags", "--fast"},
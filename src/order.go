@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Order strategies for choosing which candidate to work on next.
+const (
+	OrderSource         = "source"          // Candidate source order (default)
+	OrderReverse        = "reverse"         // Candidate source order, last first
+	OrderRandom         = "random"          // Shuffled, optionally with a fixed seed
+	OrderByField        = "by_field"        // Sorted by a map candidate field
+	OrderFewestAttempts = "fewest_attempts" // Least-attempted candidates first
+)
+
+// Order describes how candidates are sorted before one is selected.
+type Order struct {
+	Strategy string
+	Seed     int64  // random: shuffle seed
+	Field    string // by_field: map key to sort on
+	Desc     bool   // by_field: sort descending
+}
+
+// ParseOrder parses an order spec:
+//
+//	source | reverse | random[:seed] | by_field:<key>[:asc|:desc] | fewest_attempts
+//
+// An empty spec means source order. random without a seed picks one from the
+// clock, so the order is stable for the run but differs between runs.
+func ParseOrder(spec string) (Order, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	strategy := parts[0]
+	args := parts[1:]
+
+	switch strategy {
+	case "", OrderSource, OrderReverse, OrderFewestAttempts:
+		if len(args) > 0 {
+			return Order{}, fmt.Errorf("order %q takes no arguments", strategy)
+		}
+		if strategy == "" {
+			strategy = OrderSource
+		}
+		return Order{Strategy: strategy}, nil
+
+	case OrderRandom:
+		if len(args) > 1 {
+			return Order{}, fmt.Errorf("invalid order %q: expected random[:seed]", spec)
+		}
+		seed := time.Now().UnixNano()
+		if len(args) == 1 {
+			n, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return Order{}, fmt.Errorf("invalid random seed %q", args[0])
+			}
+			seed = n
+		}
+		return Order{Strategy: OrderRandom, Seed: seed}, nil
+
+	case OrderByField:
+		if len(args) == 0 || len(args) > 2 || args[0] == "" {
+			return Order{}, fmt.Errorf("invalid order %q: expected by_field:<key>[:asc|:desc]", spec)
+		}
+		order := Order{Strategy: OrderByField, Field: args[0]}
+		if len(args) == 2 {
+			switch args[1] {
+			case "asc":
+			case "desc":
+				order.Desc = true
+			default:
+				return Order{}, fmt.Errorf("invalid sort direction %q: expected asc or desc", args[1])
+			}
+		}
+		return order, nil
+	}
+
+	return Order{}, fmt.Errorf("unknown order %q (expected source, reverse, random, by_field or fewest_attempts)", strategy)
+}
+
+// String returns the order in ParseOrder syntax.
+func (o Order) String() string {
+	switch o.Strategy {
+	case OrderRandom:
+		return fmt.Sprintf("%s:%d", o.Strategy, o.Seed)
+	case OrderByField:
+		if o.Desc {
+			return o.Strategy + ":" + o.Field + ":desc"
+		}
+		return o.Strategy + ":" + o.Field
+	case "":
+		return OrderSource
+	}
+	return o.Strategy
+}
+
+// Apply returns candidates sorted by the order. The input slice is not
+// modified. Ties keep their candidate source order.
+func (o Order) Apply(candidates []Candidate, ignored *IgnoredList) []Candidate {
+	sorted := make([]Candidate, len(candidates))
+	copy(sorted, candidates)
+
+	switch o.Strategy {
+	case OrderReverse:
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+
+	case OrderRandom:
+		// Rank by a seeded hash of the key rather than shuffling the slice, so
+		// a candidate keeps its place as others come and go between iterations.
+		rank := func(c Candidate) uint64 {
+			hash := md5.Sum([]byte(strconv.FormatInt(o.Seed, 10) + "\x00" + c.Key))
+			return binary.LittleEndian.Uint64(hash[:8])
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return rank(sorted[i]) < rank(sorted[j])
+		})
+
+	case OrderByField:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, aok := sorted[i].GetKey(o.Field)
+			b, bok := sorted[j].GetKey(o.Field)
+			if !aok || !bok {
+				// Candidates without the field go last
+				return aok && !bok
+			}
+			if o.Desc {
+				return compareFieldValues(b, a) < 0
+			}
+			return compareFieldValues(a, b) < 0
+		})
+
+	case OrderFewestAttempts:
+		if ignored == nil {
+			break
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return ignored.Attempts(sorted[i].Key) < ignored.Attempts(sorted[j].Key)
+		})
+	}
+
+	return sorted
+}
+
+// compareFieldValues compares two field values, numerically when both are
+// numbers and as strings otherwise.
+func compareFieldValues(a, b string) int {
+	af, aerr := strconv.ParseFloat(a, 64)
+	bf, berr := strconv.ParseFloat(b, 64)
+	if aerr == nil && berr == nil {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseOrder(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Order
+		wantErr bool
+	}{
+		{spec: "", want: Order{Strategy: OrderSource}},
+		{spec: "source", want: Order{Strategy: OrderSource}},
+		{spec: "reverse", want: Order{Strategy: OrderReverse}},
+		{spec: "fewest_attempts", want: Order{Strategy: OrderFewestAttempts}},
+		{spec: "random:42", want: Order{Strategy: OrderRandom, Seed: 42}},
+		{spec: "by_field:priority", want: Order{Strategy: OrderByField, Field: "priority"}},
+		{spec: "by_field:priority:asc", want: Order{Strategy: OrderByField, Field: "priority"}},
+		{spec: "by_field:priority:desc", want: Order{Strategy: OrderByField, Field: "priority", Desc: true}},
+		{spec: "random:abc", wantErr: true},
+		{spec: "by_field", wantErr: true},
+		{spec: "by_field:priority:sideways", wantErr: true},
+		{spec: "reverse:1", wantErr: true},
+		{spec: "alphabetical", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseOrder(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOrder(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseOrder(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOrderRandomWithoutSeed(t *testing.T) {
+	order, err := ParseOrder("random")
+	if err != nil {
+		t.Fatalf("ParseOrder failed: %v", err)
+	}
+	if order.Seed == 0 {
+		t.Error("expected a seed to be picked")
+	}
+	if reparsed, _ := ParseOrder(order.String()); reparsed != order {
+		t.Errorf("String() = %q does not round-trip", order.String())
+	}
+}
+
+func keysOf(candidates []Candidate) []string {
+	keys := make([]string, len(candidates))
+	for i, c := range candidates {
+		keys[i] = c.Key
+	}
+	return keys
+}
+
+func TestOrderApply(t *testing.T) {
+	maps := []Candidate{
+		{Key: "a", Data: json.RawMessage(`{"name":"a","priority":2}`)},
+		{Key: "b", Data: json.RawMessage(`{"name":"b"}`)},
+		{Key: "c", Data: json.RawMessage(`{"name":"c","priority":10}`)},
+		{Key: "d", Data: json.RawMessage(`{"name":"d","priority":1}`)},
+	}
+
+	tests := []struct {
+		name  string
+		order Order
+		want  []string
+	}{
+		{name: "source", order: Order{Strategy: OrderSource}, want: []string{"a", "b", "c", "d"}},
+		{name: "reverse", order: Order{Strategy: OrderReverse}, want: []string{"d", "c", "b", "a"}},
+		{name: "by_field numeric ascending", order: Order{Strategy: OrderByField, Field: "priority"}, want: []string{"d", "a", "c", "b"}},
+		{name: "by_field descending", order: Order{Strategy: OrderByField, Field: "priority", Desc: true}, want: []string{"c", "a", "d", "b"}},
+		{name: "by_field strings", order: Order{Strategy: OrderByField, Field: "name", Desc: true}, want: []string{"d", "c", "b", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := keysOf(tt.order.Apply(maps, nil))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := keysOf(maps); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Apply() modified its input: %v", got)
+	}
+}
+
+func TestOrderApplyRandomIsStablePerSeed(t *testing.T) {
+	candidates := mustParseCandidates(t, `["a","b","c","d","e","f","g","h"]`)
+	order := Order{Strategy: OrderRandom, Seed: 7}
+
+	first := keysOf(order.Apply(candidates, nil))
+	if !reflect.DeepEqual(first, keysOf(order.Apply(candidates, nil))) {
+		t.Fatal("same seed produced different orders")
+	}
+	if reflect.DeepEqual(first, keysOf(candidates)) {
+		t.Error("random order matched source order")
+	}
+
+	// Removing a candidate keeps the relative order of the rest
+	var remaining []string
+	for _, key := range first {
+		if key != "c" {
+			remaining = append(remaining, key)
+		}
+	}
+	without := keysOf(order.Apply(mustParseCandidates(t, `["a","b","d","e","f","g","h"]`), nil))
+	if !reflect.DeepEqual(without, remaining) {
+		t.Errorf("order after removal = %v, want %v", without, remaining)
+	}
+}
+
+func TestOrderApplyFewestAttempts(t *testing.T) {
+	ignored, err := NewIgnoredList(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignored.SetMaxRepeat(5)
+	ignored.Add("a")
+	ignored.Add("a")
+	ignored.Add("b")
+
+	candidates := mustParseCandidates(t, `["a","b","c"]`)
+	got := keysOf(Order{Strategy: OrderFewestAttempts}.Apply(candidates, ignored))
+	if want := []string{"c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply() = %v, want %v", got, want)
+	}
+}
+
+func mustParseCandidates(t *testing.T, data string) []Candidate {
+	t.Helper()
+	candidates, err := ParseCandidates([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return candidates
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateLimitJitter is the most extra time added after a parsed reset, so
+// several runs waiting on one limit don't all retry in the same second.
+const rateLimitJitter = time.Minute
+
+var (
+	// "resets 3pm (America/New_York)", "resets at 10:30am"
+	resetClockRe = regexp.MustCompile(`(?i)resets\s+(?:at\s+)?(\d{1,2})(?::(\d{2}))?\s*([ap]m)(?:\s*\(([^)]+)\))?`)
+	// "usage limit reached|1760000000"
+	resetUnixRe = regexp.MustCompile(`limit reached\|(\d{9,})`)
+	// "Retry-After: 30"
+	retryAfterRe = regexp.MustCompile(`(?i)retry[- ]after"?:?\s*"?(\d+)`)
+	// "try again in 1m20s", "try again in 2 hours 5 minutes"
+	tryAgainRe     = regexp.MustCompile(`(?i)try again in\s+((?:\d+(?:\.\d+)?\s*[a-z]+[\s,]*(?:and\s+)?)+)`)
+	durationPartRe = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*([a-z]+)`)
+)
+
+// parseResetClock finds a reset time of day such as "resets 3pm
+// (America/New_York)" and returns its next occurrence after now. Times without
+// a zone are taken to be local.
+func parseResetClock(output string, now time.Time) (time.Time, bool) {
+	m := resetClockRe.FindStringSubmatch(output)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if hour < 1 || hour > 12 || minute > 59 {
+		return time.Time{}, false
+	}
+	hour %= 12
+	if strings.EqualFold(m[3], "pm") {
+		hour += 12
+	}
+
+	loc := now.Location()
+	if m[4] != "" {
+		zone, err := time.LoadLocation(strings.TrimSpace(m[4]))
+		if err != nil {
+			return time.Time{}, false
+		}
+		loc = zone
+	}
+
+	local := now.In(loc)
+	reset := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	if !reset.After(now) {
+		reset = reset.AddDate(0, 0, 1)
+	}
+	return reset, true
+}
+
+// parseResetUnix finds a reset given as a Unix timestamp after the limit
+// message, e.g. "usage limit reached|1760000000".
+func parseResetUnix(output string) (time.Time, bool) {
+	m := resetUnixRe.FindStringSubmatch(output)
+	if m == nil {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// parseRetryAfter finds a retry hint such as "Retry-After: 30" or "try again
+// in 1m20s" and returns the moment it points to.
+func parseRetryAfter(output string, now time.Time) (time.Time, bool) {
+	if m := retryAfterRe.FindStringSubmatch(output); m != nil {
+		seconds, err := strconv.Atoi(m[1])
+		if err == nil && seconds > 0 {
+			return now.Add(time.Duration(seconds) * time.Second), true
+		}
+	}
+
+	if m := tryAgainRe.FindStringSubmatch(output); m != nil {
+		var total time.Duration
+		for _, part := range durationPartRe.FindAllStringSubmatch(m[1], -1) {
+			value, err := strconv.ParseFloat(part[1], 64)
+			if err != nil {
+				return time.Time{}, false
+			}
+			unit, ok := durationUnit(part[2])
+			if !ok {
+				break
+			}
+			total += time.Duration(value * float64(unit))
+		}
+		if total > 0 {
+			return now.Add(total), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// durationUnit maps a unit as written in a retry hint to its duration.
+func durationUnit(unit string) (time.Duration, bool) {
+	switch strings.ToLower(unit) {
+	case "ms", "millisecond", "milliseconds":
+		return time.Millisecond, true
+	case "s", "sec", "secs", "second", "seconds":
+		return time.Second, true
+	case "m", "min", "mins", "minute", "minutes":
+		return time.Minute, true
+	case "h", "hr", "hrs", "hour", "hours":
+		return time.Hour, true
+	case "d", "day", "days":
+		return 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// rateLimitCooldown returns when a rate limited agent may be tried again: just
+// after the reset the backend parsed, or after the fixed backoff when there
+// was none.
+func rateLimitCooldown(now, resetAt time.Time) time.Time {
+	if !resetAt.After(now) {
+		return now.Add(rateLimitBackoff)
+	}
+	return resetAt.Add(time.Duration(rand.Int63n(int64(rateLimitJitter))))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClaudeRateLimitReset(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data not available")
+	}
+	now := time.Date(2025, 10, 16, 10, 0, 0, 0, newYork)
+
+	tests := []struct {
+		name   string
+		output string
+		want   time.Time
+		ok     bool
+	}{
+		{"later today", "You've hit your limit · resets 3pm (America/New_York)", time.Date(2025, 10, 16, 15, 0, 0, 0, newYork), true},
+		{"with minutes", "You've hit your limit · resets 10:30am (America/New_York)", time.Date(2025, 10, 16, 10, 30, 0, 0, newYork), true},
+		{"already passed today", "You've hit your limit · resets 9am (America/New_York)", time.Date(2025, 10, 17, 9, 0, 0, 0, newYork), true},
+		{"other zone", "You've hit your limit · resets 4pm (Europe/London)", time.Date(2025, 10, 16, 11, 0, 0, 0, newYork), true},
+		{"no zone is local", "You've hit your limit · resets 12pm", time.Date(2025, 10, 16, 12, 0, 0, 0, newYork), true},
+		{"unix timestamp", "Claude AI usage limit reached|1760630400", time.Unix(1760630400, 0), true},
+		{"unknown zone", "You've hit your limit · resets 3pm (Nowhere/Land)", time.Time{}, false},
+		{"no reset", "You've hit your limit", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := (&ClaudeBackend{}).RateLimitReset(tt.output, now)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("RateLimitReset() = %s, %v, want %s, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCodexRateLimitReset(t *testing.T) {
+	now := time.Date(2025, 10, 16, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		output string
+		want   time.Duration
+		ok     bool
+	}{
+		{"retry-after header", "HTTP 429 Too Many Requests\nRetry-After: 30", 30 * time.Second, true},
+		{"go duration", "rate_limit_exceeded: Please try again in 1m20s.", 80 * time.Second, true},
+		{"fractional seconds", "Rate limit reached. Please try again in 6.5s.", 6500 * time.Millisecond, true},
+		{"words", "You've hit your usage limit. Try again in 2 hours 5 minutes.", 2*time.Hour + 5*time.Minute, true},
+		{"no hint", "429 Too Many Requests", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := (&CodexBackend{}).RateLimitReset(tt.output, now)
+			if ok != tt.ok || (ok && got.Sub(now) != tt.want) {
+				t.Errorf("RateLimitReset() = %s, %v, want now+%s, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRateLimitCooldownFallsBackToFixedBackoff(t *testing.T) {
+	now := time.Now()
+	if got := rateLimitCooldown(now, time.Time{}); !got.Equal(now.Add(rateLimitBackoff)) {
+		t.Errorf("cooldown without reset = %s, want now+%s", got, rateLimitBackoff)
+	}
+	if got := rateLimitCooldown(now, now.Add(-time.Minute)); !got.Equal(now.Add(rateLimitBackoff)) {
+		t.Errorf("cooldown with a past reset = %s, want now+%s", got, rateLimitBackoff)
+	}
+}
+
+// scriptedBackend replays canned CLI output through a real backend's parser.
+type scriptedBackend struct {
+	Backend
+	lines  []string
+	stderr string
+}
+
+func (b scriptedBackend) BuildCommand(baseCmd, extraFlags, prompt string) string {
+	script := "cat <<'__LINES__'\n" + strings.Join(b.lines, "\n") + "\n__LINES__\n"
+	if b.stderr != "" {
+		script += "echo " + shellQuote(b.stderr) + " >&2\n"
+	}
+	return script
+}
+
+func TestRateLimitDetection(t *testing.T) {
+	claudeText := func(text string) string {
+		return `{"type":"stream_event","event":{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"` + text + `"}}}`
+	}
+	claudeOK := `{"type":"result","subtype":"success","is_error":false,"result":"Done"}`
+
+	tests := []struct {
+		name    string
+		backend Backend
+		lines   []string
+		stderr  string
+		want    bool
+	}{
+		// Known false positives: the agent's own prose and echoed files
+		{"claude prose mentions the limit", &ClaudeBackend{}, []string{
+			claudeText("The handler returns You've hit your limit when the quota is spent."), claudeOK}, "", false},
+		{"claude reads a file about rate limits", &ClaudeBackend{}, []string{
+			`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Read","input":{"file_path":"limits.go"}}]}}`,
+			`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":"// You've hit your limit\nconst msg = \"rate limit\""}]}}`,
+			claudeOK}, "", false},
+		{"claude summary quotes the limit", &ClaudeBackend{}, []string{
+			`{"type":"result","subtype":"success","is_error":false,"result":"Fixed the You've hit your limit message"}`}, "", false},
+		{"codex prose mentions 429", &CodexBackend{}, []string{
+			`{"type":"item.completed","item":{"id":"1","type":"agent_message","text":"Retry on HTTP 429 Too Many Requests with rate_limit backoff"}}`,
+			`{"type":"turn.completed"}`}, "", false},
+		{"codex command prints rate limit", &CodexBackend{}, []string{
+			`{"type":"item.completed","item":{"id":"2","type":"command_execution","command":"grep -r 'rate limit' .","aggregated_output":"api.go: // rate limit: 429 Too Many Requests","exit_code":0,"status":"completed"}}`,
+			`{"type":"turn.completed"}`}, "", false},
+		{"gemini prose mentions quota", &GeminiBackend{}, []string{
+			`{"type":"message","role":"assistant","content":"Map RESOURCE_EXHAUSTED to Quota exceeded","delta":true}`,
+			`{"type":"result","status":"success"}`}, "", false},
+
+		// Rate limits the CLIs report
+		{"claude error result", &ClaudeBackend{}, []string{
+			`{"type":"result","subtype":"success","is_error":true,"result":"You've hit your limit · resets 3pm (America/New_York)"}`}, "", true},
+		{"codex error event", &CodexBackend{}, []string{
+			`{"type":"error","message":"exceeded retry limit, last status: 429 Too Many Requests"}`}, "", true},
+		{"codex turn failed", &CodexBackend{}, []string{
+			`{"type":"turn.failed","error":{"message":"rate_limit_exceeded: try again in 20s"}}`}, "", true},
+		{"codex stderr", &CodexBackend{}, []string{`{"type":"turn.completed"}`},
+			"ERROR: stream error: 429 Too Many Requests", true},
+		{"gemini error event", &GeminiBackend{}, []string{
+			`{"type":"error","severity":"error","message":"[429] RESOURCE_EXHAUSTED"}`}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := scriptedBackend{Backend: tt.backend, lines: tt.lines, stderr: tt.stderr}
+			result, _ := RunAICommand(AIRequest{Backend: backend, WorkDir: t.TempDir()})
+
+			match, got := findRateLimitMatch(result.Errors, backend.RateLimitPhrases())
+			if got != tt.want {
+				t.Errorf("rate limited = %v, want %v (match %+v, errors %q)", got, tt.want, match, result.Errors)
+			}
+		})
+	}
+}
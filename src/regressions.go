@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// candidateKeys returns the set of candidate keys, for comparing the
+// candidates before and after the agent's changes.
+func candidateKeys(candidates []Candidate) map[string]bool {
+	keys := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		keys[c.Key] = true
+	}
+	return keys
+}
+
+// newCandidateKeys returns the keys in after that were not in before, in
+// source order.
+func newCandidateKeys(before map[string]bool, after []Candidate) []string {
+	var keys []string
+	for _, c := range after {
+		if !before[c.Key] {
+			keys = append(keys, c.Key)
+		}
+	}
+	return keys
+}
+
+// checkRegressions compares the candidates after the agent's changes with
+// those before them (no_regressions). It records and reports any new ones,
+// and returns false if there were. Without a recorded before set (e.g. when
+// recovering an interrupted run) there is nothing to compare and it passes.
+func (r *Runner) checkRegressions(after []Candidate) bool {
+	r.regressions = nil
+	if !r.task.NoRegressions || r.candidatesBefore == nil {
+		return true
+	}
+
+	r.regressions = newCandidateKeys(r.candidatesBefore, after)
+	if len(r.regressions) == 0 {
+		return true
+	}
+	fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("Changes introduced %d new candidates:", len(r.regressions))))
+	for _, key := range r.regressions {
+		fmt.Fprintf(r.console(), "  - %s\n", key)
+	}
+	return false
+}
+
+// regressionReason explains a rejected change for repair prompts.
+func (r *Runner) regressionReason() string {
+	return "The build passed, but the changes introduced new candidates: " + strings.Join(r.regressions, ", ")
+}
+
+// regressionDetails describes new candidates for the log outcome, or "" when
+// there were none.
+func (r *Runner) regressionDetails() string {
+	if len(r.regressions) == 0 {
+		return ""
+	}
+	return "new candidates: " + strings.Join(r.regressions, ", ")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewCandidateKeys(t *testing.T) {
+	before := candidateKeys([]Candidate{{Key: "a"}, {Key: "b"}})
+	after := []Candidate{{Key: "c"}, {Key: "b"}, {Key: "d"}}
+
+	got := newCandidateKeys(before, after)
+	if want := []string{"c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("newCandidateKeys() = %v, want %v", got, want)
+	}
+	if got := newCandidateKeys(before, []Candidate{{Key: "a"}}); got != nil {
+		t.Errorf("newCandidateKeys() = %v, want none", got)
+	}
+}
+
+func TestRunIterationRejectsNewCandidates(t *testing.T) {
+	tests := []struct {
+		name             string
+		acceptBestEffort bool
+	}{
+		{"standard", false},
+		{"best effort", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The agent fixes c1 but introduces c2 and c3
+			runner, dir := newScriptedRunner(t, "touch fixed",
+				Task{
+					CandidateSource:  `if [ -f fixed ]; then echo '["c2","c0","c3"]'; else echo '["c0","c1"]'; fi`,
+					NoRegressions:    true,
+					AcceptBestEffort: tt.acceptBestEffort,
+					Order:            "reverse",
+				},
+				Config{
+					ResetCommand:   "rm -f fixed",
+					SuccessCommand: "touch committed",
+				},
+			)
+
+			stdout := captureStdout(t, func() {
+				if _, err := runner.runIteration(); err != nil {
+					t.Fatalf("runIteration failed: %v", err)
+				}
+			})
+
+			if _, err := os.Stat(filepath.Join(dir, "committed")); err == nil {
+				t.Error("changes committed although they introduced new candidates")
+			}
+			if _, err := os.Stat(filepath.Join(dir, "fixed")); err == nil {
+				t.Error("changes not reset")
+			}
+			for _, want := range []string{"Changes introduced 2 new candidates:", "  - c2\n", "  - c3\n"} {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("stdout missing %q:\n%s", want, stdout)
+				}
+			}
+			if runner.outcome != OutcomeNotFixed {
+				t.Errorf("outcome = %s, want %s", runner.outcome, OutcomeNotFixed)
+			}
+		})
+	}
+}
+
+func TestRunIterationAllowsNewCandidatesByDefault(t *testing.T) {
+	runner, dir := newScriptedRunner(t, "touch fixed",
+		Task{
+			CandidateSource: `if [ -f fixed ]; then echo '["c2"]'; else echo '["c1"]'; fi`,
+		},
+		Config{
+			ResetCommand:   "rm -f fixed",
+			SuccessCommand: "touch committed",
+		},
+	)
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	if _, err := os.Stat(filepath.Join(dir, "committed")); err != nil {
+		t.Error("expected the fix to be committed without no_regressions")
+	}
+}
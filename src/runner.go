@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -22,10 +23,19 @@ const (
 
 // SyncWriter provides synchronized, buffered writing to prevent concurrent
 // writes from corrupting ANSI codes and output.
+//
+// A SyncWriter created with Prefixed shares its parent's lock and output but
+// buffers text until a full line is available, then writes the line with its
+// prefix. This lets several workers stream into one terminal without
+// interleaving mid-line.
 type SyncWriter struct {
 	mu     sync.Mutex
 	writer *bufio.Writer
 	color  string // Current active color code
+
+	parent  *SyncWriter // Set for prefixed writers
+	prefix  string
+	pending strings.Builder // Incomplete line (prefixed writers only)
 }
 
 // NewSyncWriter creates a new synchronized writer.
@@ -35,16 +45,55 @@ func NewSyncWriter(w io.Writer) *SyncWriter {
 	}
 }
 
+// Prefixed returns a line-buffered writer that prepends prefix to every line
+// and writes through s.
+func (s *SyncWriter) Prefixed(prefix string) *SyncWriter {
+	return &SyncWriter{parent: s, prefix: prefix}
+}
+
 // WriteString writes text with mutex protection and immediate flush.
 func (s *SyncWriter) WriteString(text string) {
+	if s.parent != nil {
+		s.parent.mu.Lock()
+		defer s.parent.mu.Unlock()
+		s.pending.WriteString(text)
+		buffered := s.pending.String()
+		s.pending.Reset()
+		for {
+			line, rest, found := strings.Cut(buffered, "\n")
+			if !found {
+				s.pending.WriteString(line)
+				break
+			}
+			s.writePrefixedLine(line)
+			buffered = rest
+		}
+		s.parent.writer.Flush()
+		return
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.writer.WriteString(text)
 	s.writer.Flush()
 }
 
+// Write implements io.Writer so a SyncWriter can be used with fmt.Fprintf.
+func (s *SyncWriter) Write(p []byte) (int, error) {
+	s.WriteString(string(p))
+	return len(p), nil
+}
+
 // SetColor sets the terminal color with mutex protection.
 func (s *SyncWriter) SetColor(color string) {
+	if s.parent != nil {
+		s.parent.mu.Lock()
+		defer s.parent.mu.Unlock()
+		s.flushPending()
+		s.color = color
+		return
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.writer.WriteString(color)
@@ -54,6 +103,14 @@ func (s *SyncWriter) SetColor(color string) {
 
 // ResetColor resets the terminal color with mutex protection.
 func (s *SyncWriter) ResetColor() {
+	if s.parent != nil {
+		s.parent.mu.Lock()
+		defer s.parent.mu.Unlock()
+		s.flushPending()
+		s.color = ""
+		return
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.writer.WriteString(colorReset)
@@ -61,11 +118,36 @@ func (s *SyncWriter) ResetColor() {
 	s.writer.Flush()
 }
 
+// writePrefixedLine writes one complete line to the parent. The caller must
+// hold the parent's lock.
+func (s *SyncWriter) writePrefixedLine(line string) {
+	w := s.parent.writer
+	w.WriteString(s.prefix)
+	if s.color != "" {
+		w.WriteString(s.color + line + colorReset)
+	} else {
+		w.WriteString(line)
+	}
+	w.WriteString("\n")
+}
+
+// flushPending terminates and writes any incomplete line. The caller must hold
+// the parent's lock.
+func (s *SyncWriter) flushPending() {
+	if s.pending.Len() == 0 {
+		return
+	}
+	s.writePrefixedLine(s.pending.String())
+	s.pending.Reset()
+	s.parent.writer.Flush()
+}
+
 // rateLimitError indicates the agent returned a rate limit message
 type rateLimitError struct {
 	msg     string
 	phrase  string
 	context string
+	resetAt time.Time // When the limit resets, if the agent said; zero otherwise
 }
 
 func (e *rateLimitError) Error() string {
@@ -79,7 +161,7 @@ func (e *rateLimitError) DebugString() string {
 	if e.context == "" {
 		return fmt.Sprintf("Rate limit detector matched phrase %q.", e.phrase)
 	}
-	return fmt.Sprintf("Rate limit detector matched phrase %q in captured agent output:\n%s", e.phrase, e.context)
+	return fmt.Sprintf("Rate limit detector matched phrase %q in the errors the agent reported:\n%s", e.phrase, e.context)
 }
 
 // fatalError indicates an error that should stop execution immediately
@@ -103,144 +185,24 @@ func calculateBackoff(level int) time.Duration {
 	return backoff
 }
 
-type peakSchedule struct {
-	label        string
-	locationName string
-	weekdaysOnly bool
-	startMinutes int
-	endMinutes   int
-}
-
-var (
-	easternPeakSchedule = peakSchedule{
-		label:        "On-peak hours (8AM-2PM ET)",
-		locationName: "America/New_York",
-		weekdaysOnly: true,
-		startMinutes: 8 * 60,
-		endMinutes:   14 * 60,
-	}
-	chinaPeakSchedule = peakSchedule{
-		label:        "China peak hours (14:00-18:00 UTC+8)",
-		locationName: "Asia/Shanghai",
-		startMinutes: 14 * 60,
-		endMinutes:   18 * 60,
-	}
-)
-
-func (s peakSchedule) location() *time.Location {
-	loc, err := time.LoadLocation(s.locationName)
-	if err != nil {
-		return time.UTC
-	}
-	return loc
-}
-
-func (s peakSchedule) isOnPeak(t time.Time) bool {
-	localTime := t.In(s.location())
-
-	if s.weekdaysOnly {
-		weekday := localTime.Weekday()
-		if weekday == time.Saturday || weekday == time.Sunday {
-			return false
-		}
-	}
-
-	hour, minute, _ := localTime.Clock()
-	currentMinutes := hour*60 + minute
-
-	return currentMinutes >= s.startMinutes && currentMinutes < s.endMinutes
-}
-
-func (s peakSchedule) timeUntilOffPeak(t time.Time) time.Duration {
-	localTime := t.In(s.location())
-
-	if !s.isOnPeak(t) {
-		return 0
-	}
-
-	endHour := s.endMinutes / 60
-	endMinute := s.endMinutes % 60
-	offPeakStart := time.Date(
-		localTime.Year(), localTime.Month(), localTime.Day(),
-		endHour, endMinute, 0, 0,
-		localTime.Location(),
-	)
-
-	return offPeakStart.Sub(localTime)
-}
-
-// isOnPeak returns true if current time is during on-peak hours.
-// On-peak: weekdays (Mon-Fri) 8:00 AM - 2:00 PM Eastern Time.
-func isOnPeak(t time.Time) bool {
-	return easternPeakSchedule.isOnPeak(t)
-}
-
-// isChinaOnPeak returns true if current time is during China peak hours.
-// Peak: daily 14:00 - 18:00 UTC+8.
-func isChinaOnPeak(t time.Time) bool {
-	return chinaPeakSchedule.isOnPeak(t)
-}
-
-func activePeakSchedule(t time.Time, opts RunnerOptions) (peakSchedule, bool) {
-	if opts.OffPeakOnly && easternPeakSchedule.isOnPeak(t) {
-		return easternPeakSchedule, true
-	}
-	if opts.ChinaOffPeakOnly && chinaPeakSchedule.isOnPeak(t) {
-		return chinaPeakSchedule, true
-	}
-	return peakSchedule{}, false
-}
-
-// timeUntilOffPeak returns duration until the next off-peak period begins.
-func timeUntilOffPeak(t time.Time) time.Duration {
-	return easternPeakSchedule.timeUntilOffPeak(t)
-}
-
-// timeUntilChinaOffPeak returns duration until China peak hours end.
-func timeUntilChinaOffPeak(t time.Time) time.Duration {
-	return chinaPeakSchedule.timeUntilOffPeak(t)
-}
-
-func waitForOffPeak(r *Runner) bool {
-	schedule, onPeak := activePeakSchedule(time.Now(), r.opts)
-	if !onPeak {
-		return false
-	}
-
-	waitDuration := schedule.timeUntilOffPeak(time.Now())
-	fmt.Printf(ColorInfo("%s. Pausing for %s until off-peak...\n"), schedule.label, waitDuration.Round(time.Minute))
-
-	// Use a ticker to check periodically (allows for interruption)
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for !r.stopRequested {
-		if _, stillOnPeak := activePeakSchedule(time.Now(), r.opts); !stillOnPeak {
-			break
-		}
-		<-ticker.C
-	}
-
-	if r.stopRequested {
-		fmt.Println("Stopped by user request during off-peak wait.")
-		return true
-	}
-
-	fmt.Println(ColorInfo("Off-peak hours resumed. Continuing..."))
-	return false
-}
-
 type RunnerOptions struct {
-	Limit            int
-	TimeLimit        time.Duration
-	DryRun           bool
-	Verbose          bool
-	Partition        HashPartition
-	Timeout          time.Duration // Per-candidate timeout (overrides task.yaml)
-	Agent            string        // Agent command (overrides task.yaml)
-	AgentFlags       string        // Additional agent flags (overrides task.yaml)
-	OffPeakOnly      bool          // Only run during off-peak hours
-	ChinaOffPeakOnly bool          // Only run during China off-peak hours
+	Limit      int
+	TimeLimit  time.Duration
+	DryRun     bool
+	Verbose    bool
+	Partition  HashPartition
+	Timeout    time.Duration // Per-candidate timeout (overrides task.yaml)
+	Agent      string        // Agent command (overrides task.yaml)
+	AgentFlags string        // Additional agent flags (overrides task.yaml)
+	Schedules  []string      // Names of the schedules that apply (overrides task.yaml)
+	Worker     int           // 1-based worker number when running in a pool (0 = standalone)
+	Resume     bool          // Offer to recover a candidate left in flight by an interrupted run
+	Order      string        // Candidate order (overrides task.yaml)
+	Watch      bool          // Keep polling for new candidates once the source is empty
+	Poll       time.Duration // Interval between candidate source polls in watch mode
+	MaxCost    float64       // Stop once the run has cost this many USD (overrides task.yaml)
+	MaxTokens  int64         // Stop once the run has used this many tokens (overrides task.yaml)
+	ToolOutput string        // How agent tool calls are shown (overrides config.yaml)
 }
 
 type Runner struct {
@@ -255,7 +217,30 @@ type Runner struct {
 	stopOnce      sync.Once
 	backoffLevel  int
 	executor      CommandExecutor
-	backend       Backend
+	backend       Backend        // Backend of the active agent
+	agents        []*agentChoice // Agent fallback chain, most preferred first
+	active        int            // Index of the active agent in agents
+	procs         *ProcessTracker
+	out           *SyncWriter // Prefixed worker output; nil writes straight to stdout
+	input         io.Reader   // Answers to interactive prompts; nil reads stdin
+	statePath     string
+	state         *RunState // Candidate in flight; nil between candidates
+	stateReset    bool      // The reset command has cleaned up after the candidate in flight
+	iteration     int
+	order         Order
+	scoreBefore   *float64    // score_command output before the agent ran (accept_if)
+	scoreAfter    *float64    // score_command output after the agent's changes
+	batchUnfixed  []Candidate // Batch members still reported by the last re-check
+	usage         Usage       // Agent usage for the candidate in flight
+	budget        *Budget     // Usage limits for the whole run
+	schedules     []*Schedule // Windows that pause the run
+	outcome       Outcome     // Outcome logged for the candidate in flight
+	timedOut      bool        // Whether the agent timed out on the candidate in flight
+
+	candidatesBefore map[string]bool // Candidate keys before the agent ran
+	regressions      []string        // New candidates found by the last re-check
+	collateral       []string        // Other candidates gone after the last re-check
+	outcomes         map[Outcome]int // Outcome counts for the end-of-run summary
 }
 
 // requestStop signals a graceful stop. Safe to call multiple times; the channel
@@ -297,14 +282,57 @@ func NewRunner(env *Environment, taskName string, opts RunnerOptions) (*Runner,
 	// Set repeat mode on ignored list
 	ignoredList.SetMaxRepeat(task.Repeat)
 
+	// Candidate order: CLI override > task-level
+	orderSpec := opts.Order
+	if orderSpec == "" {
+		orderSpec = task.Order
+	}
+	order, err := ParseOrder(orderSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateBackendNames(env.Config, task); err != nil {
+		return nil, err
+	}
+
 	var agentLogger *AgentLogger
 	if !opts.DryRun {
-		agentLogger, err = NewAgentLogger(task.Dir)
+		if opts.Worker > 0 {
+			agentLogger, err = NewWorkerAgentLogger(task.Dir, opts.Worker)
+		} else {
+			agentLogger, err = NewAgentLogger(task.Dir)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to create agent logger: %w", err)
 		}
 	}
 
+	// Schedules: CLI override > task-level
+	scheduleNames := opts.Schedules
+	if len(scheduleNames) == 0 {
+		scheduleNames = task.Schedules
+	}
+	schedules, err := resolveSchedules(scheduleNames, env.Config.Schedules)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run budget: CLI override > task-level
+	budget := &Budget{MaxCost: task.MaxCost, MaxTokens: task.MaxTokens}
+	if opts.MaxCost > 0 {
+		budget.MaxCost = opts.MaxCost
+	}
+	if opts.MaxTokens > 0 {
+		budget.MaxTokens = opts.MaxTokens
+	}
+
+	statePath := StatePath(task.Dir)
+	if opts.Worker > 0 {
+		statePath = WorkerLogPath(statePath, opts.Worker)
+	}
+
+	procs := NewProcessTracker()
 	return &Runner{
 		env:         env,
 		task:        task,
@@ -312,9 +340,14 @@ func NewRunner(env *Environment, taskName string, opts RunnerOptions) (*Runner,
 		ignoredList: ignoredList,
 		agentLogger: agentLogger,
 		agentStats:  NewSessionStats(),
-		executor:    &RealCommandExecutor{},
+		executor:    &RealCommandExecutor{Procs: procs},
 		backend:     nil, // resolved in Run() after command precedence is established
 		stopCh:      make(chan struct{}),
+		procs:       procs,
+		statePath:   statePath,
+		order:       order,
+		budget:      budget,
+		schedules:   schedules,
 	}, nil
 }
 
@@ -323,6 +356,32 @@ func (r *Runner) setExecutor(exec CommandExecutor) {
 	r.executor = exec
 }
 
+// setOutput routes all runner output, including command output, through w.
+func (r *Runner) setOutput(w *SyncWriter) {
+	r.out = w
+	if executor, ok := r.executor.(*RealCommandExecutor); ok {
+		executor.Output = w
+	}
+}
+
+// console returns the writer for user-facing output.
+func (r *Runner) console() io.Writer {
+	if r.out != nil {
+		return r.out
+	}
+	return os.Stdout
+}
+
+// newProgressTimer creates a delayed progress timer. Timers redraw the current
+// terminal line, so they are suppressed when output is shared between workers.
+func (r *Runner) newProgressTimer(label string, delay time.Duration) *DelayedProgressTimer {
+	timer := NewDelayedProgressTimer(label, delay)
+	if r.out != nil {
+		timer.SetWriter(io.Discard)
+	}
+	return timer
+}
+
 func (r *Runner) effectiveTimeout() time.Duration {
 	if r.opts.Timeout != 0 {
 		return r.opts.Timeout
@@ -340,6 +399,19 @@ func findRateLimitMatch(output string, phrases []string) (*rateLimitError, bool)
 		if phrase == "" {
 			continue
 		}
+		if pattern, ok := rateLimitPattern(phrase); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				continue
+			}
+			if loc := re.FindStringIndex(output); loc != nil {
+				return &rateLimitError{
+					phrase:  output[loc[0]:loc[1]],
+					context: contextAround(output, loc[0], loc[1]-loc[0], 240),
+				}, true
+			}
+			continue
+		}
 		if idx := strings.Index(output, phrase); idx >= 0 {
 			return &rateLimitError{
 				phrase:  phrase,
@@ -390,76 +462,108 @@ func contextAround(s string, idx, matchLen, maxLen int) string {
 }
 
 func (r *Runner) Run() error {
-	// Resolve agent command: CLI override > task-level > global
-	resolvedCmd := r.opts.Agent
-	if resolvedCmd == "" {
-		resolvedCmd = r.task.Agent
+	if err := r.prepare(); err != nil {
+		return err
 	}
-	if resolvedCmd == "" {
-		resolvedCmd = r.env.Config.Agent
+
+	handleSignals(r.console(), r.requestStop)
+
+	// Print startup banner with cat
+	fmt.Fprint(r.console(), StartupBanner(r.task.Name, displayPath(AgentLogPath(r.task.Dir)), r.modeString(), agentChainString(r.agents)))
+
+	if err := r.recoverInterruptedRun(); err != nil {
+		return err
 	}
 
-	// Create backend based on the resolved command
-	r.backend = NewBackend(resolvedCmd)
+	return r.loop()
+}
 
-	// Verify command exists (skip in dry-run)
+// prepare resolves the agent chain and checks that the agent commands exist.
+func (r *Runner) prepare() error {
+	r.agents = r.resolveAgents()
+	r.active = 0
+	r.backend = r.activeAgent().backend
+
+	// Verify commands exist (skip in dry-run)
 	if !r.opts.DryRun {
-		if err := CheckAICommand(resolvedCmd); err != nil {
-			return err
+		for _, agent := range r.agents {
+			if direct, ok := agent.backend.(DirectBackend); ok {
+				if err := direct.Check(agent.Agent, agent.Flags); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := CheckAICommand(agent.Agent); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
 
-	// Set up signal handlers
+// handleSignals installs the signal handlers: SIGQUIT requests a graceful stop,
+// SIGINT/SIGTERM kill any running child processes and exit.
+func handleSignals(out io.Writer, requestStop func()) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGQUIT, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-sigChan
 		switch sig {
 		case syscall.SIGQUIT:
-			fmt.Println("\n[Ctrl+\\] Graceful stop requested, will finish current iteration...")
-			r.requestStop()
+			fmt.Fprintln(out, "\n[Ctrl+\\] Graceful stop requested, will finish current iteration...")
+			requestStop()
 		case syscall.SIGINT, syscall.SIGTERM:
-			fmt.Println("\nInterrupted, cleaning up...")
+			fmt.Fprintln(out, "\nInterrupted, cleaning up...")
 			KillRunningProcess()
 			os.Exit(1)
 		}
 	}()
+}
 
-	// Print startup banner with cat
-	logPath := AgentLogPath(r.task.Dir)
+// displayPath returns path relative to the working directory when possible.
+func displayPath(path string) string {
 	if cwd, err := os.Getwd(); err == nil {
-		if rel, err := filepath.Rel(cwd, logPath); err == nil {
-			logPath = rel
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			return rel
 		}
 	}
-	fmt.Print(StartupBanner(r.task.Name, logPath, r.modeString()))
+	return path
+}
 
+// loop processes candidates until done, stopped, or a limit is reached.
+func (r *Runner) loop() error {
 	startTime := time.Now()
 	iteration := 0
 	firstIteration := true
 	for {
 		if r.stopRequested {
-			fmt.Println("Stopped by user request.")
+			fmt.Fprintln(r.console(), "Stopped by user request.")
 			break
 		}
 
 		if r.opts.Limit > 0 && iteration >= r.opts.Limit {
-			fmt.Printf("Reached iteration limit (%d).\n", r.opts.Limit)
+			fmt.Fprintf(r.console(), "Reached iteration limit (%d).\n", r.opts.Limit)
 			break
 		}
 
 		if r.opts.TimeLimit > 0 && time.Since(startTime) >= r.opts.TimeLimit {
-			fmt.Printf("Reached time limit (%s).\n", r.opts.TimeLimit)
+			fmt.Fprintf(r.console(), "Reached time limit (%s).\n", r.opts.TimeLimit)
 			break
 		}
 
-		// Check off-peak schedules
-		if waitForOffPeak(r) {
+		if limit, exceeded := r.budget.Exceeded(); exceeded {
+			fmt.Fprintf(r.console(), "Reached %s.\n", limit)
+			break
+		}
+
+		// Wait while the schedules don't allow running
+		if r.waitForSchedule() {
 			break
 		}
 
 		iteration++
-		fmt.Print(IterationBanner(iteration, time.Now().Format("15:04:05")))
+		r.iteration = iteration
+		fmt.Fprint(r.console(), IterationBanner(iteration, time.Now().Format("15:04:05")))
 
 		// Reset environment to clean state at start of first iteration
 		if firstIteration {
@@ -471,35 +575,40 @@ func (r *Runner) Run() error {
 
 		done, err := r.runIteration()
 		if err != nil {
-			fmt.Println(ColorError(fmt.Sprintf("Error: %v", err)))
+			fmt.Fprintln(r.console(), ColorError(fmt.Sprintf("Error: %v", err)))
 
 			// If a graceful stop was requested (e.g. the candidate source was
 			// interrupted by the stop signal), don't back off — just stop.
 			if r.stopRequested {
-				fmt.Println("Stopped by user request.")
+				fmt.Fprintln(r.console(), "Stopped by user request.")
 				break
 			}
 
 			// Check if it's a fatal error - stop immediately
 			if _, isFatal := err.(*fatalError); isFatal {
-				fmt.Println(ColorError("Fatal error, stopping."))
+				fmt.Fprintln(r.console(), ColorError("Fatal error, stopping."))
 				return err
 			}
 
 			// Check if it's a rate limit error
-			if _, isRateLimit := err.(*rateLimitError); isRateLimit {
-				fmt.Println(ColorWarning(fmt.Sprintf("Rate limit hit, sleeping for %s...", rateLimitBackoff)))
-				if r.interruptibleSleep(rateLimitBackoff) {
-					fmt.Println("Stopped by user request.")
-					break
+			if rateLimitErr, isRateLimit := err.(*rateLimitError); isRateLimit {
+				// Hand over to the next agent in the chain, or sleep until one is available
+				now := time.Now()
+				if wait := r.coolDownActiveAgent(now, rateLimitErr.resetAt); wait > 0 {
+					fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("Rate limit hit, sleeping for %s until %s...",
+						wait.Round(time.Second), now.Add(wait).Format("2006-01-02 15:04:05"))))
+					if r.interruptibleSleep(wait) {
+						fmt.Fprintln(r.console(), "Stopped by user request.")
+						break
+					}
 				}
 				r.backoffLevel = 0
 			} else {
 				// Exponential backoff for other errors
 				backoff := calculateBackoff(r.backoffLevel)
-				fmt.Println(ColorWarning(fmt.Sprintf("Sleeping for %s (backoff level %d)...", backoff, r.backoffLevel)))
+				fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("Sleeping for %s (backoff level %d)...", backoff, r.backoffLevel)))
 				if r.interruptibleSleep(backoff) {
-					fmt.Println("Stopped by user request.")
+					fmt.Fprintln(r.console(), "Stopped by user request.")
 					break
 				}
 				r.backoffLevel++
@@ -508,12 +617,20 @@ func (r *Runner) Run() error {
 		}
 
 		if done {
-			break
+			if !r.opts.Watch || r.watchForCandidates(startTime) {
+				break
+			}
+			continue
 		}
 
 		r.backoffLevel = 0
 	}
 
+	if r.opts.Worker == 0 {
+		fmt.Fprintln(r.console(), ColorInfo(runSummary(iteration, r.outcomes)))
+		r.runCompleteHook(r.env.ProjectDir, time.Since(startTime), iteration)
+	}
+
 	if r.agentLogger != nil {
 		r.agentLogger.Close()
 	}
@@ -521,34 +638,53 @@ func (r *Runner) Run() error {
 	return nil
 }
 
-func (r *Runner) runIteration() (done bool, err error) {
-	timeout := r.effectiveTimeout()
-
-	// Run candidate source to get candidates
-	candidateTimer := NewDelayedProgressTimer("Running candidate source...", 5*time.Second)
+// loadCandidates runs the candidate source and returns this runner's share of
+// the candidates in processing order.
+func (r *Runner) loadCandidates() ([]Candidate, error) {
+	candidateTimer := r.newProgressTimer("Running candidate source...", 5*time.Second)
 	candidateTimer.Start()
-	output, err := RunCandidateSource(r.task.CandidateSource, r.env.ProjectDir)
+	output, err := RunCandidateSource(r.procs, r.task.CandidateSource, r.env.ProjectDir)
 	candidateTimer.Stop()
 	if err != nil {
-		return false, fmt.Errorf("candidate source failed: %w", err)
+		return nil, fmt.Errorf("candidate source failed: %w", err)
 	}
 
 	if r.opts.Verbose {
-		fmt.Printf(ColorInfo("Candidate source output:\n%s\n"), output)
+		fmt.Fprintf(r.console(), ColorInfo("Candidate source output:\n%s\n"), output)
 	}
 
 	candidates, err := ParseCandidates(output)
 	if err != nil {
-		return false, fmt.Errorf("failed to parse candidates: %w", err)
+		return nil, fmt.Errorf("failed to parse candidates: %w", err)
 	}
 
 	// Filter by hash if requested
 	candidates = FilterByPartition(candidates, r.opts.Partition)
+	return r.order.Apply(candidates, r.ignoredList), nil
+}
+
+func (r *Runner) runIteration() (done bool, err error) {
+	timeout := r.effectiveTimeout()
+
+	// Return to the preferred agent once its rate limit cooldown is over
+	r.selectAgent(time.Now())
+	r.scoreBefore, r.scoreAfter = nil, nil
+	r.batchUnfixed = nil
+	r.usage = Usage{}
+	r.outcome, r.timedOut = "", false
+	r.candidatesBefore, r.regressions, r.collateral = nil, nil, nil
+
+	// Run candidate source to get candidates
+	candidates, err := r.loadCandidates()
+	if err != nil {
+		return false, err
+	}
+	r.candidatesBefore = candidateKeys(candidates)
 
 	if r.opts.Verbose {
-		fmt.Printf(ColorInfo("Parsed candidates (%d total):\n"), len(candidates))
+		fmt.Fprintf(r.console(), ColorInfo("Parsed candidates (%d total):\n"), len(candidates))
 		for _, c := range candidates {
-			fmt.Printf("  - %s\n", c.Key)
+			fmt.Fprintf(r.console(), "  - %s\n", c.Key)
 		}
 	}
 
@@ -562,21 +698,31 @@ func (r *Runner) runIteration() (done bool, err error) {
 		}
 	}
 
-	// Select first non-ignored candidate
-	candidate := SelectCandidate(candidates, r.ignoredList)
+	// Select first non-ignored candidate, plus up to batch_size-1 more
+	var candidate *Candidate
+	if batch := SelectBatch(candidates, r.ignoredList, r.task.BatchSize, r.task.BatchBy); len(batch) > 0 {
+		candidate = batchCandidate(batch)
+	}
 	if candidate == nil {
 		remaining := len(candidates) - ignoredCount
 		if remaining == 0 && ignoredCount > 0 {
-			fmt.Printf("No more candidates (%d ignored)\n", ignoredCount)
+			fmt.Fprintf(r.console(), "No more candidates (%d ignored)\n", ignoredCount)
 		} else {
-			fmt.Println("No more candidates.")
+			fmt.Fprintln(r.console(), "No more candidates.")
 		}
 		return true, nil
 	}
 
-	fmt.Printf("Found %d candidates (%d ignored)\n", len(candidates)-ignoredCount, ignoredCount)
+	fmt.Fprintf(r.console(), "Found %d candidates (%d ignored)\n", len(candidates)-ignoredCount, ignoredCount)
 
-	fmt.Printf("Selected: %s\n", candidate.Key)
+	if len(candidate.batch) > 0 {
+		fmt.Fprintf(r.console(), "Selected batch of %d:\n", len(candidate.batch))
+		for _, c := range candidate.batch {
+			fmt.Fprintf(r.console(), "  - %s\n", c.Key)
+		}
+	} else {
+		fmt.Fprintf(r.console(), "Selected: %s\n", candidate.Key)
+	}
 
 	// Get prompt content
 	prompt, err := r.getPrompt(candidate)
@@ -585,65 +731,194 @@ func (r *Runner) runIteration() (done bool, err error) {
 	}
 
 	if r.opts.Verbose {
-		fmt.Printf("Prompt:\n%s\n", prompt)
+		fmt.Fprintf(r.console(), "Prompt:\n%s\n", prompt)
 	}
 
-	// Determine agent flags: CLI override > task-level > global
-	agentFlags := r.opts.AgentFlags
-	if agentFlags == "" {
-		agentFlags = r.task.AgentFlags
+	agent := r.activeAgent()
+	agentCmd, agentFlags := agent.Agent, agent.Flags
+	if r.opts.Verbose {
+		fmt.Fprintf(r.console(), ColorInfo("Using %s agent: %s\n"), agent.source, agentCmd)
+		if agentFlags != "" {
+			fmt.Fprintf(r.console(), ColorInfo("Using agent_flags: %s\n"), agentFlags)
+		}
 	}
-	if agentFlags == "" {
-		agentFlags = r.env.Config.AgentFlags
+
+	if r.opts.Verbose {
+		fmt.Fprintf(r.console(), ColorInfo("%s command: %s\n"), r.backend.DisplayName(), commandPreview(r.backend.BuildCommand(agentCmd, agentFlags, prompt)))
 	}
-	if agentFlags != "" && r.opts.Verbose {
-		fmt.Printf(ColorInfo("Using agent_flags: %s\n"), agentFlags)
+
+	// Dry run: just print and exit
+	if r.opts.DryRun {
+		fmt.Fprintf(r.console(), "\n--- Dry Run Prompt ---\n%s\n--- End Prompt ---\n", prompt)
+		return true, nil
 	}
 
-	// Determine agent command: CLI override > task-level > global
-	agentCmd := r.opts.Agent
-	if agentCmd != "" {
-		if r.opts.Verbose {
-			fmt.Printf(ColorInfo("Using CLI override agent: %s\n"), agentCmd)
+	if ok, err := r.runBeforeCandidateHook(candidate); !ok {
+		return false, err
+	}
+	candidateStart := time.Now()
+	defer func() { r.runCandidateHooks(candidate, time.Since(candidateStart)) }()
+
+	r.beginCandidate(candidate)
+	defer func() {
+		// Errors leave the state behind so an interrupted candidate can be
+		// recovered, unless its changes were already reset
+		if err == nil || r.stateReset {
+			r.finishCandidate()
 		}
-	} else {
-		agentCmd = r.task.Agent
-		if agentCmd != "" && r.opts.Verbose {
-			fmt.Printf(ColorInfo("Using task-level agent: %s\n"), agentCmd)
+	}()
+
+	if r.agentLogger != nil {
+		r.agentLogger.StartEntry(agent.label(), prompt)
+	}
+
+	if r.task.AcceptIf != "" {
+		if err := r.measureScoreBefore(); err != nil {
+			return false, err
 		}
 	}
-	if agentCmd == "" {
-		agentCmd = r.env.Config.Agent
+
+	if r.task.AttemptsPerCandidate > 1 {
+		return r.runTournament(candidate, agent, prompt, timeout)
 	}
 
-	if r.opts.Verbose {
-		fmt.Printf(ColorInfo("%s command: %s\n"), r.backend.DisplayName(), commandPreview(r.backend.BuildCommand(agentCmd, agentFlags, prompt)))
+	extraEnv := r.candidateEnv(timeout)
+	sessionID, err := r.runAgent(agentCmd, agentFlags, prompt, "", timeout, extraEnv)
+
+	// Each pass verifies the agent's work; failures get up to
+	// max_repair_rounds follow-up sessions before the changes are reset.
+	for round := 1; ; round++ {
+		err = r.continueOnTimeout(agentCmd, agentFlags, sessionID, err)
+		if err != nil {
+			return r.handleAgentError(candidate, timeout, err)
+		}
+
+		// Verify build FIRST before checking candidate presence
+		// Invalid changes can cause candidates to be excluded from source,
+		// creating false positives if we check presence before build
+		r.setPhase(PhaseVerify)
+		verifyOK, verifyOutput := r.runVerifyCapture()
+		recheckResult := "Not checked because the build failed."
+		if verifyOK {
+			// Build passed - now check if candidate was fixed
+			r.setPhase(PhaseRecheck)
+			candidateFixed, reason, err := r.checkFixed(candidate, extraEnv)
+			if err != nil {
+				return false, err
+			}
+			r.reportCollateral()
+			if candidateFixed {
+				violations, err := r.guardrailViolations(candidate)
+				if err != nil {
+					return false, err
+				}
+				if len(violations) > 0 {
+					return r.handleGuardrailViolation(candidate, violations)
+				}
+				return r.handleSuccess(candidate, true) // Build already verified
+			}
+			recheckResult = reason
+		} else {
+			fmt.Fprintln(r.console(), ColorWarning("Build failed after agent changes"))
+		}
+
+		if round > r.task.MaxRepairRounds {
+			return r.handleFailure(candidate)
+		}
+
+		repairPrompt, err := r.getRepairPrompt(candidate, prompt, verifyOutput, recheckResult)
+		if err != nil {
+			return false, err
+		}
+
+		label := fmt.Sprintf("Repair round %d/%d", round, r.task.MaxRepairRounds)
+		fmt.Fprintln(r.console(), ColorInfo(label+": asking "+r.backend.DisplayName()+" to fix its changes..."))
+		if r.opts.Verbose {
+			fmt.Fprintf(r.console(), "Repair prompt:\n%s\n", repairPrompt)
+		}
+		if r.agentLogger != nil {
+			r.agentLogger.StartSubEntry(label, repairPrompt)
+		}
+
+		r.setPhase(PhaseAgent)
+		extraEnv = r.candidateEnv(timeout)
+		sessionID, err = r.runAgent(agentCmd, agentFlags, repairPrompt, "", timeout, extraEnv)
 	}
+}
 
-	// Dry run: just print and exit
-	if r.opts.DryRun {
-		fmt.Printf("\n--- Dry Run Prompt ---\n%s\n--- End Prompt ---\n", prompt)
-		return true, nil
+// defaultContinuationPrompt is sent when resuming a session that timed out.
+const defaultContinuationPrompt = `You ran out of time on this task. You have $REMAINING left.
+
+Wrap up now: finish or back out the change you are in the middle of, make sure the project builds, and stop. Do not start any new work.`
+
+// continueOnTimeout resumes a timed-out agent session up to
+// timeout_continuations times, giving the agent a short budget to wrap up.
+// Returns the error from the last session (nil if a continuation finished).
+func (r *Runner) continueOnTimeout(agentCmd, agentFlags, sessionID string, err error) error {
+	for i := 1; i <= r.task.TimeoutContinuations; i++ {
+		// An idle agent is wedged, not short of time
+		if timeoutErr, isTimeout := err.(*timeoutError); !isTimeout || timeoutErr.idle {
+			return err
+		}
+		if sessionID == "" {
+			fmt.Fprintln(r.console(), ColorWarning(r.backend.DisplayName()+" did not report a session ID; cannot continue the session"))
+			return err
+		}
+
+		budget := r.task.ContinuationTimeout
+		prompt := strings.ReplaceAll(defaultContinuationPrompt, "$REMAINING", formatDuration(budget))
+
+		label := fmt.Sprintf("Timeout continuation %d/%d", i, r.task.TimeoutContinuations)
+		fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("%s: resuming session with %s to wrap up...", label, formatDuration(budget))))
+		if r.agentLogger != nil {
+			r.agentLogger.StartSubEntry(label, prompt)
+		}
+
+		r.setPhase(PhaseAgent)
+		var resumedID string
+		resumedID, err = r.runAgent(agentCmd, agentFlags, prompt, sessionID, budget, r.candidateEnv(budget))
+		if resumedID != "" {
+			sessionID = resumedID
+		}
 	}
+	return err
+}
 
-	if r.agentLogger != nil {
-		r.agentLogger.StartEntry(prompt)
+// candidateEnv returns the timeout environment for an agent session starting
+// now, and passes it on to commands run by the executor.
+func (r *Runner) candidateEnv(timeout time.Duration) []string {
+	extraEnv := timeoutEnv(timeout, time.Now().Add(timeout))
+	if executor, ok := r.executor.(*RealCommandExecutor); ok {
+		executor.ExtraEnv = extraEnv
 	}
+	return extraEnv
+}
 
+// runAgent runs one agent session, streaming its output to the console and the
+// agent log. The caller starts the log entry; runAgent ends it. When sessionID
+// is set the existing session is resumed. Returns the session ID reported by
+// the backend, a rateLimitError if the output indicates the agent was rate
+// limited, and an agentError with the backend's classification for other
+// failures.
+func (r *Runner) runAgent(agentCmd, agentFlags, prompt, sessionID string, timeout time.Duration, extraEnv []string) (string, error) {
 	// Create SyncWriter for all output during streaming
-	syncWriter := NewSyncWriter(os.Stdout)
+	syncWriter := r.out
+	if syncWriter == nil {
+		syncWriter = NewSyncWriter(os.Stdout)
+	}
 
 	// Create inactivity timer - shows after 30 seconds of no streaming output
 	// Note: timer will be stopped when streaming starts
-	inactivityTimer := NewDelayedProgressTimer("Waiting for "+r.backend.DisplayName()+"...", 30*time.Second)
+	inactivityTimer := r.newProgressTimer("Waiting for "+r.backend.DisplayName()+"...", 30*time.Second)
 
-	fmt.Println(ColorInfo("Running " + r.backend.DisplayName() + "..."))
+	fmt.Fprintln(r.console(), ColorInfo("Running "+r.backend.DisplayName()+"..."))
 
 	// Track first chunk to stop timer and set color
 	firstChunk := &atomic.Bool{}
 	firstChunk.Store(true)
 
 	// Create stream callback - all writes go through SyncWriter
+	lineStart := true
 	streamCb := func(text string) {
 		// On first chunk, stop inactivity timer and set color
 		if firstChunk.Load() {
@@ -652,16 +927,52 @@ func (r *Runner) runIteration() (done bool, err error) {
 			syncWriter.SetColor(colorDim + colorItalic)
 		}
 		syncWriter.WriteString(text)
+		lineStart = strings.HasSuffix(text, "\n")
 	}
 
-	extraEnv := timeoutEnv(timeout, time.Now().Add(timeout))
-	if executor, ok := r.executor.(*RealCommandExecutor); ok {
-		executor.ExtraEnv = extraEnv
+	// Tool calls are shown as status lines in their own color
+	toolOutput := r.toolOutput()
+	toolCb := func(tool ToolEvent) {
+		if toolOutput == ToolOutputHide {
+			return
+		}
+		firstChunk.Store(false)
+		inactivityTimer.Stop()
+		if !lineStart {
+			syncWriter.WriteString("\n")
+		}
+		syncWriter.SetColor(colorBlue)
+		syncWriter.WriteString(tool.Summary(r.env.ProjectDir) + "\n")
+		if output := strings.TrimRight(tool.Output, "\n"); toolOutput == ToolOutputFull && output != "" {
+			syncWriter.SetColor(colorDim)
+			syncWriter.WriteString("  " + strings.ReplaceAll(output, "\n", "\n  ") + "\n")
+		}
+		syncWriter.SetColor(colorDim + colorItalic)
+		lineStart = true
 	}
 
 	inactivityTimer.Start()
 
-	agentOutput, err := RunAICommand(r.backend, agentCmd, agentFlags, prompt, r.env.ProjectDir, r.agentLogger, timeout, extraEnv, streamCb)
+	req := AIRequest{
+		Backend:     r.backend,
+		BaseCmd:     agentCmd,
+		ExtraFlags:  agentFlags,
+		Prompt:      prompt,
+		SessionID:   sessionID,
+		WorkDir:     r.env.ProjectDir,
+		Timeout:     timeout,
+		IdleTimeout: r.task.IdleTimeout,
+		ExtraEnv:    extraEnv,
+		StreamCb:    streamCb,
+		ToolCb:      toolCb,
+		Procs:       r.procs,
+	}
+	if r.agentLogger != nil {
+		req.LogWriter = r.agentLogger
+	}
+	result, err := RunAICommand(req)
+	r.usage.Add(result.Usage)
+	r.budget.Add(result.Usage)
 
 	// Make sure timer is stopped (in case no stream chunks arrived)
 	inactivityTimer.Stop()
@@ -673,100 +984,170 @@ func (r *Runner) runIteration() (done bool, err error) {
 		r.agentLogger.EndEntry()
 	}
 
-	// Check for rate limit in output
-	if match, ok := findRateLimitMatch(agentOutput, r.backend.RateLimitPhrases()); ok {
+	// Check for rate limit in the errors the agent reported
+	if match, ok := findRateLimitMatch(result.Errors, r.backend.RateLimitPhrases()); ok {
 		// Always surface why the detector fired so false positives can be
 		// diagnosed without re-running in verbose mode.
-		fmt.Println(ColorWarning(match.DebugString()))
+		fmt.Fprintln(r.console(), ColorWarning(match.DebugString()))
 		if r.agentLogger != nil {
-			fmt.Printf(ColorInfo("Full captured output is logged in %s\n"), r.agentLogger.Path())
+			fmt.Fprintf(r.console(), ColorInfo("Full captured output is logged in %s\n"), r.agentLogger.Path())
+		}
+		resetAt, ok := r.backend.RateLimitReset(result.Errors, time.Now())
+		if ok {
+			fmt.Fprintln(r.console(), ColorInfo(fmt.Sprintf("%s reports the limit resets at %s", r.backend.DisplayName(), resetAt.Local().Format("2006-01-02 15:04:05"))))
 		}
-		return false, &rateLimitError{
+		return result.SessionID, &rateLimitError{
 			msg:     r.backend.DisplayName() + " rate limit hit",
 			phrase:  match.phrase,
 			context: match.context,
+			resetAt: resetAt,
 		}
 	}
 
-	// Check for timeout
-	if _, isTimeout := err.(*timeoutError); isTimeout {
-		fmt.Println(ColorWarning(fmt.Sprintf("Candidate timeout after %s", timeout)))
-		return r.handleTimeout(candidate)
+	switch err.(type) {
+	case nil, *timeoutError, *rateLimitError:
+		return result.SessionID, err
 	}
+	return result.SessionID, &agentError{failure: r.backend.ClassifyFailure(result.Errors, result.ExitCode), err: err}
+}
 
-	if err != nil {
-		// AI backend errored out - clean up any partial changes before retry
-		fmt.Println(ColorWarning(fmt.Sprintf("%s failed: %v", r.backend.DisplayName(), err)))
-		fmt.Println(ColorWarning("Cleaning up..."))
-		if !r.runResetAndVerify() {
-			return false, &fatalError{msg: "failed to reset after " + strings.ToLower(r.backend.DisplayName()) + " error"}
+// handleAgentError deals with an agent session that did not complete normally.
+func (r *Runner) handleAgentError(candidate *Candidate, timeout time.Duration, err error) (bool, error) {
+	if _, isRateLimit := err.(*rateLimitError); isRateLimit {
+		return false, err
+	}
+
+	// Check for timeout
+	if timeoutErr, isTimeout := err.(*timeoutError); isTimeout {
+		if timeoutErr.idle {
+			fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("%s produced no output for %s, killed it", r.backend.DisplayName(), timeoutErr.duration)))
+			if r.agentLogger != nil {
+				fmt.Fprintf(r.console(), ColorInfo("The last lines of output are logged in %s\n"), r.agentLogger.Path())
+			}
+		} else {
+			fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("Candidate timeout after %s", timeout)))
 		}
-		return false, fmt.Errorf("%s failed: %w", strings.ToLower(r.backend.DisplayName()), err)
+		return r.handleTimeout(candidate, timeoutErr.idle)
+	}
+
+	if agentErr, ok := err.(*agentError); ok && agentErr.failure.Kind != FailureRetryable {
+		return r.handleAgentFailure(candidate, agentErr)
 	}
 
-	// Verify build FIRST before checking candidate presence
-	// Invalid changes can cause candidates to be excluded from source,
-	// creating false positives if we check presence before build
-	if !r.runVerify() {
-		fmt.Println(ColorWarning("Build failed after agent changes"))
-		return r.handleFailure(candidate)
+	// AI backend errored out - clean up any partial changes before retry
+	fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("%s failed: %v", r.backend.DisplayName(), err)))
+	fmt.Fprintln(r.console(), ColorWarning("Cleaning up..."))
+	if !r.runResetAndVerify() {
+		return false, &fatalError{msg: "failed to reset after " + strings.ToLower(r.backend.DisplayName()) + " error"}
 	}
+	return false, fmt.Errorf("%s failed: %w", strings.ToLower(r.backend.DisplayName()), err)
+}
+
+// handleAgentFailure deals with an agent failure that retrying the candidate
+// won't fix: a fatal one stops the run, a candidate-specific one ignores the
+// candidate and moves on.
+func (r *Runner) handleAgentFailure(candidate *Candidate, agentErr *agentError) (bool, error) {
+	name := r.backend.DisplayName()
+	fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("%s failed: %v", name, agentErr.err)))
+	fmt.Fprintln(r.console(), ColorWarning("Cleaning up..."))
+	if !r.runResetAndVerify() {
+		return false, &fatalError{msg: "failed to reset after " + strings.ToLower(name) + " error"}
+	}
+
+	if agentErr.failure.Kind == FailureFatal {
+		return false, &fatalError{msg: fmt.Sprintf("%s failed in a way retrying won't fix: %s", strings.ToLower(name), agentErr.failure.Reason)}
+	}
+
+	fmt.Fprintln(r.console(), ColorError(fmt.Sprintf("✗ Giving up on candidate %s: %s", candidate.Key, agentErr.failure.Reason)))
+	r.logOutcome(OutcomeAgentFailed, agentErr.failure.Reason)
+	if err := r.ignoreCandidate(candidate); err != nil {
+		return false, err
+	}
+	return false, nil
+}
 
-	// Build passed - now check if candidate was fixed
-	fmt.Println(ColorInfo("Re-checking candidates..."))
-	output, err = RunCandidateSource(r.task.CandidateSource, r.env.ProjectDir, extraEnv)
+// rerunCandidateSource runs the candidate source again after the agent's
+// changes, with the same partition filter as the initial run.
+func (r *Runner) rerunCandidateSource(extraEnv []string) ([]Candidate, error) {
+	fmt.Fprintln(r.console(), ColorInfo("Re-checking candidates..."))
+	output, err := RunCandidateSource(r.procs, r.task.CandidateSource, r.env.ProjectDir, extraEnv)
 	if err != nil {
-		return false, fmt.Errorf("candidate source re-run failed: %w", err)
+		return nil, fmt.Errorf("candidate source re-run failed: %w", err)
 	}
 
 	if r.opts.Verbose {
-		fmt.Printf(ColorInfo("Re-check candidate source output:\n%s\n"), output)
+		fmt.Fprintf(r.console(), ColorInfo("Re-check candidate source output:\n%s\n"), output)
 	}
 
 	newCandidates, err := ParseCandidates(output)
 	if err != nil {
-		return false, fmt.Errorf("failed to parse new candidates: %w", err)
+		return nil, fmt.Errorf("failed to parse new candidates: %w", err)
 	}
 
 	// Apply the same hash filter for consistent verification
-	newCandidates = FilterByPartition(newCandidates, r.opts.Partition)
+	return FilterByPartition(newCandidates, r.opts.Partition), nil
+}
+
+// recheckCandidate re-runs the candidate source and reports whether the
+// candidate is gone.
+func (r *Runner) recheckCandidate(candidate *Candidate, extraEnv []string) (bool, error) {
+	newCandidates, err := r.rerunCandidateSource(extraEnv)
+	if err != nil {
+		return false, err
+	}
 
 	if r.opts.Verbose {
-		fmt.Printf(ColorInfo("Re-check parsed candidates (%d total):\n"), len(newCandidates))
+		fmt.Fprintf(r.console(), ColorInfo("Re-check parsed candidates (%d total):\n"), len(newCandidates))
 		for _, c := range newCandidates {
-			fmt.Printf("  - %s\n", c.Key)
+			fmt.Fprintf(r.console(), "  - %s\n", c.Key)
 		}
-		fmt.Printf(ColorInfo("Looking for candidate: %s\n"), candidate.Key)
-		fmt.Printf(ColorInfo("Candidate found: %v\n"), containsKey(newCandidates, candidate.Key))
+		fmt.Fprintf(r.console(), ColorInfo("Looking for candidate: %s\n"), candidate.Key)
+		fmt.Fprintf(r.console(), ColorInfo("Candidate found: %v\n"), containsKey(newCandidates, candidate.Key))
 	}
 
-	candidateFixed := !containsKey(newCandidates, candidate.Key)
+	if !r.checkRegressions(newCandidates) {
+		return false, nil
+	}
+	r.recordCollateral(candidate, newCandidates)
 
-	if candidateFixed {
-		return r.handleSuccess(candidate, true) // Build already verified
-	} else {
-		return r.handleFailure(candidate)
+	if len(candidate.batch) == 0 {
+		return !containsKey(newCandidates, candidate.Key), nil
+	}
+
+	// Batches count as fixed when any member is gone; the rest are marked
+	// individually once the batch is committed
+	fixed, unfixed := splitBatch(candidate, newCandidates)
+	fmt.Fprintln(r.console(), ColorInfo(fmt.Sprintf("Fixed %d of %d candidates in the batch", len(fixed), len(candidate.batch))))
+	r.batchUnfixed = nil
+	if len(fixed) == 0 {
+		return false, nil
 	}
+	r.batchUnfixed = unfixed
+	return true, nil
 }
 
 func (r *Runner) handleSuccess(candidate *Candidate, buildVerified bool) (bool, error) {
-	fmt.Println(ColorSuccess(fmt.Sprintf("✓ Candidate %s was fixed!", candidate.Key)))
+	// Only the fixed members of a batch count as fixed
+	candidate, err := r.settleBatch(candidate)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Fprintln(r.console(), ColorSuccess(fmt.Sprintf("✓ Candidate %s was fixed!", candidate.Key)))
 
 	// Verify build (unless already verified)
 	if !buildVerified && !r.runVerify() {
-		fmt.Println(ColorWarning("Build verification failed after fix, attempting recovery..."))
+		fmt.Fprintln(r.console(), ColorWarning("Build verification failed after fix, attempting recovery..."))
 		if !r.runReset() {
 			return false, &fatalError{msg: "failed to reset after build failure"}
 		}
 		if !r.runVerify() {
 			return false, &fatalError{msg: "build still fails after reset"}
 		}
-		fmt.Println("Recovered via reset.")
+		fmt.Fprintln(r.console(), "Recovered via reset.")
 		r.logOutcome(OutcomeFixedReverted, "build failed after fix")
-		if r.ignoredList != nil {
-			if err := r.ignoredList.Add(candidate.Key); err != nil {
-				return false, err
-			}
+		if err := r.ignoreCandidate(candidate); err != nil {
+			return false, err
 		}
 		return false, nil
 	}
@@ -777,17 +1158,18 @@ func (r *Runner) handleSuccess(candidate *Candidate, buildVerified bool) (bool,
 		return false, fmt.Errorf("failed to check for changes: %w", err)
 	}
 
-	successCmd := InterpolateCommand(r.getSuccessCommand(), candidate, r.task.Name)
+	successCmd := r.successCommand(candidate)
 
 	if shouldSkipSuccessCommand(successCmd, hasChanges) {
-		fmt.Println(ColorInfo("No changes to commit, skipping git operation"))
+		fmt.Fprintln(r.console(), ColorInfo("No changes to commit, skipping git operation"))
 		r.logOutcome(OutcomeFixed, "no changes to commit")
 	} else {
 		if hasChanges {
-			fmt.Println(ColorInfo("Committing changes..."))
+			fmt.Fprintln(r.console(), ColorInfo("Committing changes..."))
 		} else {
-			fmt.Println(ColorInfo("Running success command..."))
+			fmt.Fprintln(r.console(), ColorInfo("Running success command..."))
 		}
+		r.setPhase(PhaseCommit)
 		ok, err := r.executor.Run(successCmd, r.env.ProjectDir)
 		if err != nil {
 			return false, fmt.Errorf("success command error: %w", err)
@@ -795,37 +1177,47 @@ func (r *Runner) handleSuccess(candidate *Candidate, buildVerified bool) (bool,
 		if !ok {
 			return false, &fatalError{msg: "success command returned non-zero exit code"}
 		}
-		fmt.Println(ColorSuccess("✓ Success"))
+		fmt.Fprintln(r.console(), ColorSuccess("✓ Success"))
 		r.logOutcome(OutcomeFixed, "success command executed")
 	}
+	r.logCollateralFixes(candidate)
 
 	return false, nil
 }
 
 func (r *Runner) handleFailure(candidate *Candidate) (bool, error) {
-	fmt.Println(ColorError(fmt.Sprintf("✗ Candidate %s not fixed.", candidate.Key)))
+	fmt.Fprintln(r.console(), ColorError(fmt.Sprintf("✗ Candidate %s not fixed.", candidate.Key)))
 
-	if r.task.AcceptBestEffort {
-		// Best effort mode: commit if build passes
+	if r.task.AcceptBestEffort && len(r.regressions) == 0 {
+		// Best effort mode: commit if build passes (never with new candidates)
 		if r.runVerify() {
+			violations, err := r.guardrailViolations(candidate)
+			if err != nil {
+				return false, err
+			}
+			if len(violations) > 0 {
+				return r.handleGuardrailViolation(candidate, violations)
+			}
+
 			hasChanges, err := r.executor.HasUncommittedChanges(r.env.ProjectDir)
 			if err != nil {
 				return false, fmt.Errorf("failed to check for changes: %w", err)
 			}
 
-			successCmd := InterpolateCommand(r.getSuccessCommand(), candidate, r.task.Name)
+			successCmd := r.successCommand(candidate)
 			// Modify message for best effort
 			successCmd = replaceBestEffort(successCmd, candidate.Key)
 
 			if shouldSkipSuccessCommand(successCmd, hasChanges) {
-				fmt.Println(ColorInfo("No changes to commit, skipping git operation"))
+				fmt.Fprintln(r.console(), ColorInfo("No changes to commit, skipping git operation"))
 				r.logOutcome(OutcomeBestEffort, "no changes made")
 			} else {
 				if hasChanges {
-					fmt.Println(ColorInfo("Committing partial progress..."))
+					fmt.Fprintln(r.console(), ColorInfo("Committing partial progress..."))
 				} else {
-					fmt.Println(ColorInfo("Running success command..."))
+					fmt.Fprintln(r.console(), ColorInfo("Running success command..."))
 				}
+				r.setPhase(PhaseCommit)
 				ok, err := r.executor.Run(successCmd, r.env.ProjectDir)
 				if err != nil {
 					return false, fmt.Errorf("best effort commit error: %w", err)
@@ -833,12 +1225,12 @@ func (r *Runner) handleFailure(candidate *Candidate) (bool, error) {
 				if !ok {
 					return false, &fatalError{msg: "best effort commit returned non-zero exit code"}
 				}
-				fmt.Println(ColorSuccess("✓ Success"))
+				fmt.Fprintln(r.console(), ColorSuccess("✓ Success"))
 				r.logOutcome(OutcomeBestEffort, "success command executed")
 			}
 		} else {
 			// Build failed, reset
-			fmt.Println(ColorWarning("Build failed, resetting..."))
+			fmt.Fprintln(r.console(), ColorWarning("Build failed, resetting..."))
 			if !r.runResetAndVerify() {
 				return false, &fatalError{msg: "failed to reset"}
 			}
@@ -852,38 +1244,53 @@ func (r *Runner) handleFailure(candidate *Candidate) (bool, error) {
 		r.logOutcome(OutcomeNotFixed, "reverted")
 	}
 
-	if r.ignoredList != nil {
-		if err := r.ignoredList.Add(candidate.Key); err != nil {
-			return false, err
-		}
+	if err := r.ignoreCandidate(candidate); err != nil {
+		return false, err
 	}
 
 	return false, nil
 }
 
-func (r *Runner) handleTimeout(candidate *Candidate) (bool, error) {
-	fmt.Println(ColorWarning(fmt.Sprintf("Candidate %s timed out", candidate.Key)))
+func (r *Runner) handleTimeout(candidate *Candidate, idle bool) (bool, error) {
+	r.timedOut = true
+	fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("Candidate %s timed out", candidate.Key)))
+
+	// Changes left by an idle agent are reverted with their own outcome, so
+	// wedged agents stand out from candidates that ran out of time
+	label, revertedOutcome, buildFailedOutcome := "timeout", OutcomeNotFixed, OutcomeBuildFailed
+	if idle {
+		label, revertedOutcome, buildFailedOutcome = "idle timeout", OutcomeIdleTimeout, OutcomeIdleTimeout
+	}
 
 	if r.task.AcceptBestEffort {
 		// Best effort mode: commit if build passes
 		if r.runVerify() {
+			violations, err := r.guardrailViolations(candidate)
+			if err != nil {
+				return false, err
+			}
+			if len(violations) > 0 {
+				return r.handleGuardrailViolation(candidate, violations)
+			}
+
 			hasChanges, err := r.executor.HasUncommittedChanges(r.env.ProjectDir)
 			if err != nil {
 				return false, fmt.Errorf("failed to check for changes: %w", err)
 			}
 
-			successCmd := InterpolateCommand(r.getSuccessCommand(), candidate, r.task.Name)
+			successCmd := r.successCommand(candidate)
 			successCmd = replaceBestEffort(successCmd, candidate.Key)
 
 			if shouldSkipSuccessCommand(successCmd, hasChanges) {
-				fmt.Println(ColorInfo("No changes to commit, skipping git operation"))
-				r.logOutcome(OutcomeBestEffort, "timeout - no changes made")
+				fmt.Fprintln(r.console(), ColorInfo("No changes to commit, skipping git operation"))
+				r.logOutcome(OutcomeBestEffort, label+" - no changes made")
 			} else {
 				if hasChanges {
-					fmt.Println(ColorInfo("Committing partial progress after timeout..."))
+					fmt.Fprintln(r.console(), ColorInfo("Committing partial progress after timeout..."))
 				} else {
-					fmt.Println(ColorInfo("Running success command..."))
+					fmt.Fprintln(r.console(), ColorInfo("Running success command..."))
 				}
+				r.setPhase(PhaseCommit)
 				ok, err := r.executor.Run(successCmd, r.env.ProjectDir)
 				if err != nil {
 					return false, fmt.Errorf("timeout commit error: %w", err)
@@ -891,29 +1298,27 @@ func (r *Runner) handleTimeout(candidate *Candidate) (bool, error) {
 				if !ok {
 					return false, &fatalError{msg: "timeout commit returned non-zero exit code"}
 				}
-				fmt.Println(ColorSuccess("✓ Success"))
-				r.logOutcome(OutcomeBestEffort, "timeout - success command executed")
+				fmt.Fprintln(r.console(), ColorSuccess("✓ Success"))
+				r.logOutcome(OutcomeBestEffort, label+" - success command executed")
 			}
 		} else {
 			// Build failed, reset
-			fmt.Println(ColorWarning("Build failed after timeout, resetting..."))
+			fmt.Fprintln(r.console(), ColorWarning("Build failed after timeout, resetting..."))
 			if !r.runResetAndVerify() {
 				return false, &fatalError{msg: "failed to reset"}
 			}
-			r.logOutcome(OutcomeBuildFailed, "timeout - reverted")
+			r.logOutcome(buildFailedOutcome, label+" - reverted")
 		}
 	} else {
 		// Standard mode: reset changes
 		if !r.runResetAndVerify() {
 			return false, &fatalError{msg: "failed to reset"}
 		}
-		r.logOutcome(OutcomeNotFixed, "timeout - reverted")
+		r.logOutcome(revertedOutcome, label+" - reverted")
 	}
 
-	if r.ignoredList != nil {
-		if err := r.ignoredList.Add(candidate.Key); err != nil {
-			return false, err
-		}
+	if err := r.ignoreCandidate(candidate); err != nil {
+		return false, err
 	}
 
 	return false, nil
@@ -934,25 +1339,60 @@ func (r *Runner) getPrompt(candidate *Candidate) (string, error) {
 		template = r.task.Prompt
 	}
 
-	return InterpolatePrompt(template, candidate, r.env.TaskID)
+	return InterpolateBatchPrompt(template, candidate.members(), r.env.TaskID)
 }
 
-func (r *Runner) runVerify() bool {
-	if r.env.Config.VerifyCommand == "" {
-		return true
+// defaultRepairPrompt is used for repair rounds when the task has no repair_prompt.
+const defaultRepairPrompt = `You were given the following task:
+
+$PROMPT
+
+Your changes are still in the working tree, but they do not pass yet.
+
+$RECHECK_RESULT
+
+Verify output:
+$VERIFY_OUTPUT
+
+Fix these problems without discarding the work you have already done.`
+
+// getRepairPrompt builds the follow-up prompt for a repair round.
+// In addition to the usual prompt variables it supports $PROMPT (the original
+// prompt), $VERIFY_OUTPUT and $RECHECK_RESULT.
+func (r *Runner) getRepairPrompt(candidate *Candidate, prompt, verifyOutput, recheckResult string) (string, error) {
+	template := r.task.RepairPrompt
+	if template == "" {
+		template = defaultRepairPrompt
 	}
-	fmt.Print(ColorInfo("Verifying build... "))
-	ok, err := r.executor.RunShowOnFail(r.env.Config.VerifyCommand, r.env.ProjectDir)
+
+	result, err := InterpolateBatchPrompt(template, candidate.members(), r.env.TaskID)
 	if err != nil {
-		fmt.Println(ColorError(fmt.Sprintf("Verify command error: %v", err)))
-		return false
+		return "", err
 	}
-	if ok {
-		fmt.Println(ColorInfo("OK"))
+
+	if strings.TrimSpace(verifyOutput) == "" {
+		verifyOutput = "(no output)"
 	}
+
+	// Substitute captured text last so it is never itself interpolated
+	return strings.NewReplacer(
+		"$PROMPT", prompt,
+		"$VERIFY_OUTPUT", strings.TrimSpace(verifyOutput),
+		"$RECHECK_RESULT", recheckResult,
+	).Replace(result), nil
+}
+
+func (r *Runner) runVerify() bool {
+	ok, _ := r.runVerifyCapture()
 	return ok
 }
 
+// runVerifyCapture runs the verify stages, showing output only on failure,
+// and returns the failing stage's output for repair prompts.
+func (r *Runner) runVerifyCapture() (bool, string) {
+	return r.runVerifyStages(false)
+}
+
 func (r *Runner) runReset() bool {
 	if r.env.Config.ResetCommand == "" {
 		return true
@@ -962,36 +1402,33 @@ func (r *Runner) runReset() bool {
 	if err != nil {
 		return false
 	}
+	if ok && r.state != nil {
+		r.stateReset = true
+	}
 	return ok
 }
 
 func (r *Runner) runResetAndVerify() bool {
-	fmt.Print(ColorInfo("Resetting changes and verifying build..."))
+	fmt.Fprint(r.console(), ColorInfo("Resetting changes and verifying build..."))
 
 	// Reset
 	if !r.runReset() {
-		fmt.Println(ColorError(" FAILED"))
+		fmt.Fprintln(r.console(), ColorError(" FAILED"))
 		return false
 	}
 
 	// Verify
-	if r.env.Config.VerifyCommand == "" {
-		fmt.Println(ColorInfo(" OK"))
-		return true
-	}
-
-	ok, err := r.executor.RunSilent(r.env.Config.VerifyCommand, r.env.ProjectDir)
-	if err != nil || !ok {
-		fmt.Println(ColorError(" FAILED"))
+	if ok, _ := r.runVerifyStages(true); !ok {
+		fmt.Fprintln(r.console(), ColorError(" FAILED"))
 		return false
 	}
 
-	fmt.Println(ColorInfo(" OK"))
+	fmt.Fprintln(r.console(), ColorInfo(" OK"))
 	return true
 }
 
 func (r *Runner) runStartupReset() error {
-	fmt.Println(ColorInfo("Resetting environment to clean state..."))
+	fmt.Fprintln(r.console(), ColorInfo("Resetting environment to clean state..."))
 
 	if r.env.Config.ResetCommand == "" {
 		// No reset command configured - check if there are uncommitted changes
@@ -1002,7 +1439,7 @@ func (r *Runner) runStartupReset() error {
 		if hasChanges {
 			return fmt.Errorf("working directory has uncommitted changes but no reset_command configured")
 		}
-		fmt.Println(ColorInfo("No reset_command configured, working directory is clean"))
+		fmt.Fprintln(r.console(), ColorInfo("No reset_command configured, working directory is clean"))
 		return nil
 	}
 
@@ -1015,31 +1452,53 @@ func (r *Runner) runStartupReset() error {
 		return fmt.Errorf("reset command failed")
 	}
 
-	// Verify build after reset
-	if r.env.Config.VerifyCommand != "" {
-		ok, err = r.executor.RunSilent(r.env.Config.VerifyCommand, r.env.ProjectDir)
-		if err != nil || !ok {
-			return fmt.Errorf("build verification failed after reset")
-		}
+	// Verify build after reset; no candidate is in flight yet
+	if ok, _ := r.runVerifyStages(true); !ok {
+		return fmt.Errorf("build verification failed after reset")
 	}
 
-	fmt.Println(ColorSuccess("✓ Environment reset complete"))
+	fmt.Fprintln(r.console(), ColorSuccess("✓ Environment reset complete"))
 	return nil
 }
 
 func (r *Runner) modeString() string {
+	mode := "standard"
 	if r.opts.DryRun {
-		return "dry-run"
-	}
-	if r.task.AcceptBestEffort {
-		return "best-effort"
+		mode = "dry-run"
+	} else if r.task.AcceptBestEffort {
+		mode = "best-effort"
+	}
+	if r.order.Strategy != OrderSource {
+		// Includes the seed for random order so a run can be reproduced
+		mode += ", order " + r.order.String()
+	}
+	if len(r.schedules) > 0 {
+		names := make([]string, len(r.schedules))
+		for i, s := range r.schedules {
+			names[i] = s.name
+		}
+		mode += ", schedule " + strings.Join(names, ",")
 	}
-	return "standard"
+	return mode
 }
 
 func (r *Runner) logOutcome(outcome Outcome, details string) {
+	r.outcome = outcome
+	r.countOutcome(outcome, 1)
+	if batch := r.batchDetails(); batch != "" {
+		details += ", " + batch
+	}
+	if scores := r.scoreDetails(); scores != "" {
+		details += ", " + scores
+	}
+	if regressions := r.regressionDetails(); regressions != "" {
+		details += ", " + regressions
+	}
+	if !r.usage.IsZero() {
+		fmt.Fprintln(r.console(), ColorInfo("Usage: "+r.usage.String()))
+	}
 	if r.agentLogger != nil {
-		r.agentLogger.LogOutcome(outcome, details)
+		r.agentLogger.LogOutcome(outcome, details, r.usage)
 	}
 }
 
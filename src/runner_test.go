@@ -63,94 +63,6 @@ func TestCalculateBackoff(t *testing.T) {
 	}
 }
 
-func TestPeakSchedules(t *testing.T) {
-	eastern, err := time.LoadLocation("America/New_York")
-	if err != nil {
-		t.Fatalf("failed to load eastern timezone: %v", err)
-	}
-	china, err := time.LoadLocation("Asia/Shanghai")
-	if err != nil {
-		t.Fatalf("failed to load china timezone: %v", err)
-	}
-
-	tests := []struct {
-		name       string
-		at         time.Time
-		onPeak     bool
-		wait       time.Duration
-		checkPeak  func(time.Time) bool
-		checkUntil func(time.Time) time.Duration
-	}{
-		{
-			name:       "eastern weekday before peak",
-			at:         time.Date(2026, 6, 22, 7, 59, 0, 0, eastern),
-			onPeak:     false,
-			wait:       0,
-			checkPeak:  isOnPeak,
-			checkUntil: timeUntilOffPeak,
-		},
-		{
-			name:       "eastern weekday peak starts at 8am",
-			at:         time.Date(2026, 6, 22, 8, 0, 0, 0, eastern),
-			onPeak:     true,
-			wait:       6 * time.Hour,
-			checkPeak:  isOnPeak,
-			checkUntil: timeUntilOffPeak,
-		},
-		{
-			name:       "eastern weekday peak ends at 2pm",
-			at:         time.Date(2026, 6, 22, 14, 0, 0, 0, eastern),
-			onPeak:     false,
-			wait:       0,
-			checkPeak:  isOnPeak,
-			checkUntil: timeUntilOffPeak,
-		},
-		{
-			name:       "eastern weekend is off peak",
-			at:         time.Date(2026, 6, 27, 9, 0, 0, 0, eastern),
-			onPeak:     false,
-			wait:       0,
-			checkPeak:  isOnPeak,
-			checkUntil: timeUntilOffPeak,
-		},
-		{
-			name:       "china peak starts at 14",
-			at:         time.Date(2026, 6, 22, 14, 0, 0, 0, china),
-			onPeak:     true,
-			wait:       4 * time.Hour,
-			checkPeak:  isChinaOnPeak,
-			checkUntil: timeUntilChinaOffPeak,
-		},
-		{
-			name:       "china peak applies on weekends",
-			at:         time.Date(2026, 6, 27, 16, 30, 0, 0, china),
-			onPeak:     true,
-			wait:       90 * time.Minute,
-			checkPeak:  isChinaOnPeak,
-			checkUntil: timeUntilChinaOffPeak,
-		},
-		{
-			name:       "china peak ends at 18",
-			at:         time.Date(2026, 6, 22, 18, 0, 0, 0, china),
-			onPeak:     false,
-			wait:       0,
-			checkPeak:  isChinaOnPeak,
-			checkUntil: timeUntilChinaOffPeak,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.checkPeak(tt.at); got != tt.onPeak {
-				t.Errorf("peak check = %v, want %v", got, tt.onPeak)
-			}
-			if got := tt.checkUntil(tt.at); got != tt.wait {
-				t.Errorf("time until off peak = %v, want %v", got, tt.wait)
-			}
-		})
-	}
-}
-
 func TestVerboseDryRunShowsCodexCommandWithYolo(t *testing.T) {
 	tmpDir := t.TempDir()
 	taskDir := filepath.Join(tmpDir, "test-task")
@@ -678,7 +590,7 @@ func TestHandleTimeout_CommitFailureIsFatal(t *testing.T) {
 	candidate := &Candidate{Key: "test-candidate"}
 
 	// Handle timeout in best effort mode should return fatalError when commit fails
-	_, err = runner.handleTimeout(candidate)
+	_, err = runner.handleTimeout(candidate, false)
 
 	if err == nil {
 		t.Fatal("handleTimeout with commit failure should return an error")
@@ -801,3 +713,415 @@ func TestRequestStopIsIdempotent(t *testing.T) {
 		t.Fatal("expected stopCh to be closed")
 	}
 }
+
+func TestSyncWriterPrefixedBuffersWholeLines(t *testing.T) {
+	var buf strings.Builder
+	root := NewSyncWriter(&buf)
+	a := root.Prefixed("[a] ")
+	b := root.Prefixed("[b] ")
+
+	a.WriteString("hello ")
+	b.WriteString("other line\n")
+	a.WriteString("world\nsecond")
+	a.ResetColor() // flushes the incomplete line
+
+	want := "[b] other line\n[a] hello world\n[a] second\n"
+	if buf.String() != want {
+		t.Fatalf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestSyncWriterPrefixedAppliesColorPerLine(t *testing.T) {
+	var buf strings.Builder
+	w := NewSyncWriter(&buf).Prefixed("> ")
+
+	w.SetColor(colorDim)
+	w.WriteString("one\ntwo\n")
+	w.ResetColor()
+	w.WriteString("plain\n")
+
+	want := "> " + colorDim + "one" + colorReset + "\n" +
+		"> " + colorDim + "two" + colorReset + "\n" +
+		"> plain\n"
+	if buf.String() != want {
+		t.Fatalf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+// newScriptedRunner creates a runner whose agent is a shell script in a temp
+// project. The script sees the number of times it has been called in $CALLS.
+func newScriptedRunner(t *testing.T, agentScript string, task Task, config Config) (*Runner, string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	taskDir := filepath.Join(tmpDir, "test-task")
+	if err := os.Mkdir(taskDir, 0755); err != nil {
+		t.Fatalf("failed to create task dir: %v", err)
+	}
+
+	agentPath := filepath.Join(tmpDir, "fake-agent")
+	script := "#!/bin/bash\nCALLS=$(( $(cat .calls 2>/dev/null || echo 0) + 1 ))\necho $CALLS > .calls\ncat > .prompt-$CALLS\n" +
+		agentScript + "\necho '{\"type\":\"result\"}'\n"
+	if err := os.WriteFile(agentPath, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write agent script: %v", err)
+	}
+
+	task.Name = "test-task"
+	task.Dir = taskDir
+	if task.Prompt == "" {
+		task.Prompt = "fix $INPUT"
+	}
+	if task.Timeout == 0 {
+		task.Timeout = time.Minute
+	}
+	config.Agent = agentPath
+
+	env := &Environment{
+		ProjectDir: tmpDir,
+		Config:     config,
+		Tasks:      map[string]Task{"test-task": task},
+	}
+
+	runner, err := NewRunner(env, "test-task", RunnerOptions{})
+	if err != nil {
+		t.Fatalf("NewRunner failed: %v", err)
+	}
+	t.Cleanup(func() { runner.agentLogger.Close() })
+	if err := runner.prepare(); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	return runner, tmpDir
+}
+
+func TestRunIterationRepairRoundFixesCandidate(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`if [ "$CALLS" -ge 2 ]; then touch fixed; fi`,
+		Task{
+			CandidateSource: `if [ -f fixed ]; then echo '[]'; else echo '["c1"]'; fi`,
+			MaxRepairRounds: 2,
+		},
+		Config{
+			VerifyCommand:  "echo verify-said-no; test -f fixed",
+			ResetCommand:   "true",
+			SuccessCommand: "touch committed",
+		},
+	)
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	if _, err := os.Stat(filepath.Join(dir, "committed")); err != nil {
+		t.Fatal("expected success command to run after repair round")
+	}
+	repairPrompt, err := os.ReadFile(filepath.Join(dir, ".prompt-2"))
+	if err != nil {
+		t.Fatalf("expected a second agent call: %v", err)
+	}
+	for _, want := range []string{"verify-said-no", "fix c1", "build failed"} {
+		if !strings.Contains(string(repairPrompt), want) {
+			t.Errorf("repair prompt missing %q:\n%s", want, repairPrompt)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".prompt-3")); err == nil {
+		t.Error("agent called again after the candidate was fixed")
+	}
+
+	log, _ := os.ReadFile(runner.agentLogger.Path())
+	if !strings.Contains(string(log), "Repair round 1/2") {
+		t.Errorf("agent log missing repair sub-entry:\n%s", log)
+	}
+}
+
+func TestRunIterationRepairRoundsExhausted(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		"true",
+		Task{
+			CandidateSource: `echo '["c1"]'`,
+			MaxRepairRounds: 1,
+			RepairPrompt:    "still there: $RECHECK_RESULT",
+		},
+		Config{
+			ResetCommand:   "touch reset",
+			SuccessCommand: "touch committed",
+		},
+	)
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	calls, _ := os.ReadFile(filepath.Join(dir, ".calls"))
+	if strings.TrimSpace(string(calls)) != "2" {
+		t.Fatalf("agent calls = %q, want 2 (initial + 1 repair round)", calls)
+	}
+	repairPrompt, _ := os.ReadFile(filepath.Join(dir, ".prompt-2"))
+	if !strings.Contains(string(repairPrompt), "still there: The build passed, but the candidate source still reports: c1") {
+		t.Errorf("repair prompt = %q, want templated re-check result", repairPrompt)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reset")); err != nil {
+		t.Error("expected reset after repair rounds ran out")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "committed")); err == nil {
+		t.Error("success command ran for an unfixed candidate")
+	}
+	if !runner.ignoredList.Contains("c1") {
+		t.Error("expected candidate to be ignored after repair rounds ran out")
+	}
+}
+
+func TestRunIterationTimeoutContinuationResumesSession(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`echo "$@" > .args-$CALLS
+echo '{"type":"system","subtype":"init","session_id":"sess-1"}'
+if [ "$CALLS" -eq 1 ]; then sleep 10; else touch fixed; fi`,
+		Task{
+			CandidateSource:      `if [ -f fixed ]; then echo '[]'; else echo '["c1"]'; fi`,
+			Timeout:              time.Second,
+			TimeoutContinuations: 1,
+			ContinuationTimeout:  time.Minute,
+		},
+		Config{
+			ResetCommand:   "touch reset",
+			SuccessCommand: "touch committed",
+		},
+	)
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	args, _ := os.ReadFile(filepath.Join(dir, ".args-2"))
+	if !strings.Contains(string(args), "--resume sess-1") {
+		t.Fatalf("continuation args = %q, want --resume sess-1", args)
+	}
+	prompt, _ := os.ReadFile(filepath.Join(dir, ".prompt-2"))
+	if !strings.Contains(string(prompt), "You have 1m 00s left") {
+		t.Errorf("continuation prompt = %q, want remaining budget", prompt)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "committed")); err != nil {
+		t.Error("expected success command after the continuation fixed the candidate")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reset")); err == nil {
+		t.Error("reset ran even though the continuation succeeded")
+	}
+}
+
+func TestRunIterationTimeoutWithoutSessionFallsBack(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`sleep 10`,
+		Task{
+			CandidateSource:      `echo '["c1"]'`,
+			Timeout:              time.Second,
+			TimeoutContinuations: 1,
+			ContinuationTimeout:  time.Minute,
+		},
+		Config{
+			ResetCommand:   "touch reset",
+			SuccessCommand: "touch committed",
+		},
+	)
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	calls, _ := os.ReadFile(filepath.Join(dir, ".calls"))
+	if strings.TrimSpace(string(calls)) != "1" {
+		t.Fatalf("agent calls = %q, want 1 (no session to resume)", calls)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reset")); err != nil {
+		t.Error("expected reset after the timeout")
+	}
+	if !runner.ignoredList.Contains("c1") {
+		t.Error("expected candidate to be ignored after the timeout")
+	}
+}
+
+func TestRunIterationIdleTimeout(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`echo '{"type":"system","subtype":"init","session_id":"sess-1"}'
+echo 'touching files' >&2
+sleep 10`,
+		Task{
+			CandidateSource:      `echo '["c1"]'`,
+			IdleTimeout:          200 * time.Millisecond,
+			TimeoutContinuations: 1,
+			ContinuationTimeout:  time.Minute,
+		},
+		Config{
+			ResetCommand:   "touch reset",
+			SuccessCommand: "touch committed",
+		},
+	)
+
+	stdout := captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	if !strings.Contains(stdout, "produced no output for 200ms") {
+		t.Errorf("stdout missing the idle timeout:\n%s", stdout)
+	}
+	calls, _ := os.ReadFile(filepath.Join(dir, ".calls"))
+	if strings.TrimSpace(string(calls)) != "1" {
+		t.Fatalf("agent calls = %q, want 1 (idle sessions are not continued)", calls)
+	}
+	if runner.outcome != OutcomeIdleTimeout {
+		t.Errorf("outcome = %s, want %s", runner.outcome, OutcomeIdleTimeout)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reset")); err != nil {
+		t.Error("expected reset after the idle timeout")
+	}
+	if !runner.ignoredList.Contains("c1") {
+		t.Error("expected candidate to be ignored after the idle timeout")
+	}
+	log, _ := os.ReadFile(runner.agentLogger.Path())
+	for _, want := range []string{"Outcome: IDLE_TIMEOUT", `"session_id":"sess-1"`, "Stderr:\ntouching files"} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("agent log missing %q:\n%s", want, log)
+		}
+	}
+}
+
+func TestRunIterationRecordsStateWhileCandidateInFlight(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`cp test-task/state.json .state-during-agent; touch fixed`,
+		Task{CandidateSource: `if [ -f fixed ]; then echo '[]'; else echo '["c1"]'; fi`},
+		Config{SuccessCommand: "true"},
+	)
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	state, err := LoadRunState(filepath.Join(dir, ".state-during-agent"))
+	if err != nil || state == nil {
+		t.Fatalf("expected state during agent phase, got %v, %v", state, err)
+	}
+	if state.Candidate != "c1" || state.Phase != PhaseAgent {
+		t.Errorf("state = %+v, want candidate c1 in agent phase", state)
+	}
+	if _, err := os.Stat(StatePath(runner.task.Dir)); !os.IsNotExist(err) {
+		t.Error("expected state to be cleared after the candidate was resolved")
+	}
+}
+
+func TestRunIterationClearsStateOnceReset(t *testing.T) {
+	runner, _ := newScriptedRunner(t,
+		"echo 'connection reset by peer' >&2; exit 1",
+		Task{CandidateSource: `echo '["c1"]'`},
+		Config{ResetCommand: "touch reset"},
+	)
+	runner.opts.Resume = true
+
+	stdout := captureStdout(t, func() {
+		if _, err := runner.runIteration(); err == nil {
+			t.Fatal("expected the agent error to be returned")
+		}
+		if err := runner.recoverInterruptedRun(); err != nil {
+			t.Fatalf("recoverInterruptedRun failed: %v", err)
+		}
+	})
+
+	if _, err := os.Stat(runner.statePath); !os.IsNotExist(err) {
+		t.Error("expected state to be cleared once the changes were reset")
+	}
+	if strings.Contains(stdout, "Previous run was interrupted") {
+		t.Errorf("recovery reported a candidate that was already reset:\n%s", stdout)
+	}
+}
+
+func TestRecoverInterruptedRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		resume      bool
+		answer      string
+		wantCommit  bool
+		wantReset   bool
+		wantIgnored bool
+	}{
+		{name: "without --resume", resume: false},
+		{name: "commit", resume: true, answer: "c\n", wantCommit: true},
+		{name: "reset", resume: true, answer: "reset\n", wantReset: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, _ := newScriptedRunner(t, "true",
+				Task{CandidateSource: `echo '[]'`},
+				Config{SuccessCommand: "commit $CANDIDATE", ResetCommand: "reset"},
+			)
+			mock := NewMockCommandExecutor()
+			mock.HasChangesResult = true
+			runner.setExecutor(mock)
+			runner.opts.Resume = tt.resume
+			runner.input = strings.NewReader(tt.answer)
+
+			if err := SaveRunState(runner.statePath, &RunState{Candidate: "c1", Phase: PhaseVerify}); err != nil {
+				t.Fatal(err)
+			}
+
+			captureStdout(t, func() {
+				if err := runner.recoverInterruptedRun(); err != nil {
+					t.Fatalf("recoverInterruptedRun failed: %v", err)
+				}
+			})
+
+			var committed, reset bool
+			for _, call := range mock.Calls {
+				committed = committed || call.Command == "commit 'c1'"
+				reset = reset || call.Command == "reset"
+			}
+			if committed != tt.wantCommit {
+				t.Errorf("committed = %v, want %v", committed, tt.wantCommit)
+			}
+			if reset != tt.wantReset {
+				t.Errorf("reset = %v, want %v", reset, tt.wantReset)
+			}
+			if _, err := os.Stat(runner.statePath); !os.IsNotExist(err) {
+				t.Error("expected state to be cleared after recovery")
+			}
+		})
+	}
+}
+
+func TestRecoverInterruptedRunWithoutAnswerKeepsChanges(t *testing.T) {
+	runner, _ := newScriptedRunner(t, "true",
+		Task{CandidateSource: `echo '[]'`},
+		Config{ResetCommand: "reset"},
+	)
+	mock := NewMockCommandExecutor()
+	mock.HasChangesResult = true
+	runner.setExecutor(mock)
+	runner.opts.Resume = true
+	runner.input = strings.NewReader("")
+
+	if err := SaveRunState(runner.statePath, &RunState{Candidate: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	captureStdout(t, func() {
+		if err := runner.recoverInterruptedRun(); err == nil {
+			t.Fatal("expected an error when no answer is given")
+		}
+	})
+
+	if len(mock.Calls) != 0 {
+		t.Errorf("expected no commands to run, got %v", mock.Calls)
+	}
+	if _, err := os.Stat(runner.statePath); err != nil {
+		t.Error("expected state to be kept when nothing was decided")
+	}
+}
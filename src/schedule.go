@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schedule modes
+const (
+	ScheduleDeny  = "deny"  // Pause while the window is open
+	ScheduleAllow = "allow" // Only run while the window is open
+)
+
+// Schedule is a named time window from the `schedules:` section of
+// config.yaml. Tasks and --schedule pick which schedules apply to a run.
+type Schedule struct {
+	Timezone string   `yaml:"timezone"` // IANA time zone (default: local time)
+	Days     []string `yaml:"days"`     // mon..sun, weekdays or weekends (default: every day)
+	Start    string   `yaml:"start"`    // HH:MM (default 00:00)
+	End      string   `yaml:"end"`      // HH:MM (default 24:00); before start for windows past midnight
+	Mode     string   `yaml:"mode"`     // deny (default) or allow
+
+	name         string
+	loc          *time.Location
+	days         [7]bool // Indexed by time.Weekday
+	startMinutes int
+	endMinutes   int
+}
+
+// builtinSchedules are always available, and back the --off-peak-only and
+// --china-off-peak-only flags. config.yaml may redefine them.
+var builtinSchedules = map[string]Schedule{
+	"off-peak": {
+		Timezone: "America/New_York",
+		Days:     []string{"weekdays"},
+		Start:    "08:00",
+		End:      "14:00",
+	},
+	"china-off-peak": {
+		Timezone: "Asia/Shanghai",
+		Start:    "14:00",
+		End:      "18:00",
+	},
+}
+
+// parse validates the schedule and fills in its parsed fields.
+func (s *Schedule) parse(name string) error {
+	s.name = name
+
+	switch s.Mode {
+	case "":
+		s.Mode = ScheduleDeny
+	case ScheduleDeny, ScheduleAllow:
+	default:
+		return fmt.Errorf("schedule %s has invalid 'mode' %q (expected allow or deny)", name, s.Mode)
+	}
+
+	s.loc = time.Local
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("schedule %s has invalid 'timezone': %w", name, err)
+		}
+		s.loc = loc
+	}
+
+	s.days = [7]bool{}
+	if len(s.Days) == 0 {
+		s.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, day := range s.Days {
+		weekdays, ok := parseDays(day)
+		if !ok {
+			return fmt.Errorf("schedule %s has invalid day %q", name, day)
+		}
+		for _, d := range weekdays {
+			s.days[d] = true
+		}
+	}
+
+	var err error
+	if s.startMinutes, err = parseClock(s.Start, 0); err != nil {
+		return fmt.Errorf("schedule %s has invalid 'start': %w", name, err)
+	}
+	if s.endMinutes, err = parseClock(s.End, 24*60); err != nil {
+		return fmt.Errorf("schedule %s has invalid 'end': %w", name, err)
+	}
+	if s.startMinutes == s.endMinutes {
+		return fmt.Errorf("schedule %s 'start' and 'end' must differ", name)
+	}
+	return nil
+}
+
+// parseDays maps a day name, or weekdays/weekends, to the days it covers.
+func parseDays(day string) ([]time.Weekday, bool) {
+	day = strings.ToLower(strings.TrimSpace(day))
+	switch day {
+	case "weekdays":
+		return []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, true
+	case "weekends":
+		return []time.Weekday{time.Saturday, time.Sunday}, true
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if day == full || day == full[:3] {
+			return []time.Weekday{d}, true
+		}
+	}
+	return nil, false
+}
+
+// parseClock parses HH:MM into minutes after midnight. 24:00 is allowed as
+// the end of the day.
+func parseClock(clock string, fallback int) (int, error) {
+	if clock == "" {
+		return fallback, nil
+	}
+	var hour, minute int
+	if _, err := fmt.Sscanf(clock, "%d:%d", &hour, &minute); err != nil || len(clock) != 5 {
+		return 0, fmt.Errorf("%q is not HH:MM", clock)
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("%q is not a time of day", clock)
+	}
+	return hour*60 + minute, nil
+}
+
+// isOpen reports whether the window is open at t. A window that ends before it
+// starts runs past midnight and belongs to the day it started on.
+func (s *Schedule) isOpen(t time.Time) bool {
+	local := t.In(s.loc)
+	hour, minute, _ := local.Clock()
+	minutes := hour*60 + minute
+	day := local.Weekday()
+
+	if s.startMinutes < s.endMinutes {
+		return s.days[day] && minutes >= s.startMinutes && minutes < s.endMinutes
+	}
+	if minutes >= s.startMinutes {
+		return s.days[day]
+	}
+	if minutes < s.endMinutes {
+		return s.days[(day+6)%7]
+	}
+	return false
+}
+
+// String describes the schedule for the console, e.g.
+// `"off-peak" (deny Mon,Tue,Wed,Thu,Fri 08:00-14:00 America/New_York)`.
+func (s *Schedule) String() string {
+	var days []string
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if s.days[d] {
+			days = append(days, d.String()[:3])
+		}
+	}
+	when := strings.Join(days, ",") + " "
+	if len(days) == 7 {
+		when = "daily "
+	}
+	clock := func(m int) string { return fmt.Sprintf("%02d:%02d", m/60, m%60) }
+	return fmt.Sprintf("%q (%s %s%s-%s %s)", s.name, s.Mode, when, clock(s.startMinutes), clock(s.endMinutes), s.loc)
+}
+
+// resolveSchedules looks up schedules by name in config.yaml, then among the
+// built-in ones.
+func resolveSchedules(names []string, configured map[string]Schedule) ([]*Schedule, error) {
+	var schedules []*Schedule
+	for _, name := range names {
+		schedule, ok := configured[name]
+		if !ok {
+			schedule, ok = builtinSchedules[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown schedule %q (available: %s)", name, strings.Join(scheduleNames(configured), ", "))
+		}
+		if err := schedule.parse(name); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, &schedule)
+	}
+	return schedules, nil
+}
+
+// scheduleNames lists the configured and built-in schedule names.
+func scheduleNames(configured map[string]Schedule) []string {
+	var names []string
+	for name := range builtinSchedules {
+		if _, ok := configured[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for name := range configured {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pausedBy returns the schedule that pauses runs at t: an open deny window,
+// or the first allow window when none of them is open.
+func pausedBy(schedules []*Schedule, t time.Time) (*Schedule, bool) {
+	var firstAllow *Schedule
+	allowOpen := false
+	for _, s := range schedules {
+		switch s.Mode {
+		case ScheduleDeny:
+			if s.isOpen(t) {
+				return s, true
+			}
+		case ScheduleAllow:
+			if firstAllow == nil {
+				firstAllow = s
+			}
+			allowOpen = allowOpen || s.isOpen(t)
+		}
+	}
+	if firstAllow != nil && !allowOpen {
+		return firstAllow, true
+	}
+	return nil, false
+}
+
+// nextRunTime returns the first minute after t at which the schedules let the
+// run continue, or false if they never do within a week.
+func nextRunTime(schedules []*Schedule, t time.Time) (time.Time, bool) {
+	next := t.Truncate(time.Minute)
+	for i := 0; i <= 8*24*60; i++ {
+		next = next.Add(time.Minute)
+		if _, paused := pausedBy(schedules, next); !paused {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// waitForSchedule pauses while the run's schedules don't allow it to run.
+// Returns true if a graceful stop was requested during the wait.
+func (r *Runner) waitForSchedule() bool {
+	schedule, paused := pausedBy(r.schedules, time.Now())
+	if !paused {
+		return false
+	}
+
+	if resume, ok := nextRunTime(r.schedules, time.Now()); ok {
+		fmt.Fprintf(r.console(), ColorInfo("Paused by schedule %s. Waiting %s until %s...\n"),
+			schedule, time.Until(resume).Round(time.Minute), resume.Format("2006-01-02 15:04"))
+	} else {
+		fmt.Fprintf(r.console(), ColorWarning("Paused by schedule %s, which never allows running. Waiting...\n"), schedule)
+	}
+
+	// Re-check every minute, waking early if a stop is requested
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if _, paused := pausedBy(r.schedules, time.Now()); !paused {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-r.stopCh:
+			fmt.Fprintln(r.console(), "Stopped by user request during scheduled pause.")
+			return true
+		}
+	}
+
+	fmt.Fprintln(r.console(), ColorInfo("Schedule allows running again. Continuing..."))
+	return false
+}
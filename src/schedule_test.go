@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuiltinSchedules(t *testing.T) {
+	eastern, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("failed to load eastern timezone: %v", err)
+	}
+	china, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("failed to load china timezone: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		schedule string
+		at       time.Time
+		paused   bool
+		wait     time.Duration
+	}{
+		{"eastern weekday before peak", "off-peak", time.Date(2026, 6, 22, 7, 59, 0, 0, eastern), false, 0},
+		{"eastern weekday peak starts at 8am", "off-peak", time.Date(2026, 6, 22, 8, 0, 0, 0, eastern), true, 6 * time.Hour},
+		{"eastern weekday peak ends at 2pm", "off-peak", time.Date(2026, 6, 22, 14, 0, 0, 0, eastern), false, 0},
+		{"eastern weekend is off peak", "off-peak", time.Date(2026, 6, 27, 9, 0, 0, 0, eastern), false, 0},
+		{"china peak starts at 14", "china-off-peak", time.Date(2026, 6, 22, 14, 0, 0, 0, china), true, 4 * time.Hour},
+		{"china peak applies on weekends", "china-off-peak", time.Date(2026, 6, 27, 16, 30, 0, 0, china), true, 90 * time.Minute},
+		{"china peak ends at 18", "china-off-peak", time.Date(2026, 6, 22, 18, 0, 0, 0, china), false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedules, err := resolveSchedules([]string{tt.schedule}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, paused := pausedBy(schedules, tt.at); paused != tt.paused {
+				t.Errorf("paused = %v, want %v", paused, tt.paused)
+			}
+			if !tt.paused {
+				return
+			}
+			resume, ok := nextRunTime(schedules, tt.at)
+			if !ok || resume.Sub(tt.at) != tt.wait {
+				t.Errorf("resumes after %v, want %v", resume.Sub(tt.at), tt.wait)
+			}
+		})
+	}
+}
+
+func TestConfiguredSchedules(t *testing.T) {
+	configured := map[string]Schedule{
+		"nights":   {Timezone: "UTC", Start: "22:00", End: "07:00", Mode: ScheduleAllow},
+		"weekends": {Timezone: "UTC", Days: []string{"weekends"}, Mode: ScheduleAllow},
+		"standup":  {Timezone: "UTC", Days: []string{"mon", "Wednesday", "fri"}, Start: "09:30", End: "10:00"},
+	}
+	schedules, err := resolveSchedules([]string{"nights", "weekends", "standup"}, configured)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2026-06-22 is a Monday
+	tests := []struct {
+		name   string
+		at     time.Time
+		paused bool
+		wait   time.Duration
+	}{
+		{"weekday afternoon", time.Date(2026, 6, 22, 15, 0, 0, 0, time.UTC), true, 7 * time.Hour},
+		{"weekday night", time.Date(2026, 6, 22, 23, 0, 0, 0, time.UTC), false, 0},
+		{"after midnight belongs to the night before", time.Date(2026, 6, 23, 6, 59, 0, 0, time.UTC), false, 0},
+		{"night ends at 7", time.Date(2026, 6, 23, 7, 0, 0, 0, time.UTC), true, 15 * time.Hour},
+		{"friday night runs into saturday", time.Date(2026, 6, 27, 3, 0, 0, 0, time.UTC), false, 0},
+		{"weekend day", time.Date(2026, 6, 28, 12, 0, 0, 0, time.UTC), false, 0},
+		{"sunday night into monday", time.Date(2026, 6, 29, 6, 0, 0, 0, time.UTC), false, 0},
+		{"deny wins over allow", time.Date(2026, 6, 29, 9, 45, 0, 0, time.UTC), true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, paused := pausedBy(schedules, tt.at); paused != tt.paused {
+				t.Errorf("paused = %v, want %v", paused, tt.paused)
+			}
+			if tt.paused && tt.wait > 0 {
+				if resume, _ := nextRunTime(schedules, tt.at); resume.Sub(tt.at) != tt.wait {
+					t.Errorf("resumes after %v, want %v", resume.Sub(tt.at), tt.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+	}{
+		{"bad mode", Schedule{Mode: "sometimes"}},
+		{"bad timezone", Schedule{Timezone: "Nowhere/Land"}},
+		{"bad day", Schedule{Days: []string{"someday"}}},
+		{"bad start", Schedule{Start: "9am"}},
+		{"end past midnight", Schedule{End: "24:30"}},
+		{"empty window", Schedule{Start: "09:00", End: "09:00"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.schedule.parse("test"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	if _, err := resolveSchedules([]string{"missing"}, nil); err == nil {
+		t.Error("expected an error for an unknown schedule")
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Acceptance rules comparing the score after the agent's changes with the
+// score before them. Lower scores are better unless the rule is "increased".
+const (
+	AcceptDecreased         = "decreased"
+	AcceptIncreased         = "increased"
+	AcceptUnchangedOrBetter = "unchanged_or_better"
+)
+
+// scoreAccepted reports whether going from before to after satisfies rule.
+func scoreAccepted(rule string, before, after float64) bool {
+	switch rule {
+	case AcceptDecreased:
+		return after < before
+	case AcceptIncreased:
+		return after > before
+	case AcceptUnchangedOrBetter:
+		return after <= before
+	}
+	return false
+}
+
+// acceptDescription describes what rule needs, for repair prompts.
+func acceptDescription(rule string) string {
+	switch rule {
+	case AcceptDecreased:
+		return "decrease"
+	case AcceptIncreased:
+		return "increase"
+	}
+	return "stay the same or decrease"
+}
+
+// measureScoreBefore records the score before the agent runs. It is kept in
+// the run state so a resumed run can still judge the leftover changes.
+func (r *Runner) measureScoreBefore() error {
+	fmt.Fprint(r.console(), ColorInfo("Measuring score... "))
+	score, err := r.runScore()
+	if err != nil {
+		fmt.Fprintln(r.console())
+		return err
+	}
+	fmt.Fprintln(r.console(), ColorInfo(formatScore(score)))
+
+	r.scoreBefore = &score
+	if r.state != nil {
+		r.state.ScoreBefore = &score
+		r.saveState()
+	}
+	return nil
+}
+
+// checkFixed decides whether the agent's changes fixed the candidate: by
+// comparing scores when accept_if is set, otherwise by the candidate
+// disappearing from the source. When it isn't fixed, reason says why.
+func (r *Runner) checkFixed(candidate *Candidate, extraEnv []string) (fixed bool, reason string, err error) {
+	if r.task.AcceptIf == "" {
+		fixed, err := r.recheckCandidate(candidate, extraEnv)
+		if err != nil || fixed {
+			return fixed, "", err
+		}
+		if len(r.regressions) > 0 {
+			return false, r.regressionReason(), nil
+		}
+		return false, fmt.Sprintf("The build passed, but the candidate source still reports: %s", candidate.Key), nil
+	}
+
+	if r.scoreBefore == nil {
+		return false, "No score was recorded before the changes were made.", nil
+	}
+
+	fmt.Fprint(r.console(), ColorInfo("Measuring score... "))
+	after, err := r.runScore()
+	if err != nil {
+		fmt.Fprintln(r.console())
+		return false, fmt.Sprintf("The build passed, but the score could not be measured: %v", err), nil
+	}
+	r.scoreAfter = &after
+	fmt.Fprintln(r.console(), ColorInfo(fmt.Sprintf("%s (was %s)", formatScore(after), formatScore(*r.scoreBefore))))
+
+	if scoreAccepted(r.task.AcceptIf, *r.scoreBefore, after) {
+		candidates, err := r.rerunCandidateSource(extraEnv)
+		if err != nil {
+			return false, "", err
+		}
+		if !r.checkRegressions(candidates) {
+			return false, r.regressionReason(), nil
+		}
+		r.recordCollateral(candidate, candidates)
+		return true, "", nil
+	}
+	return false, fmt.Sprintf("The build passed, but the score went from %s to %s; it needs to %s.",
+		formatScore(*r.scoreBefore), formatScore(after), acceptDescription(r.task.AcceptIf)), nil
+}
+
+// successCommand returns the interpolated success command, including the
+// $SCORE_BEFORE and $SCORE_AFTER variables (empty when not measured) and
+// $FIXED_CANDIDATES, one fixed candidate per line.
+func (r *Runner) successCommand(candidate *Candidate) string {
+	cmd := InterpolateCommand(r.getSuccessCommand(), candidate, r.task.Name)
+	return strings.NewReplacer(
+		"$SCORE_BEFORE", optionalScore(r.scoreBefore),
+		"$SCORE_AFTER", optionalScore(r.scoreAfter),
+		"$FIXED_CANDIDATES", shellQuote(strings.Join(r.fixedCandidates(candidate), "\n")),
+	).Replace(cmd)
+}
+
+// scoreDetails describes the score change for the log outcome, or "" when no
+// scores were measured.
+func (r *Runner) scoreDetails() string {
+	if r.scoreBefore == nil {
+		return ""
+	}
+	after := "not measured"
+	if r.scoreAfter != nil {
+		after = formatScore(*r.scoreAfter)
+	}
+	return fmt.Sprintf("score %s -> %s", formatScore(*r.scoreBefore), after)
+}
+
+// runScore runs the task's score_command in the project directory and parses
+// its output.
+func (r *Runner) runScore() (float64, error) {
+	ok, output, err := r.executor.RunCapture(r.task.ScoreCommand, r.env.ProjectDir)
+	if err != nil {
+		return 0, fmt.Errorf("score command error: %w", err)
+	}
+	if !ok {
+		return 0, fmt.Errorf("score command failed: %s", strings.TrimSpace(output))
+	}
+	return parseScore(output)
+}
+
+// parseScore reads the score from the last non-empty line of output.
+func parseScore(output string) (float64, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	score, err := strconv.ParseFloat(last, 64)
+	if err != nil {
+		return 0, fmt.Errorf("score command printed %q, want a number on the last line", last)
+	}
+	return score, nil
+}
+
+// formatScore formats a score without trailing zeros.
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func optionalScore(score *float64) string {
+	if score == nil {
+		return ""
+	}
+	return formatScore(*score)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScoreAccepted(t *testing.T) {
+	tests := []struct {
+		rule          string
+		before, after float64
+		want          bool
+	}{
+		{AcceptDecreased, 10, 9, true},
+		{AcceptDecreased, 10, 10, false},
+		{AcceptDecreased, 10, 11, false},
+		{AcceptIncreased, 10, 11, true},
+		{AcceptIncreased, 10, 10, false},
+		{AcceptIncreased, 10, 9, false},
+		{AcceptUnchangedOrBetter, 10, 9, true},
+		{AcceptUnchangedOrBetter, 10, 10, true},
+		{AcceptUnchangedOrBetter, 10, 11, false},
+		{"bogus", 10, 9, false},
+	}
+
+	for _, tt := range tests {
+		if got := scoreAccepted(tt.rule, tt.before, tt.after); got != tt.want {
+			t.Errorf("scoreAccepted(%q, %v, %v) = %v, want %v", tt.rule, tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestRunIterationAcceptsDecreasedScore(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`echo 7 > score`,
+		Task{
+			CandidateSource: `echo '["c1"]'`, // Never disappears; only the score matters
+			ScoreCommand:    "echo measuring; cat score",
+			AcceptIf:        AcceptDecreased,
+		},
+		Config{
+			ResetCommand:   "touch reset",
+			SuccessCommand: "echo $SCORE_BEFORE:$SCORE_AFTER > committed",
+		},
+	)
+	if err := os.WriteFile(filepath.Join(dir, "score"), []byte("10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	committed, err := os.ReadFile(filepath.Join(dir, "committed"))
+	if err != nil {
+		t.Fatal("expected success command to run for a decreased score")
+	}
+	if strings.TrimSpace(string(committed)) != "10:7" {
+		t.Errorf("success command saw scores %q, want 10:7", committed)
+	}
+	log, _ := os.ReadFile(AgentLogPath(runner.task.Dir))
+	if !strings.Contains(string(log), "score 10 -> 7") {
+		t.Errorf("agent log does not record the scores:\n%s", log)
+	}
+}
+
+func TestRunIterationRejectsWorseScore(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`echo $(( $(cat score) + 1 )) > score`,
+		Task{
+			CandidateSource: `if [ -f .calls ]; then echo '[]'; else echo '["c1"]'; fi`, // Presence would say fixed
+			ScoreCommand:    "cat score",
+			AcceptIf:        AcceptUnchangedOrBetter,
+			MaxRepairRounds: 1,
+		},
+		Config{
+			ResetCommand:   "touch reset",
+			SuccessCommand: "touch committed",
+		},
+	)
+	if err := os.WriteFile(filepath.Join(dir, "score"), []byte("10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	if _, err := os.Stat(filepath.Join(dir, "committed")); err == nil {
+		t.Error("success command ran for a worse score")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reset")); err != nil {
+		t.Error("expected reset after the score got worse")
+	}
+	repairPrompt, _ := os.ReadFile(filepath.Join(dir, ".prompt-2"))
+	if !strings.Contains(string(repairPrompt), "the score went from 10 to 11; it needs to stay the same or decrease") {
+		t.Errorf("repair prompt = %q, want score explanation", repairPrompt)
+	}
+}
+
+func TestRunIterationScoreRecordsCollateralFixes(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`echo 7 > score`,
+		Task{
+			CandidateSource: `if [ -f score ] && [ "$(cat score)" = 7 ]; then echo '["c1"]'; else echo '["c1","c2"]'; fi`,
+			ScoreCommand:    "cat score",
+			AcceptIf:        AcceptDecreased,
+		},
+		Config{
+			ResetCommand:   "true",
+			SuccessCommand: "printf '%s' $FIXED_CANDIDATES > committed",
+		},
+	)
+	if err := os.WriteFile(filepath.Join(dir, "score"), []byte("10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	committed, err := os.ReadFile(filepath.Join(dir, "committed"))
+	if err != nil {
+		t.Fatal("expected success command to run for a decreased score")
+	}
+	if string(committed) != "c1\nc2" {
+		t.Errorf("$FIXED_CANDIDATES = %q, want c1 and c2 on separate lines", committed)
+	}
+	log, _ := os.ReadFile(runner.agentLogger.Path())
+	if want := "Outcome: COLLATERAL_FIX\nCandidate: c2\nFixed by: c1\n"; !strings.Contains(string(log), want) {
+		t.Errorf("agent log missing %q:\n%s", want, log)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Phase is the step of the candidate pipeline a run was in.
+type Phase string
+
+const (
+	PhaseAgent   Phase = "agent"
+	PhaseVerify  Phase = "verify"
+	PhaseRecheck Phase = "recheck"
+	PhaseCommit  Phase = "commit"
+)
+
+// RunState records the candidate in flight so an interrupted run can be
+// recovered with --resume. It is deleted once the candidate is resolved.
+type RunState struct {
+	RunID     int64           `json:"run_id"`
+	Iteration int             `json:"iteration"`
+	Candidate string          `json:"candidate"`      // Candidate key
+	Data      json.RawMessage `json:"candidate_data"` // Raw candidate JSON, for interpolating the success command
+	StartedAt time.Time       `json:"started_at"`
+	Phase     Phase           `json:"phase"`
+
+	ScoreBefore *float64 `json:"score_before,omitempty"` // accept_if: score before the agent ran
+	Batch       []string `json:"batch,omitempty"`        // batch_size: member keys; Data holds their data in order
+}
+
+// StatePath returns the run state path for a task.
+func StatePath(taskDir string) string {
+	return filepath.Join(taskDir, "state.json")
+}
+
+// LoadRunState reads the run state at path. Returns nil if there is none.
+func LoadRunState(path string) (*RunState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run state: %w", err)
+	}
+
+	var state RunState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse run state %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// SaveRunState writes the run state atomically: it is written to a temporary
+// file first and renamed over the old state, so a crash mid-write never leaves
+// a truncated file behind.
+func SaveRunState(path string, state *RunState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".state-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	return nil
+}
+
+// ClearRunState removes the run state at path, if any.
+func ClearRunState(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove run state: %w", err)
+	}
+	return nil
+}
+
+// candidate rebuilds the candidate the state was recorded for.
+func (s *RunState) candidate() *Candidate {
+	data := s.Data
+	if len(data) == 0 {
+		data = json.RawMessage(`"` + jsonEscape(s.Candidate) + `"`)
+	}
+	if len(s.Batch) > 0 {
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err == nil && len(items) == len(s.Batch) {
+			batch := make([]Candidate, len(items))
+			for i, item := range items {
+				batch[i] = Candidate{Key: s.Batch[i], Data: item}
+			}
+			return batchCandidate(batch)
+		}
+	}
+	return &Candidate{Key: s.Candidate, Data: data}
+}
+
+// beginCandidate records that the runner has started working on candidate.
+func (r *Runner) beginCandidate(candidate *Candidate) {
+	r.state = &RunState{
+		RunID:     r.env.TaskID,
+		Iteration: r.iteration,
+		Candidate: candidate.Key,
+		Data:      candidate.Data,
+		StartedAt: time.Now(),
+		Phase:     PhaseAgent,
+	}
+	r.stateReset = false
+	for _, member := range candidate.batch {
+		r.state.Batch = append(r.state.Batch, member.Key)
+	}
+	r.saveState()
+}
+
+// setPhase records the pipeline phase of the candidate in flight.
+func (r *Runner) setPhase(phase Phase) {
+	if r.state == nil || r.state.Phase == phase {
+		return
+	}
+	r.state.Phase = phase
+	r.saveState()
+}
+
+// finishCandidate forgets the candidate in flight once it has been resolved.
+func (r *Runner) finishCandidate() {
+	if r.state == nil {
+		return
+	}
+	r.state = nil
+	if err := ClearRunState(r.statePath); err != nil {
+		fmt.Fprintln(r.console(), ColorWarning(err.Error()))
+	}
+}
+
+func (r *Runner) saveState() {
+	// Losing the state file only matters after a crash, so don't stop the run
+	if err := SaveRunState(r.statePath, r.state); err != nil {
+		fmt.Fprintln(r.console(), ColorWarning(err.Error()))
+	}
+}
+
+// recoverInterruptedRun checks for a candidate left in flight by a previous
+// run. With --resume the user can verify and commit the leftover changes, or
+// reset them; otherwise the startup reset discards them as before.
+func (r *Runner) recoverInterruptedRun() error {
+	if r.opts.DryRun {
+		return nil
+	}
+	state, err := LoadRunState(r.statePath)
+	if err != nil || state == nil {
+		return err
+	}
+
+	fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("Previous run was interrupted in the %s phase of candidate %s (iteration %d, started %s)",
+		state.Phase, state.Candidate, state.Iteration, state.StartedAt.Format("2006-01-02 15:04:05"))))
+
+	if !r.opts.Resume {
+		fmt.Fprintln(r.console(), ColorWarning("Its changes will be reset. Run with --resume to verify and commit them instead."))
+		return ClearRunState(r.statePath)
+	}
+
+	hasChanges, err := r.executor.HasUncommittedChanges(r.env.ProjectDir)
+	if err != nil {
+		return fmt.Errorf("failed to check for changes: %w", err)
+	}
+	if !hasChanges {
+		fmt.Fprintln(r.console(), ColorInfo("No leftover changes to recover."))
+		return ClearRunState(r.statePath)
+	}
+
+	commit, err := r.askResume()
+	if err != nil {
+		return err
+	}
+	if !commit {
+		if !r.runResetAndVerify() {
+			return &fatalError{msg: "failed to reset leftover changes"}
+		}
+		return ClearRunState(r.statePath)
+	}
+
+	candidate := state.candidate()
+	r.state = state
+	if r.agentLogger != nil {
+		r.agentLogger.StartEntry("none", "(resumed after interruption) "+candidate.Key)
+		r.agentLogger.EndEntry()
+	}
+
+	if _, err := r.resolveLeftoverChanges(candidate); err != nil {
+		return err
+	}
+	r.finishCandidate()
+	return nil
+}
+
+// resolveLeftoverChanges runs the usual verify, re-check and commit steps on
+// changes recovered from an interrupted run.
+func (r *Runner) resolveLeftoverChanges(candidate *Candidate) (bool, error) {
+	r.setPhase(PhaseVerify)
+	if ok, _ := r.runVerifyCapture(); !ok {
+		fmt.Fprintln(r.console(), ColorWarning("Build failed with the leftover changes"))
+		return r.handleFailure(candidate)
+	}
+
+	r.setPhase(PhaseRecheck)
+	r.scoreBefore = r.state.ScoreBefore
+	fixed, _, err := r.checkFixed(candidate, nil)
+	if err != nil {
+		return false, err
+	}
+	r.reportCollateral()
+	if fixed {
+		violations, err := r.guardrailViolations(candidate)
+		if err != nil {
+			return false, err
+		}
+		if len(violations) > 0 {
+			return r.handleGuardrailViolation(candidate, violations)
+		}
+		return r.handleSuccess(candidate, true)
+	}
+	return r.handleFailure(candidate)
+}
+
+// askResume asks whether to commit (true) or reset (false) leftover changes.
+func (r *Runner) askResume() (bool, error) {
+	input := r.input
+	if input == nil {
+		input = os.Stdin
+	}
+	reader := bufio.NewReader(input)
+
+	for {
+		fmt.Fprint(r.console(), "Verify and commit the leftover changes, or reset them? [c]ommit/[r]eset: ")
+		line, err := reader.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "c", "commit":
+			return true, nil
+		case "r", "reset":
+			return false, nil
+		}
+		if err != nil {
+			fmt.Fprintln(r.console())
+			return false, &fatalError{msg: "no answer given; leftover changes were left in place"}
+		}
+	}
+}
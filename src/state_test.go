@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveRunStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	want := &RunState{
+		RunID:     42,
+		Iteration: 3,
+		Candidate: "c1",
+		Data:      json.RawMessage(`["c1","x"]`),
+		StartedAt: time.Unix(1000, 0).UTC(),
+		Phase:     PhaseVerify,
+	}
+
+	if err := SaveRunState(path, want); err != nil {
+		t.Fatalf("SaveRunState failed: %v", err)
+	}
+	got, err := LoadRunState(path)
+	if err != nil {
+		t.Fatalf("LoadRunState failed: %v", err)
+	}
+	if got.RunID != want.RunID || got.Iteration != want.Iteration || got.Candidate != want.Candidate ||
+		string(got.Data) != string(want.Data) || !got.StartedAt.Equal(want.StartedAt) || got.Phase != want.Phase {
+		t.Fatalf("LoadRunState() = %+v, want %+v", got, want)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only state.json after save, found %d files", len(entries))
+	}
+}
+
+func TestLoadRunStateMissingFile(t *testing.T) {
+	state, err := LoadRunState(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil || state != nil {
+		t.Fatalf("LoadRunState() = %v, %v; want nil, nil", state, err)
+	}
+}
+
+func TestClearRunState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := SaveRunState(path, &RunState{Candidate: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClearRunState(path); err != nil {
+		t.Fatalf("ClearRunState failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected state file to be removed")
+	}
+	if err := ClearRunState(path); err != nil {
+		t.Fatalf("ClearRunState on missing file failed: %v", err)
+	}
+}
+
+func TestRunStateCandidateFallsBackToKey(t *testing.T) {
+	c := (&RunState{Candidate: `say "hi"`}).candidate()
+	if c.Key != `say "hi"` || string(c.Data) != `"say \"hi\""` {
+		t.Fatalf("candidate() = %+v", c)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// How agent tool calls are shown while streaming (--tool-output)
+const (
+	ToolOutputHide    = "hide"    // Don't show tool calls
+	ToolOutputSummary = "summary" // One line per tool call (default)
+	ToolOutputFull    = "full"    // One line per tool call plus its output
+)
+
+// validateToolOutput checks a tool_output / --tool-output value.
+func validateToolOutput(mode string) error {
+	switch mode {
+	case "", ToolOutputHide, ToolOutputSummary, ToolOutputFull:
+		return nil
+	}
+	return fmt.Errorf("%q is not hide, summary or full", mode)
+}
+
+// toolOutput returns how tool calls are shown: --tool-output > config.yaml >
+// summary.
+func (r *Runner) toolOutput() string {
+	switch {
+	case r.opts.ToolOutput != "":
+		return r.opts.ToolOutput
+	case r.env.Config.ToolOutput != "":
+		return r.env.Config.ToolOutput
+	}
+	return ToolOutputSummary
+}
+
+// ToolEvent is a finished agent tool call, such as a shell command or a file
+// edit, reported by a backend.
+type ToolEvent struct {
+	Name    string // Tool name, e.g. Bash or Edit
+	Command string // Shell command, for command tools
+	Target  string // File path, pattern or other main argument
+	Failed  bool   // Whether the tool reported an error
+	Exit    int    // Exit code of a failed command, 0 when unknown
+	Output  string // Tool result, shown with --tool-output full
+}
+
+// maxToolSummary is the longest command or target shown in a tool summary.
+const maxToolSummary = 100
+
+// Summary describes the tool call in one line, e.g. "▶ Edit src/foo.c" or
+// "▶ Bash: make -j8 (exit 2)". Paths under workDir are shown relative to it.
+func (t ToolEvent) Summary(workDir string) string {
+	line := "▶ " + t.Name
+	switch {
+	case t.Command != "":
+		line += ": " + truncateSummary(t.Command)
+	case t.Target != "":
+		target := t.Target
+		if workDir != "" && filepath.IsAbs(target) {
+			if rel, err := filepath.Rel(workDir, target); err == nil && !strings.HasPrefix(rel, "..") {
+				target = rel
+			}
+		}
+		line += " " + truncateSummary(target)
+	}
+
+	switch {
+	case t.Exit != 0:
+		line += fmt.Sprintf(" (exit %d)", t.Exit)
+	case t.Failed:
+		line += " (failed)"
+	}
+	return line
+}
+
+// truncateSummary keeps the first line of s, shortened to maxToolSummary.
+func truncateSummary(s string) string {
+	s = strings.TrimSpace(s)
+	first, rest, multiline := strings.Cut(s, "\n")
+	if len(first) > maxToolSummary {
+		return first[:maxToolSummary] + "…"
+	}
+	if multiline && strings.TrimSpace(rest) != "" {
+		return first + " …"
+	}
+	return first
+}
+
+// claudeToolArgs are the tool input fields shown as a summary's target, most
+// telling first.
+var claudeToolArgs = []string{"file_path", "notebook_path", "pattern", "path", "url", "query", "description"}
+
+// claudeToolEvent describes a Claude tool_use block.
+func claudeToolEvent(name string, input map[string]interface{}) ToolEvent {
+	tool := ToolEvent{Name: name}
+	if command, ok := input["command"].(string); ok {
+		tool.Command = command
+		return tool
+	}
+	for _, arg := range claudeToolArgs {
+		if value, ok := input[arg].(string); ok && value != "" {
+			tool.Target = value
+			break
+		}
+	}
+	return tool
+}
+
+// claudeExitRe matches the exit status Claude reports for failed commands.
+var claudeExitRe = regexp.MustCompile(`^Exit code (\d+)`)
+
+// finishClaudeTool fills in a tool call's result from its tool_result block.
+func finishClaudeTool(tool ToolEvent, isError bool, output string) ToolEvent {
+	tool.Failed = isError
+	tool.Output = output
+	if m := claudeExitRe.FindStringSubmatch(output); m != nil {
+		tool.Exit, _ = strconv.Atoi(m[1])
+		tool.Failed = tool.Failed || tool.Exit != 0
+	}
+	return tool
+}
+
+// unwrapShell strips the `bash -lc '...'` wrapper Codex puts around commands.
+func unwrapShell(command string) string {
+	for _, shell := range []string{"bash -lc ", "/bin/bash -lc ", "sh -c ", "/bin/sh -c "} {
+		rest, ok := strings.CutPrefix(command, shell)
+		if !ok {
+			continue
+		}
+		if len(rest) >= 2 && rest[0] == '\'' && rest[len(rest)-1] == '\'' && !strings.Contains(rest[1:len(rest)-1], "'") {
+			return rest[1 : len(rest)-1]
+		}
+		return rest
+	}
+	return command
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestToolEventSummary(t *testing.T) {
+	tests := []struct {
+		name string
+		tool ToolEvent
+		want string
+	}{
+		{"edit relative to workdir", ToolEvent{Name: "Edit", Target: "/repo/src/foo.c"}, "▶ Edit src/foo.c"},
+		{"path outside workdir", ToolEvent{Name: "Read", Target: "/etc/hosts"}, "▶ Read /etc/hosts"},
+		{"failed command", ToolEvent{Name: "Bash", Command: "make -j8", Failed: true, Exit: 2}, "▶ Bash: make -j8 (exit 2)"},
+		{"failed without exit code", ToolEvent{Name: "Edit", Target: "a.go", Failed: true}, "▶ Edit a.go (failed)"},
+		{"multi-line command", ToolEvent{Name: "Bash", Command: "cd src\nmake"}, "▶ Bash: cd src …"},
+		{"no arguments", ToolEvent{Name: "TodoWrite"}, "▶ TodoWrite"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tool.Summary("/repo"); got != tt.want {
+				t.Errorf("Summary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnwrapShell(t *testing.T) {
+	tests := map[string]string{
+		"bash -lc 'make -j8'":             "make -j8",
+		"bash -lc make":                   "make",
+		`bash -lc 'echo '"'"'x'"'"` + "'": `'echo '"'"'x'"'"'`,
+		"go test ./...":                   "go test ./...",
+	}
+	for command, want := range tests {
+		if got := unwrapShell(command); got != want {
+			t.Errorf("unwrapShell(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
+
+func TestRunAgentShowsToolCalls(t *testing.T) {
+	script := `echo '{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"make"}}]}}'
+echo '{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"Exit code 1\nno rule"}]}}'`
+
+	tests := []struct {
+		mode     string
+		want     []string
+		unwanted []string
+	}{
+		{ToolOutputHide, nil, []string{"▶ Bash"}},
+		{ToolOutputSummary, []string{"▶ Bash: make (exit 1)"}, []string{"  no rule"}},
+		{ToolOutputFull, []string{"▶ Bash: make (exit 1)", "  Exit code 1\n  no rule"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			runner, _ := newScriptedRunner(t, script, Task{CandidateSource: `echo '[]'`}, Config{})
+			runner.opts.ToolOutput = tt.mode
+
+			stdout := captureStdout(t, func() {
+				if _, err := runner.runAgent(runner.env.Config.Agent, "", "prompt", "", 0, nil); err != nil {
+					t.Fatalf("runAgent failed: %v", err)
+				}
+			})
+			for _, want := range tt.want {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("stdout missing %q:\n%s", want, stdout)
+				}
+			}
+			for _, unwanted := range tt.unwanted {
+				if strings.Contains(stdout, unwanted) {
+					t.Errorf("stdout contains %q:\n%s", unwanted, stdout)
+				}
+			}
+
+			// The agent log always gets the summary
+			log, err := os.ReadFile(runner.agentLogger.Path())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(log), "▶ Bash: make (exit 1)\n") {
+				t.Errorf("agent log missing tool summary:\n%s", log)
+			}
+		})
+	}
+}
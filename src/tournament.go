@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Selection strategies for picking the winner of a best-of-N tournament.
+const (
+	SelectionSmallestDiff = "smallest_diff" // Fewest changed lines wins (default)
+	SelectionScore        = "score"         // Best score_command output wins (lowest unless accept_if: increased)
+)
+
+// attempt is one agent run of a best-of-N tournament.
+type attempt struct {
+	n           int
+	passed      bool   // Verify passed and the candidate is gone
+	result      string // Short description for the console and the archive
+	patch       []byte
+	diffLines   int
+	score       float64
+	hasScore    bool
+	unfixed     []Candidate // Batch members the attempt did not fix
+	collateral  []string    // Other candidates the attempt's changes fixed
+	regressions []string    // New candidates the attempt's changes introduced
+}
+
+// runTournament runs the prompt attempts_per_candidate times, each in its own
+// temporary worktree, and applies the best passing attempt to the project.
+// Losing patches are archived in the task directory.
+func (r *Runner) runTournament(candidate *Candidate, agent *agentChoice, prompt string, timeout time.Duration) (bool, error) {
+	root, err := gitOutput(r.env.ProjectDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return false, fmt.Errorf("attempts_per_candidate requires a git repository: %w", err)
+	}
+	prefix, err := gitOutput(r.env.ProjectDir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return false, err
+	}
+
+	total := r.task.AttemptsPerCandidate
+	attempts := make([]*attempt, 0, total)
+	for n := 1; n <= total; n++ {
+		label := fmt.Sprintf("Attempt %d/%d", n, total)
+		fmt.Fprintln(r.console(), ColorInfo(label+"..."))
+		if r.agentLogger != nil {
+			r.agentLogger.StartSubEntry(label, prompt)
+		}
+
+		a, err := r.runAttempt(n, candidate, agent, prompt, timeout, root, prefix)
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintln(r.console(), ColorInfo(fmt.Sprintf("%s: %s", label, a.result)))
+		attempts = append(attempts, a)
+	}
+
+	winner := selectWinner(attempts, r.task.Selection, r.task.AcceptIf == AcceptIncreased)
+
+	archiveDir, err := r.archiveAttempts(candidate, attempts, winner)
+	if err != nil {
+		return false, err
+	}
+	if archiveDir != "" {
+		fmt.Fprintf(r.console(), ColorInfo("Archived losing attempts in %s\n"), displayPath(archiveDir))
+	}
+
+	if winner == nil {
+		r.scoreAfter = nil
+		r.batchUnfixed = nil
+		r.collateral, r.regressions = nil, nil
+		fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("None of the %d attempts fixed the candidate", total)))
+		return r.handleFailure(candidate)
+	}
+
+	fmt.Fprintln(r.console(), ColorSuccess(fmt.Sprintf("Attempt %d wins (%s)", winner.n, winner.result)))
+	r.scoreAfter = nil
+	if winner.hasScore {
+		r.scoreAfter = &winner.score
+	}
+	r.batchUnfixed = winner.unfixed
+	r.collateral, r.regressions = winner.collateral, winner.regressions
+	r.reportCollateral()
+	if err := gitApply(root, winner.patch); err != nil {
+		fmt.Fprintln(r.console(), ColorError(fmt.Sprintf("Failed to apply winning patch: %v", err)))
+		return r.handleFailure(candidate)
+	}
+	r.setPhase(PhaseVerify)
+	return r.handleSuccess(candidate, false)
+}
+
+// runAttempt runs one agent session in a fresh worktree and collects its
+// patch. Only rate limits and fatal agent failures abort the tournament; other
+// agent errors just fail the attempt.
+func (r *Runner) runAttempt(n int, candidate *Candidate, agent *agentChoice, prompt string, timeout time.Duration, root, prefix string) (*attempt, error) {
+	a := &attempt{n: n}
+
+	dir, err := os.MkdirTemp("", "nigel-attempt-")
+	if err != nil {
+		return nil, err
+	}
+	worktree := filepath.Join(dir, filepath.Base(root))
+	defer func() {
+		gitOutput(root, "worktree", "remove", "--force", worktree)
+		os.RemoveAll(dir)
+	}()
+	if _, err := gitOutput(root, "worktree", "add", "--detach", worktree, "HEAD"); err != nil {
+		return nil, fmt.Errorf("failed to create attempt worktree: %w", err)
+	}
+
+	// Point the runner at the worktree so the agent, verify and re-check run there
+	projectEnv := r.env
+	attemptEnv := *r.env
+	attemptEnv.ProjectDir = filepath.Join(worktree, prefix)
+	r.env = &attemptEnv
+	defer func() { r.env = projectEnv }()
+
+	r.collateral, r.regressions = nil, nil
+	r.setPhase(PhaseAgent)
+	extraEnv := r.candidateEnv(timeout)
+	sessionID, err := r.runAgent(agent.Agent, agent.Flags, prompt, "", timeout, extraEnv)
+	err = r.continueOnTimeout(agent.Agent, agent.Flags, sessionID, err)
+	if _, isRateLimit := err.(*rateLimitError); isRateLimit {
+		return nil, err
+	}
+	if agentErr, ok := err.(*agentError); ok && agentErr.failure.Kind == FailureFatal {
+		return nil, &fatalError{msg: fmt.Sprintf("%s failed in a way retrying won't fix: %s", strings.ToLower(r.backend.DisplayName()), agentErr.failure.Reason)}
+	}
+
+	switch {
+	case err != nil:
+		a.result = fmt.Sprintf("%s failed: %v", r.backend.DisplayName(), err)
+	default:
+		r.setPhase(PhaseVerify)
+		if ok, _ := r.runVerifyCapture(); !ok {
+			a.result = "build failed"
+			break
+		}
+		r.setPhase(PhaseRecheck)
+		r.scoreAfter = nil
+		fixed, _, err := r.checkFixed(candidate, extraEnv)
+		if err != nil {
+			return nil, err
+		}
+		a.collateral, a.regressions = r.collateral, r.regressions
+		if !fixed {
+			a.result = "candidate still present"
+			if r.task.AcceptIf != "" {
+				a.result = "score not accepted"
+			}
+			if len(r.regressions) > 0 {
+				a.result = fmt.Sprintf("introduced %d new candidates", len(r.regressions))
+			}
+			break
+		}
+		violations, err := r.guardrailViolations(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if len(violations) > 0 {
+			a.result = "guardrail violation: " + strings.Join(violations, "; ")
+			break
+		}
+		a.passed = true
+		a.result = "fixed"
+		a.unfixed = r.batchUnfixed
+	}
+
+	a.patch, a.diffLines, err = worktreePatch(worktree)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.passed {
+		a.result = fmt.Sprintf("fixed, %d changed lines", a.diffLines)
+		if r.scoreAfter != nil {
+			// Already measured for accept_if
+			a.score, a.hasScore = *r.scoreAfter, true
+			a.result += fmt.Sprintf(", score %s", formatScore(a.score))
+		} else if r.task.ScoreCommand != "" {
+			score, err := r.runScore()
+			if err != nil {
+				a.passed = false
+				a.result = err.Error()
+			} else {
+				a.score, a.hasScore = score, true
+				a.result += fmt.Sprintf(", score %s", formatScore(score))
+			}
+		}
+	}
+	return a, nil
+}
+
+// selectWinner picks the best passing attempt: best score first when
+// selecting by score (lowest, unless higherIsBetter), then smallest diff, then
+// earliest. Returns nil if no attempt passed.
+func selectWinner(attempts []*attempt, selection string, higherIsBetter bool) *attempt {
+	var winner *attempt
+	for _, a := range attempts {
+		if !a.passed {
+			continue
+		}
+		if winner == nil || betterAttempt(a, winner, selection, higherIsBetter) {
+			winner = a
+		}
+	}
+	return winner
+}
+
+func betterAttempt(a, b *attempt, selection string, higherIsBetter bool) bool {
+	if selection == SelectionScore && a.hasScore && b.hasScore && a.score != b.score {
+		return (a.score < b.score) != higherIsBetter
+	}
+	return a.diffLines < b.diffLines
+}
+
+// archiveAttempts saves every attempt except the winner under
+// <task>/attempts/, with a summary of how each one did. Returns the archive
+// directory, or "" if there was nothing to archive.
+func (r *Runner) archiveAttempts(candidate *Candidate, attempts []*attempt, winner *attempt) (string, error) {
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Candidate: %s\n", candidate.Key)
+
+	var losers []*attempt
+	for _, a := range attempts {
+		status := "lost"
+		if a == winner {
+			status = "won"
+		} else if len(a.patch) > 0 {
+			losers = append(losers, a)
+		}
+		fmt.Fprintf(&summary, "Attempt %d: %s (%s)\n", a.n, status, a.result)
+	}
+	if len(losers) == 0 {
+		return "", nil
+	}
+
+	name := fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), r.iteration)
+	if r.opts.Worker > 0 {
+		name += fmt.Sprintf("-worker-%d", r.opts.Worker)
+	}
+	dir := filepath.Join(r.task.Dir, "attempts", name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to archive attempts: %w", err)
+	}
+	for _, a := range losers {
+		path := filepath.Join(dir, fmt.Sprintf("attempt-%d.patch", a.n))
+		if err := os.WriteFile(path, a.patch, 0644); err != nil {
+			return "", fmt.Errorf("failed to archive attempts: %w", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "summary.txt"), []byte(summary.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to archive attempts: %w", err)
+	}
+	return dir, nil
+}
+
+// worktreePatch stages everything in the worktree and returns the binary
+// patch against HEAD along with the number of changed lines.
+func worktreePatch(worktree string) ([]byte, int, error) {
+	if _, err := gitOutput(worktree, "add", "-A"); err != nil {
+		return nil, 0, err
+	}
+	patch, err := gitRaw(worktree, nil, "diff", "--cached", "--binary", "HEAD")
+	if err != nil {
+		return nil, 0, err
+	}
+	numstat, err := gitOutput(worktree, "diff", "--cached", "--numstat", "HEAD")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lines := 0
+	for _, line := range strings.Split(numstat, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// Binary files show "-" and count as one line
+		added, err1 := strconv.Atoi(fields[0])
+		deleted, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			lines++
+			continue
+		}
+		lines += added + deleted
+	}
+	return patch, lines, nil
+}
+
+// gitApply applies patch to the working tree at dir.
+func gitApply(dir string, patch []byte) error {
+	if len(patch) == 0 {
+		return nil
+	}
+	_, err := gitRaw(dir, patch, "apply", "--binary")
+	return err
+}
+
+// gitRaw runs git in dir with optional stdin and returns its untrimmed stdout.
+func gitRaw(dir string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return output, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelectWinner(t *testing.T) {
+	attempts := []*attempt{
+		{n: 1, passed: true, diffLines: 10, score: 1, hasScore: true},
+		{n: 2, passed: true, diffLines: 3, score: 5, hasScore: true},
+		{n: 3, passed: false, diffLines: 1},
+		{n: 4, passed: true, diffLines: 3, score: 5, hasScore: true},
+	}
+
+	if got := selectWinner(attempts, SelectionSmallestDiff, false); got.n != 2 {
+		t.Errorf("smallest_diff winner = attempt %d, want 2 (ties go to the earliest)", got.n)
+	}
+	if got := selectWinner(attempts, SelectionScore, false); got.n != 1 {
+		t.Errorf("score winner = attempt %d, want 1", got.n)
+	}
+	if got := selectWinner(attempts, SelectionScore, true); got.n != 2 {
+		t.Errorf("higher-is-better score winner = attempt %d, want 2", got.n)
+	}
+	if got := selectWinner([]*attempt{{n: 1}}, SelectionSmallestDiff, false); got != nil {
+		t.Errorf("winner = attempt %d, want none when nothing passed", got.n)
+	}
+}
+
+func TestParseScore(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    float64
+		wantErr bool
+	}{
+		{output: "42\n", want: 42},
+		{output: "building...\n 3.5 \n\n", want: 3.5},
+		{output: "-1", want: -1},
+		{output: "no number here", wantErr: true},
+		{output: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseScore(tt.output)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseScore(%q) error = %v, wantErr %v", tt.output, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseScore(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestRunIterationTournamentAppliesSmallestPassingDiff(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`N=$(( $(cat "$(dirname "$0")/.attempts" 2>/dev/null || echo 0) + 1 ))
+echo $N > "$(dirname "$0")/.attempts"
+case $N in
+  1) echo ok > fixed; seq 10 > extra.txt ;;
+  2) echo ok > fixed ;;
+esac`,
+		Task{
+			CandidateSource:      `if [ -f fixed ]; then echo '[]'; else echo '["c1"]'; fi`,
+			AttemptsPerCandidate: 3,
+		},
+		Config{
+			ResetCommand:   "git checkout -q . && git clean -qfd",
+			SuccessCommand: "git add -A && git -c user.name=test -c user.email=test@example.com commit -qm fix",
+		},
+	)
+
+	ignore := ".attempts\n.calls\n.prompt-*\nfake-agent\ntest-task/\n"
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(ignore), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "add", ".gitignore")
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init")
+
+	captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	files, err := gitOutput(dir, "show", "--name-only", "--format=", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files != "fixed" {
+		t.Fatalf("committed files = %q, want only the winning attempt's change", files)
+	}
+
+	archives, _ := filepath.Glob(filepath.Join(runner.task.Dir, "attempts", "*"))
+	if len(archives) != 1 {
+		t.Fatalf("expected one archive directory, got %v", archives)
+	}
+	patch, err := os.ReadFile(filepath.Join(archives[0], "attempt-1.patch"))
+	if err != nil {
+		t.Fatalf("losing attempt was not archived: %v", err)
+	}
+	if !strings.Contains(string(patch), "extra.txt") {
+		t.Errorf("archived patch = %q, want attempt 1's changes", patch)
+	}
+	if _, err := os.Stat(filepath.Join(archives[0], "attempt-2.patch")); err == nil {
+		t.Error("winning attempt should not be archived")
+	}
+	summary, _ := os.ReadFile(filepath.Join(archives[0], "summary.txt"))
+	if !strings.Contains(string(summary), "Attempt 2: won") || !strings.Contains(string(summary), "Attempt 3: lost (candidate still present)") {
+		t.Errorf("summary = %q", summary)
+	}
+
+	worktrees, _ := gitOutput(dir, "worktree", "list")
+	if strings.Count(worktrees, "\n") != 0 {
+		t.Errorf("attempt worktrees were not removed:\n%s", worktrees)
+	}
+}
+
+func TestRunIterationTournamentKeepsWinnersCollateral(t *testing.T) {
+	// Attempt 1 wins and also clears c3; attempt 2 runs last, clears c2 and loses
+	runner, dir := newScriptedRunner(t,
+		`N=$(( $(cat "$(dirname "$0")/.attempts" 2>/dev/null || echo 0) + 1 ))
+echo $N > "$(dirname "$0")/.attempts"
+case $N in
+  1) echo ok > fixed; touch fixed3 ;;
+  2) echo ok > fixed; echo ok > fixed2; seq 10 > extra.txt ;;
+esac`,
+		Task{
+			CandidateSource: `c="\"c1\""; [ -f fixed ] && c=""
+for n in 2 3; do [ -f fixed$n ] || c="$c${c:+,}\"c$n\""; done
+echo "[$c]"`,
+			AttemptsPerCandidate: 2,
+		},
+		Config{
+			ResetCommand:   "git checkout -q . && git clean -qfd",
+			SuccessCommand: "printf '%s' $FIXED_CANDIDATES > committed",
+		},
+	)
+
+	ignore := ".attempts\n.calls\n.prompt-*\nfake-agent\ntest-task/\ncommitted\n"
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(ignore), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "add", ".gitignore")
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init")
+
+	stdout := captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	committed, err := os.ReadFile(filepath.Join(dir, "committed"))
+	if err != nil {
+		t.Fatalf("expected success command to run: %v", err)
+	}
+	if string(committed) != "c1\nc3" {
+		t.Errorf("$FIXED_CANDIDATES = %q, want the winner's c1 and c3", committed)
+	}
+	if n := strings.Count(stdout, "other candidates also disappeared"); n != 1 {
+		t.Errorf("collateral reported %d times, want once for the winner:\n%s", n, stdout)
+	}
+	log, _ := os.ReadFile(runner.agentLogger.Path())
+	if !strings.Contains(string(log), "Candidate: c3\nFixed by: c1\n") || strings.Contains(string(log), "Candidate: c2\n") {
+		t.Errorf("agent log should record only the winner's collateral fix:\n%s", log)
+	}
+}
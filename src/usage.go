@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Usage is the token usage of one or more agent sessions, as reported by the
+// backend. InputTokens excludes cached input, which is counted separately.
+type Usage struct {
+	InputTokens      int64
+	OutputTokens     int64
+	CacheReadTokens  int64
+	CacheWriteTokens int64
+	CostUSD          float64
+	HasCost          bool // Whether any session reported a cost
+}
+
+// Add accumulates u2 into u.
+func (u *Usage) Add(u2 Usage) {
+	u.InputTokens += u2.InputTokens
+	u.OutputTokens += u2.OutputTokens
+	u.CacheReadTokens += u2.CacheReadTokens
+	u.CacheWriteTokens += u2.CacheWriteTokens
+	u.CostUSD += u2.CostUSD
+	u.HasCost = u.HasCost || u2.HasCost
+}
+
+// Tokens returns every token processed: input, output and cache.
+func (u Usage) Tokens() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheWriteTokens
+}
+
+// IsZero reports whether nothing was recorded.
+func (u Usage) IsZero() bool {
+	return u == Usage{}
+}
+
+// String formats the usage for the console and agent log, e.g.
+// "1200 input, 300 output, 5000 cache read tokens, $0.0421".
+func (u Usage) String() string {
+	parts := []string{
+		fmt.Sprintf("%d input", u.InputTokens),
+		fmt.Sprintf("%d output", u.OutputTokens),
+	}
+	if u.CacheReadTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d cache read", u.CacheReadTokens))
+	}
+	if u.CacheWriteTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d cache write", u.CacheWriteTokens))
+	}
+	s := strings.Join(parts, ", ") + " tokens"
+	if u.HasCost {
+		s += fmt.Sprintf(", $%.4f", u.CostUSD)
+	}
+	return s
+}
+
+// Budget caps the usage of a whole run. Zero limits are unlimited. A budget is
+// shared by every worker of a pool.
+type Budget struct {
+	MaxCost   float64
+	MaxTokens int64
+
+	mu    sync.Mutex
+	spent Usage
+}
+
+// Add records usage against the budget.
+func (b *Budget) Add(u Usage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent.Add(u)
+}
+
+// Spent returns the usage recorded so far.
+func (b *Budget) Spent() Usage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
+
+// Exceeded reports whether a limit has been reached, with a description of it.
+func (b *Budget) Exceeded() (string, bool) {
+	spent := b.Spent()
+	if b.MaxCost > 0 && spent.CostUSD >= b.MaxCost {
+		return fmt.Sprintf("cost budget ($%.2f spent of $%.2f)", spent.CostUSD, b.MaxCost), true
+	}
+	if b.MaxTokens > 0 && spent.Tokens() >= b.MaxTokens {
+		return fmt.Sprintf("token budget (%d used of %d)", spent.Tokens(), b.MaxTokens), true
+	}
+	return "", false
+}
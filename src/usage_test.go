@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUsageString(t *testing.T) {
+	tests := []struct {
+		usage Usage
+		want  string
+	}{
+		{Usage{InputTokens: 10, OutputTokens: 20}, "10 input, 20 output tokens"},
+		{Usage{InputTokens: 10, OutputTokens: 20, CacheReadTokens: 300, CacheWriteTokens: 40}, "10 input, 20 output, 300 cache read, 40 cache write tokens"},
+		{Usage{OutputTokens: 5, CostUSD: 0.0421, HasCost: true}, "0 input, 5 output tokens, $0.0421"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.usage.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestBudgetExceeded(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxCost   float64
+		maxTokens int64
+		spent     Usage
+		want      bool
+	}{
+		{"unlimited", 0, 0, Usage{InputTokens: 1e9, CostUSD: 1e3, HasCost: true}, false},
+		{"under cost", 1, 0, Usage{CostUSD: 0.5, HasCost: true}, false},
+		{"at cost", 1, 0, Usage{CostUSD: 1, HasCost: true}, true},
+		{"under tokens", 0, 100, Usage{InputTokens: 50, OutputTokens: 49}, false},
+		{"cache counts towards tokens", 0, 100, Usage{InputTokens: 50, CacheReadTokens: 50}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := &Budget{MaxCost: tt.maxCost, MaxTokens: tt.maxTokens}
+			budget.Add(tt.spent)
+			if _, got := budget.Exceeded(); got != tt.want {
+				t.Errorf("Exceeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopStopsAtCostBudget(t *testing.T) {
+	runner, dir := newScriptedRunner(t,
+		`echo '{"type":"result","total_cost_usd":0.6,"usage":{"input_tokens":100,"output_tokens":10}}'`,
+		Task{
+			CandidateSource: `echo '["c1", "c2", "c3", "c4"]'`,
+		},
+		Config{ResetCommand: "true"},
+	)
+	runner.budget.MaxCost = 1
+
+	output := captureStdout(t, func() {
+		if err := runner.loop(); err != nil {
+			t.Fatalf("loop failed: %v", err)
+		}
+	})
+
+	calls, _ := os.ReadFile(filepath.Join(dir, ".calls"))
+	if strings.TrimSpace(string(calls)) != "2" {
+		t.Errorf("agent ran %s times, want 2", strings.TrimSpace(string(calls)))
+	}
+	if !strings.Contains(output, "Reached cost budget ($1.20 spent of $1.00)") {
+		t.Errorf("output does not report the budget stop:\n%s", output)
+	}
+	if !strings.Contains(output, "Usage: 100 input, 10 output tokens, $0.6000") {
+		t.Errorf("output does not show per-candidate usage:\n%s", output)
+	}
+	log, _ := os.ReadFile(AgentLogPath(runner.task.Dir))
+	if !strings.Contains(string(log), "Usage: 100 input, 10 output tokens, $0.6000") {
+		t.Errorf("agent log does not record usage:\n%s", log)
+	}
+}
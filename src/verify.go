@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// VerifyStage is one step of a staged build check. Stages run in order and
+// later stages only run once the earlier ones pass, so a quick check can
+// reject broken changes before a slow full build.
+type VerifyStage struct {
+	Name    string        `yaml:"name"`
+	Command string        `yaml:"command"` // Supports $CANDIDATE, $TASK_NAME and $INPUT
+	Timeout time.Duration `yaml:"timeout"` // Kill the stage after this long (0 = no limit)
+}
+
+// validateVerify checks a verify section from config.yaml or task.yaml.
+func validateVerify(stages []VerifyStage, verifyCommand string) error {
+	if len(stages) > 0 && verifyCommand != "" {
+		return fmt.Errorf("cannot have both 'verify' and 'verify_command'")
+	}
+	for i, stage := range stages {
+		if strings.TrimSpace(stage.Command) == "" {
+			return fmt.Errorf("verify stage %d is missing 'command'", i+1)
+		}
+		if stage.Timeout < 0 {
+			return fmt.Errorf("verify stage %d 'timeout' cannot be negative", i+1)
+		}
+	}
+	return nil
+}
+
+// verifyStages returns the verify stages for this run: task-level verify or
+// verify_command > global verify or verify_command.
+func (r *Runner) verifyStages() []VerifyStage {
+	switch {
+	case len(r.task.Verify) > 0:
+		return r.task.Verify
+	case r.task.VerifyCommand != "":
+		return []VerifyStage{{Command: r.task.VerifyCommand}}
+	case len(r.env.Config.Verify) > 0:
+		return r.env.Config.Verify
+	case r.env.Config.VerifyCommand != "":
+		return []VerifyStage{{Command: r.env.Config.VerifyCommand}}
+	}
+	return nil
+}
+
+// usesCandidate reports whether a verify command needs a candidate in flight.
+func usesCandidate(command string) bool {
+	return strings.Contains(command, "$CANDIDATE") || strings.Contains(command, "$INPUT")
+}
+
+// verifyCommand interpolates a stage's command for the candidate in flight.
+func (r *Runner) verifyCommand(command string) (string, error) {
+	if r.state == nil {
+		return strings.ReplaceAll(command, "$TASK_NAME", r.task.Name), nil
+	}
+	candidate := r.state.candidate()
+	return InterpolateCandidateCommand(command, candidate, r.task.Name, r.env.TaskID)
+}
+
+// runVerifyStages runs the verify stages in order, stopping at the first that
+// fails, and returns the failing stage's output. Stages scoped to a candidate
+// are skipped when none is in flight. Unless quiet, progress and failure
+// output are shown.
+func (r *Runner) runVerifyStages(quiet bool) (bool, string) {
+	stages := r.verifyStages()
+	named := len(stages) > 1
+	for i, stage := range stages {
+		if r.state == nil && usesCandidate(stage.Command) {
+			continue
+		}
+
+		label := "Verifying build... "
+		if stage.Name != "" || named {
+			name := stage.Name
+			if name == "" {
+				name = fmt.Sprintf("stage %d", i+1)
+			}
+			label = fmt.Sprintf("Verifying build (%s)... ", name)
+		}
+		if !quiet {
+			fmt.Fprint(r.console(), ColorInfo(label))
+		}
+
+		command, err := r.verifyCommand(stage.Command)
+		if err != nil {
+			if !quiet {
+				fmt.Fprintln(r.console(), ColorError(fmt.Sprintf("Verify command error: %v", err)))
+			}
+			return false, err.Error()
+		}
+
+		ok, output, err := r.executor.RunCaptureTimeout(command, r.env.ProjectDir, stage.Timeout)
+		if err != nil {
+			if !quiet {
+				fmt.Fprintln(r.console(), ColorError(fmt.Sprintf("Verify command error: %v", err)))
+			}
+			return false, err.Error()
+		}
+		if !ok {
+			if !quiet {
+				fmt.Fprint(r.console(), output)
+			}
+			return false, output
+		}
+		if !quiet {
+			fmt.Fprintln(r.console(), ColorInfo("OK"))
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newVerifyRunner(t *testing.T, task Task, config Config) (*Runner, *MockCommandExecutor) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	task.Name = "test-task"
+	task.Dir = tmpDir
+	task.Prompt = "fix $INPUT"
+	env := &Environment{
+		ProjectDir: tmpDir,
+		Config:     config,
+		Tasks:      map[string]Task{"test-task": task},
+	}
+
+	runner, err := NewRunner(env, "test-task", RunnerOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("NewRunner failed: %v", err)
+	}
+	mock := NewMockCommandExecutor()
+	runner.setExecutor(mock)
+	return runner, mock
+}
+
+func TestVerifyStagesPrecedence(t *testing.T) {
+	global := []VerifyStage{{Name: "global", Command: "make"}}
+	local := []VerifyStage{{Name: "local", Command: "make test"}}
+
+	tests := []struct {
+		name   string
+		task   Task
+		config Config
+		want   []string
+	}{
+		{"none", Task{}, Config{}, nil},
+		{"global verify_command", Task{}, Config{VerifyCommand: "make"}, []string{"make"}},
+		{"global verify", Task{}, Config{Verify: global}, []string{"make"}},
+		{"task verify_command wins", Task{VerifyCommand: "go vet"}, Config{Verify: global}, []string{"go vet"}},
+		{"task verify wins", Task{Verify: local}, Config{VerifyCommand: "make"}, []string{"make test"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, _ := newVerifyRunner(t, tt.task, tt.config)
+			var got []string
+			for _, stage := range runner.verifyStages() {
+				got = append(got, stage.Command)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("verifyStages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		stages  []VerifyStage
+		command string
+		wantErr bool
+	}{
+		{"empty", nil, "", false},
+		{"command only", nil, "make", false},
+		{"stages", []VerifyStage{{Name: "quick", Command: "make vet", Timeout: time.Minute}}, "", false},
+		{"both", []VerifyStage{{Command: "make vet"}}, "make", true},
+		{"missing command", []VerifyStage{{Name: "quick"}}, "", true},
+		{"negative timeout", []VerifyStage{{Command: "make", Timeout: -time.Second}}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateVerify(tt.stages, tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateVerify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunVerifyStagesStopsAtFirstFailure(t *testing.T) {
+	runner, mock := newVerifyRunner(t, Task{}, Config{Verify: []VerifyStage{
+		{Name: "quick", Command: "make vet"},
+		{Name: "lint", Command: "make lint"},
+		{Name: "full", Command: "make test"},
+	}})
+	mock.SetResult("make lint", false, nil)
+	mock.SetOutput("make lint", "lint says no\n")
+
+	var ok bool
+	var output string
+	stdout := captureStdout(t, func() { ok, output = runner.runVerifyCapture() })
+
+	if ok {
+		t.Fatal("runVerifyCapture() = true, want false when a stage fails")
+	}
+	if output != "lint says no\n" {
+		t.Errorf("output = %q, want the failing stage's output", output)
+	}
+	if !mock.CalledWith("make vet") || !mock.CalledWith("make lint") {
+		t.Errorf("expected quick and lint stages to run, calls: %v", mock.Calls)
+	}
+	if mock.CalledWith("make test") {
+		t.Error("full stage ran after lint failed")
+	}
+	for _, want := range []string{"Verifying build (quick)... ", "Verifying build (lint)... "} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout missing %q:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestRunVerifyStagesScopedToCandidate(t *testing.T) {
+	runner, mock := newVerifyRunner(t, Task{Verify: []VerifyStage{
+		{Name: "package", Command: `go test ./$(dirname $INPUT["file"])`},
+		{Name: "candidate", Command: "check $CANDIDATE"},
+		{Name: "full", Command: "go test ./..."},
+	}}, Config{})
+
+	// Before any candidate is in flight, candidate-scoped stages are skipped
+	captureStdout(t, func() {
+		if ok, _ := runner.runVerifyStages(true); !ok {
+			t.Fatal("runVerifyStages() = false, want true")
+		}
+	})
+	if len(mock.Calls) != 1 || mock.Calls[0].Command != "go test ./..." {
+		t.Fatalf("calls without a candidate = %v, want only the full stage", mock.Calls)
+	}
+
+	mock.Calls = nil
+	runner.beginCandidate(&Candidate{Key: "pkg/a.go", Data: json.RawMessage(`{"file":"pkg/a.go"}`)})
+	captureStdout(t, func() {
+		if ok, _ := runner.runVerifyStages(true); !ok {
+			t.Fatal("runVerifyStages() = false, want true")
+		}
+	})
+
+	want := []string{"go test ./$(dirname 'pkg/a.go')", "check 'pkg/a.go'", "go test ./..."}
+	if len(mock.Calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", mock.Calls, want)
+	}
+	for i, call := range mock.Calls {
+		if call.Command != want[i] {
+			t.Errorf("call %d = %q, want %q", i, call.Command, want[i])
+		}
+	}
+}
+
+func TestRunIterationVerifyStageTimesOut(t *testing.T) {
+	runner, dir := newScriptedRunner(t, "touch fixed",
+		Task{
+			CandidateSource: `if [ -f fixed ]; then echo '[]'; else echo '["c1"]'; fi`,
+			Verify: []VerifyStage{
+				{Name: "quick", Command: "touch quick-ran"},
+				{Name: "full", Command: "if [ -f fixed ]; then sleep 10; fi", Timeout: 200 * time.Millisecond},
+			},
+		},
+		Config{
+			ResetCommand:   "rm -f fixed",
+			SuccessCommand: "touch committed",
+		},
+	)
+
+	stdout := captureStdout(t, func() {
+		if _, err := runner.runIteration(); err != nil {
+			t.Fatalf("runIteration failed: %v", err)
+		}
+	})
+
+	if _, err := os.Stat(filepath.Join(dir, "quick-ran")); err != nil {
+		t.Error("expected the quick stage to run")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "committed")); err == nil {
+		t.Error("changes committed although the full stage timed out")
+	}
+	if !strings.Contains(stdout, "Timed out after 200ms") {
+		t.Errorf("stdout missing timeout note:\n%s", stdout)
+	}
+}
+
+func TestRunVerifyStagesQuotesCandidateInput(t *testing.T) {
+	runner, _ := newVerifyRunner(t, Task{VerifyCommand: `test -f $INPUT["file"]`}, Config{})
+	runner.setExecutor(&RealCommandExecutor{})
+	if err := os.WriteFile(filepath.Join(runner.env.ProjectDir, "my file.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner.beginCandidate(&Candidate{Key: "my file.go", Data: json.RawMessage(`{"file":"my file.go"}`)})
+	if ok, output := runner.runVerifyStages(true); !ok {
+		t.Errorf("runVerifyStages() = false, want true for a path with a space:\n%s", output)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultPollInterval is how often watch mode re-runs the candidate source.
+const DefaultPollInterval = 5 * time.Minute
+
+// headCheckInterval is how often watch mode checks whether HEAD has moved.
+// Checking is a cheap git call, so it runs far more often than the poll.
+var headCheckInterval = 10 * time.Second
+
+// watchForCandidates blocks until the candidate source reports a candidate
+// that is not ignored. The source is re-run every poll interval, and straight
+// away when a new commit moves HEAD. Returns true if the runner should stop
+// instead: a graceful stop was requested or the time limit was reached.
+func (r *Runner) watchForCandidates(startTime time.Time) bool {
+	poll := r.opts.Poll
+	if poll <= 0 {
+		poll = DefaultPollInterval
+	}
+	fmt.Fprintln(r.console(), ColorInfo(fmt.Sprintf("Watching for new candidates (polling every %s and when HEAD moves)...", poll)))
+
+	head := r.headCommit()
+	check := headCheckInterval
+	if check > poll {
+		check = poll
+	}
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+	nextPoll := time.Now().Add(poll)
+
+	var deadline <-chan time.Time
+	if r.opts.TimeLimit > 0 {
+		deadline = time.After(time.Until(startTime.Add(r.opts.TimeLimit)))
+	}
+
+	for {
+		select {
+		case <-r.stopCh:
+			fmt.Fprintln(r.console(), "Stopped by user request.")
+			return true
+		case <-deadline:
+			fmt.Fprintf(r.console(), "Reached time limit (%s).\n", r.opts.TimeLimit)
+			return true
+		case now := <-ticker.C:
+			if current := r.headCommit(); current != head {
+				fmt.Fprintln(r.console(), ColorInfo(fmt.Sprintf("HEAD moved to %s, checking for candidates...", shortCommit(current))))
+				head = current
+			} else if now.Before(nextPoll) {
+				continue
+			}
+		}
+		nextPoll = time.Now().Add(poll)
+
+		candidates, err := r.loadCandidates()
+		if err != nil {
+			// Keep watching; a stop request is picked up by the next select
+			if !r.stopRequested {
+				fmt.Fprintln(r.console(), ColorWarning(fmt.Sprintf("Warning: %v", err)))
+			}
+			continue
+		}
+		if SelectCandidate(candidates, r.ignoredList) != nil {
+			fmt.Fprintln(r.console(), ColorInfo("New candidates found, resuming."))
+			return false
+		}
+	}
+}
+
+// headCommit returns the commit HEAD points at in the project directory, or ""
+// when it cannot be read. Nothing is fetched, so upstream commits only show up
+// once something else pulls them in.
+func (r *Runner) headCommit() string {
+	head, err := gitOutput(r.env.ProjectDir, "rev-parse", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return head
+}
+
+// shortCommit abbreviates a commit hash for display.
+func shortCommit(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// watchResult runs watchForCandidates in the background and returns its result,
+// failing the test if it does not return in time.
+func watchResult(t *testing.T, runner *Runner, trigger func()) bool {
+	t.Helper()
+	var stop bool
+	captureStdout(t, func() {
+		result := make(chan bool, 1)
+		go func() { result <- runner.watchForCandidates(time.Now()) }()
+		trigger()
+		select {
+		case stop = <-result:
+		case <-time.After(10 * time.Second):
+			t.Fatal("watchForCandidates did not return")
+		}
+	})
+	return stop
+}
+
+func TestWatchForCandidatesPolls(t *testing.T) {
+	runner, dir := newScriptedRunner(t, `true`, Task{
+		CandidateSource: `if [ -f ready ]; then echo '["c1"]'; else echo '[]'; fi`,
+	}, Config{})
+	runner.opts.Poll = 20 * time.Millisecond
+
+	stop := watchResult(t, runner, func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(filepath.Join(dir, "ready"), nil, 0644)
+	})
+	if stop {
+		t.Error("expected watch to resume once a candidate appeared")
+	}
+}
+
+func TestWatchForCandidatesSkipsIgnored(t *testing.T) {
+	runner, _ := newScriptedRunner(t, `true`, Task{
+		CandidateSource: `echo '["c1"]'`,
+	}, Config{})
+	runner.opts.Poll = 20 * time.Millisecond
+	if err := runner.ignoredList.Add("c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := watchResult(t, runner, func() {
+		time.Sleep(100 * time.Millisecond)
+		runner.requestStop()
+	})
+	if !stop {
+		t.Error("expected watch to keep waiting while every candidate is ignored")
+	}
+}
+
+func TestWatchForCandidatesWakesWhenHeadMoves(t *testing.T) {
+	old := headCheckInterval
+	headCheckInterval = 20 * time.Millisecond
+	t.Cleanup(func() { headCheckInterval = old })
+
+	runner, dir := newScriptedRunner(t, `true`, Task{
+		CandidateSource: `cat candidates.json`,
+	}, Config{})
+	runner.opts.Poll = time.Hour
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial")
+	if err := os.WriteFile(filepath.Join(dir, "candidates.json"), []byte(`[]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := watchResult(t, runner, func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(filepath.Join(dir, "candidates.json"), []byte(`["c1"]`), 0644)
+		runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "teammate")
+	})
+	if stop {
+		t.Error("expected watch to resume after HEAD moved")
+	}
+}
+
+func TestWatchForCandidatesStopsAtTimeLimit(t *testing.T) {
+	runner, _ := newScriptedRunner(t, `true`, Task{
+		CandidateSource: `echo '[]'`,
+	}, Config{})
+	runner.opts.Poll = time.Hour
+	runner.opts.TimeLimit = 50 * time.Millisecond
+
+	if !watchResult(t, runner, func() {}) {
+		t.Error("expected watch to stop at the time limit")
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WorkerPool runs several Runner loops in one process. Each worker gets its own
+// git worktree and hash partition of the candidates, and all workers share one
+// ignored list and one terminal.
+type WorkerPool struct {
+	task       Task
+	runners    []*Runner
+	out        *SyncWriter
+	projectDir string
+}
+
+// NewWorkerPool creates (or reuses) a git worktree per worker and a Runner
+// bound to each one. In dry-run mode no worktrees are created and every worker
+// reads from the project directory.
+func NewWorkerPool(env *Environment, taskName string, opts RunnerOptions, count int) (*WorkerPool, error) {
+	task, ok := env.Tasks[taskName]
+	if !ok {
+		return nil, fmt.Errorf("task not found: %s", taskName)
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("worker count must be at least 1")
+	}
+
+	pool := &WorkerPool{
+		task:       task,
+		out:        NewSyncWriter(os.Stdout),
+		projectDir: env.ProjectDir,
+	}
+
+	for i := 1; i <= count; i++ {
+		out := pool.out.Prefixed(workerPrefix(i))
+		workDir := env.ProjectDir
+		if !opts.DryRun {
+			dir, err := prepareWorkerDir(out, env.ProjectDir, taskName, i)
+			if err != nil {
+				return nil, fmt.Errorf("worker %d: %w", i, err)
+			}
+			workDir = dir
+		}
+
+		workerEnv := *env
+		workerEnv.ProjectDir = workDir
+
+		workerOpts := opts
+		workerOpts.Worker = i
+		workerOpts.Partition = HashPartition{WorkerCount: count, WorkerIndex: i - 1}
+
+		runner, err := NewRunner(&workerEnv, taskName, workerOpts)
+		if err != nil {
+			return nil, fmt.Errorf("worker %d: %w", i, err)
+		}
+		if len(pool.runners) > 0 {
+			// Share one ignored list so every worker sees the same history,
+			// and one budget so the limits apply to the run as a whole
+			runner.ignoredList = pool.runners[0].ignoredList
+			runner.budget = pool.runners[0].budget
+		}
+		runner.setOutput(out)
+		pool.runners = append(pool.runners, runner)
+	}
+
+	return pool, nil
+}
+
+// workerPrefix returns the colored line prefix for worker n.
+func workerPrefix(n int) string {
+	color := gradientColors[(n-1)%len(gradientColors)]
+	return color + fmt.Sprintf("[worker %d]", n) + colorReset + " "
+}
+
+// Run starts every worker and waits for all of them to finish. Errors from
+// individual workers don't stop the others; the first one is returned.
+func (p *WorkerPool) Run() error {
+	for _, runner := range p.runners {
+		if err := runner.prepare(); err != nil {
+			return err
+		}
+	}
+
+	handleSignals(p.out, func() {
+		for _, runner := range p.runners {
+			runner.requestStop()
+		}
+	})
+
+	logPath := workerPath(displayPath(AgentLogPath(p.task.Dir)), "*")
+	fmt.Fprint(p.out, StartupBanner(p.task.Name, logPath, p.modeString(), agentChainString(p.runners[0].agents)))
+
+	// Recover one worker at a time so --resume prompts don't interleave
+	for _, runner := range p.runners {
+		if err := runner.recoverInterruptedRun(); err != nil {
+			return err
+		}
+	}
+
+	startTime := time.Now()
+	errs := make([]error, len(p.runners))
+	var wg sync.WaitGroup
+	for i, runner := range p.runners {
+		wg.Add(1)
+		go func(i int, runner *Runner) {
+			defer wg.Done()
+			if err := runner.loop(); err != nil {
+				fmt.Fprintln(runner.console(), ColorError(fmt.Sprintf("Worker stopped: %v", err)))
+				errs[i] = err
+			}
+		}(i, runner)
+	}
+	wg.Wait()
+
+	fmt.Fprintln(p.out, ColorInfo(fmt.Sprintf("All %d workers finished.", len(p.runners))))
+
+	iterations := 0
+	outcomes := make(map[Outcome]int)
+	for _, runner := range p.runners {
+		iterations += runner.iteration
+		for outcome, n := range runner.outcomes {
+			outcomes[outcome] += n
+		}
+	}
+	fmt.Fprintln(p.out, ColorInfo(runSummary(iterations, outcomes)))
+	p.runners[0].runCompleteHook(p.projectDir, time.Since(startTime), iterations)
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("worker %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (p *WorkerPool) modeString() string {
+	return fmt.Sprintf("%s, %d workers", p.runners[0].modeString(), len(p.runners))
+}
+
+// prepareWorkerDir makes sure worker n has an up to date git worktree and
+// returns the directory inside it that corresponds to projectDir.
+func prepareWorkerDir(w io.Writer, projectDir, taskName string, n int) (string, error) {
+	root, err := gitOutput(projectDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("parallel workers require a git repository: %w", err)
+	}
+	prefix, err := gitOutput(projectDir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+
+	path := workerWorktreePath(root, taskName, n)
+	if err := ensureWorktree(w, root, path, workerBranch(taskName, n)); err != nil {
+		return "", err
+	}
+	return filepath.Join(path, prefix), nil
+}
+
+// workerWorktreePath returns the worktree location for worker n. Worktrees are
+// siblings of the repository so build tools in the main tree never see them.
+func workerWorktreePath(repoRoot, taskName string, n int) string {
+	name := fmt.Sprintf("%s-nigel-%s-%d", filepath.Base(repoRoot), taskName, n)
+	return filepath.Join(filepath.Dir(repoRoot), name)
+}
+
+// workerBranch returns the branch checked out in worker n's worktree.
+func workerBranch(taskName string, n int) string {
+	return fmt.Sprintf("nigel/%s/worker-%d", taskName, n)
+}
+
+// ensureWorktree reuses the worktree at path if it exists, otherwise creates it
+// on branch (creating the branch from HEAD if needed). Either way the branch is
+// then brought up to date with HEAD, so workers never fix stale code.
+func ensureWorktree(w io.Writer, repoRoot, path, branch string) error {
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		args := []string{"worktree", "add", path, branch}
+		if _, err := gitOutput(repoRoot, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err != nil {
+			args = []string{"worktree", "add", "-b", branch, path, "HEAD"}
+		}
+		if _, err := gitOutput(repoRoot, args...); err != nil {
+			return fmt.Errorf("failed to create worktree %s: %w", path, err)
+		}
+	}
+	return syncWorktree(w, repoRoot, path, branch)
+}
+
+// syncWorktree moves a reused worker branch onto the repository's HEAD: it is
+// fast-forwarded when it has no commits of its own, and otherwise rebased so
+// its commits stay on top of the user's branch. A worktree with uncommitted
+// changes (work an interrupted run left behind) or a rebase that conflicts is
+// left as it was, with a warning.
+func syncWorktree(w io.Writer, repoRoot, path, branch string) error {
+	head, err := gitOutput(repoRoot, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	tip, err := gitOutput(path, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	if tip == head || isAncestor(path, head, tip) {
+		return nil
+	}
+
+	status, err := gitOutput(path, "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if status != "" {
+		fmt.Fprintln(w, ColorWarning(fmt.Sprintf("Warning: %s is behind HEAD but has uncommitted changes, so it was not updated", branch)))
+		return nil
+	}
+
+	if isAncestor(path, tip, head) {
+		if _, err := gitOutput(path, "merge", "--ff-only", "--quiet", head); err != nil {
+			return fmt.Errorf("failed to fast-forward %s: %w", branch, err)
+		}
+		return nil
+	}
+	if _, err := gitOutput(path, "rebase", "--quiet", head); err != nil {
+		gitOutput(path, "rebase", "--abort")
+		fmt.Fprintln(w, ColorWarning(fmt.Sprintf("Warning: %s is behind HEAD and could not be rebased onto it: %v", branch, err)))
+		return nil
+	}
+	fmt.Fprintln(w, ColorInfo(fmt.Sprintf("Rebased %s onto HEAD", branch)))
+	return nil
+}
+
+// isAncestor reports whether commit ancestor is reachable from commit.
+func isAncestor(dir, ancestor, commit string) bool {
+	_, err := gitOutput(dir, "merge-base", "--is-ancestor", ancestor, commit)
+	return err == nil
+}
+
+// gitOutput runs git in dir and returns its trimmed stdout.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
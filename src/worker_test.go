@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkerWorktreePathIsSiblingOfRepo(t *testing.T) {
+	got := workerWorktreePath("/src/project", "fix-errors", 2)
+	want := "/src/project-nigel-fix-errors-2"
+	if got != want {
+		t.Fatalf("workerWorktreePath() = %q, want %q", got, want)
+	}
+}
+
+func TestEnsureWorktreeCreatesAndReuses(t *testing.T) {
+	parent := t.TempDir()
+	repo := filepath.Join(parent, "repo")
+	if err := os.Mkdir(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, "init", "-q")
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, "add", "file.txt")
+	runGit(t, repo, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init")
+
+	path := workerWorktreePath(repo, "task", 1)
+	branch := workerBranch("task", 1)
+	if err := ensureWorktree(io.Discard, repo, path, branch); err != nil {
+		t.Fatalf("ensureWorktree() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "file.txt")); err != nil {
+		t.Fatalf("worktree missing checked out file: %v", err)
+	}
+	if got, err := gitOutput(path, "rev-parse", "--abbrev-ref", "HEAD"); err != nil || got != branch {
+		t.Fatalf("worktree branch = %q (err %v), want %q", got, err, branch)
+	}
+
+	// Leftover work in an existing worktree must survive a second call
+	marker := filepath.Join(path, "in-progress.txt")
+	if err := os.WriteFile(marker, []byte("wip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureWorktree(io.Discard, repo, path, branch); err != nil {
+		t.Fatalf("ensureWorktree() reuse error = %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("reused worktree lost existing files: %v", err)
+	}
+}
+
+func TestNewWorkerPoolDryRunPartitionsWorkers(t *testing.T) {
+	tmpDir := t.TempDir()
+	taskDir := filepath.Join(tmpDir, "test-task")
+	if err := os.Mkdir(taskDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	env := &Environment{
+		ProjectDir: tmpDir,
+		Config:     Config{Agent: "claude"},
+		Tasks: map[string]Task{
+			"test-task": {Name: "test-task", Dir: taskDir, Prompt: "fix $INPUT"},
+		},
+	}
+
+	pool, err := NewWorkerPool(env, "test-task", RunnerOptions{DryRun: true}, 3)
+	if err != nil {
+		t.Fatalf("NewWorkerPool() error = %v", err)
+	}
+	if len(pool.runners) != 3 {
+		t.Fatalf("len(runners) = %d, want 3", len(pool.runners))
+	}
+	for i, runner := range pool.runners {
+		want := HashPartition{WorkerCount: 3, WorkerIndex: i}
+		if runner.opts.Partition != want {
+			t.Errorf("runner %d partition = %+v, want %+v", i, runner.opts.Partition, want)
+		}
+		if runner.ignoredList != pool.runners[0].ignoredList {
+			t.Errorf("runner %d does not share the ignored list", i)
+		}
+		if runner.procs == pool.runners[0].procs && i > 0 {
+			t.Errorf("runner %d shares a process tracker with worker 1", i)
+		}
+	}
+}
+
+func TestNewWorkerPoolUpdatesReusedWorktree(t *testing.T) {
+	parent := t.TempDir()
+	repo := filepath.Join(parent, "repo")
+	taskDir := filepath.Join(repo, "test-task")
+	if err := os.MkdirAll(taskDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	commit := func(dir, file, message string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(message+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, dir, "add", file)
+		runGit(t, dir, "commit", "-qm", message)
+	}
+	runGit(t, repo, "init", "-q")
+	commit(repo, "a.txt", "init")
+
+	env := &Environment{
+		ProjectDir: repo,
+		Config:     Config{Agent: "claude"},
+		Tasks: map[string]Task{
+			"test-task": {Name: "test-task", Dir: taskDir, Prompt: "fix $INPUT"},
+		},
+	}
+	newPool := func() {
+		t.Helper()
+		captureStdout(t, func() {
+			pool, err := NewWorkerPool(env, "test-task", RunnerOptions{}, 1)
+			if err != nil {
+				t.Fatalf("NewWorkerPool() error = %v", err)
+			}
+			t.Cleanup(func() { pool.runners[0].agentLogger.Close() })
+		})
+	}
+	worktree := workerWorktreePath(repo, "test-task", 1)
+	revParse := func(dir, rev string) string {
+		t.Helper()
+		out, err := gitOutput(dir, "rev-parse", rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	// A worker branch with no commits of its own is fast-forwarded
+	newPool()
+	commit(repo, "b.txt", "user commit")
+	newPool()
+	if got, want := revParse(worktree, "HEAD"), revParse(repo, "HEAD"); got != want {
+		t.Errorf("reused worktree is at %s, want HEAD %s", got, want)
+	}
+
+	// One with its own commits is rebased onto HEAD, keeping them
+	commit(worktree, "c.txt", "worker fix")
+	commit(repo, "d.txt", "another user commit")
+	newPool()
+	if got, want := revParse(worktree, "HEAD~1"), revParse(repo, "HEAD"); got != want {
+		t.Errorf("worker commit is based on %s, want HEAD %s", got, want)
+	}
+	if subject, _ := gitOutput(worktree, "log", "-1", "--format=%s"); subject != "worker fix" {
+		t.Errorf("worker branch tip = %q, want the worker's commit", subject)
+	}
+
+	// Uncommitted work is left alone, with a warning
+	os.WriteFile(filepath.Join(worktree, "wip.txt"), []byte("wip"), 0644)
+	commit(repo, "e.txt", "yet another user commit")
+	tip := revParse(worktree, "HEAD")
+	var warnings bytes.Buffer
+	if err := ensureWorktree(&warnings, repo, worktree, workerBranch("test-task", 1)); err != nil {
+		t.Fatalf("ensureWorktree() error = %v", err)
+	}
+	if got := revParse(worktree, "HEAD"); got != tip {
+		t.Errorf("dirty worktree moved from %s to %s", tip, got)
+	}
+	if !strings.Contains(warnings.String(), "is behind HEAD but has uncommitted changes") {
+		t.Errorf("expected a warning about the stale worktree, got %q", warnings.String())
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+}
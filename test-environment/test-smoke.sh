@@ -17,7 +17,8 @@
 #   2. Slow candidate source - Progress timer appears after 5 seconds
 #   3. Slow agent           - Inactivity timer appears after 30 seconds
 #   4. Empty messages       - No extra blank lines in output
-#   5-14. Streaming edge cases - Various chunk sizes and patterns to stress-test buffering
+#   5-13. Streaming edge cases - Various chunk sizes and patterns to stress-test buffering
+#   14. Gemini backend      - Streams through the Gemini backend with ./mock-gemini
 
 set -e
 
@@ -59,11 +60,13 @@ run_test() {
     local expect="$3"
     local env_vars="$4"
     local timeout_dur="${5:-60}"
+    local output="${6:-/dev/null}"
 
     header "$name"
     echo -e "${GREEN}Expected: $expect${NC}"
     echo ""
-    eval "env $env_vars timeout $timeout_dur $NIGEL_BIN $task" || {
+    eval "env $env_vars timeout $timeout_dur $NIGEL_BIN $task" | tee "$output"
+    (exit "${PIPESTATUS[0]}") || {
         local exit_code=$?
         if [[ $exit_code -eq 124 ]]; then
             echo -e "${RED}Test timed out after ${timeout_dur}s${NC}"
@@ -80,6 +83,19 @@ run_test() {
     fi
 }
 
+# Fail the suite unless the last test's output contains each expected line
+assert_output() {
+    local output="$1"
+    shift
+    for want in "$@"; do
+        if ! grep -qF -- "$want" "$output"; then
+            echo -e "${RED}Assertion failed: output is missing '$want'${NC}"
+            exit 1
+        fi
+    done
+    echo -e "${GREEN}Assertions passed${NC}"
+}
+
 # Main test sequence
 main() {
     cd "$(dirname "$0")"
@@ -218,6 +234,20 @@ main() {
 
     cleanup
 
+    # Asserted, so a task that doesn't reach the Gemini backend fails the suite
+    GEMINI_OUTPUT=$(mktemp)
+    run_test \
+        "Test 14: Gemini Backend" \
+        "gemini-task" \
+        "Gemini backend streams text and a '▶ Bash: ls -a' tool line, both candidates fixed" \
+        "MOCK_GEMINI_FIX=1" \
+        30 \
+        "$GEMINI_OUTPUT"
+    assert_output "$GEMINI_OUTPUT" "Agent: Gemini" "Running Gemini..." "▶ Bash: ls -a"
+    rm -f "$GEMINI_OUTPUT"
+
+    cleanup
+
     header "All Tests Complete"
     echo -e "${GREEN}Smoke test suite finished.${NC}"
     echo ""
@@ -227,6 +257,7 @@ main() {
     echo "  3. Slow agent        - Inactivity timer after 30s"
     echo "  4. Empty messages    - No extra blank lines"
     echo "  5-13. Edge cases     - No corruption, overwriting, or excessive spacing"
+    echo "  14. Gemini backend   - Text and tool line streamed, candidates fixed"
 }
 
 main "$@"